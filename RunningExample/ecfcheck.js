@@ -52,10 +52,15 @@ var simpledao = simpledaoContract.new(
 		console.log("Test ::: Before: SimpleDAO has " + eth.getBalance(simpledaoAddr) + " and Mallory has " + eth.getBalance(malloryAddr));
 
 		console.log("Test ::: Send 1 wei to Mallory contract - sendTransaction() ...");
-		eth.sendTransaction({from: me, to: malloryAddr, value: 1, gas: 500000}); // Send 1 wei to mallory in order to invoke fallback function, will cause mallory to gain 1 wei + 3k wei stolen, totaling to 4k+1 wei.
+		var attackTx = eth.sendTransaction({from: me, to: malloryAddr, value: 1, gas: 500000}); // Send 1 wei to mallory in order to invoke fallback function, will cause mallory to gain 1 wei + 3k wei stolen, totaling to 4k+1 wei.
 		admin.sleepBlocks(3);
 		console.log("Test ::: After: SimpleDAO has " + eth.getBalance(simpledaoAddr) + " and Mallory has " + eth.getBalance(malloryAddr));
 
+		var verdict = ecf.checkTransaction(attackTx);
+		var isECF = (verdict.violations || []).length == 0;
+		console.log("Test ::: Attack transaction is ECF: " + isECF);
+		console.log("Test ::: Violations recorded on SimpleDAO: " + ecf.violations({contract: simpledaoAddr}).length);
+
 	    }
 	 });
 
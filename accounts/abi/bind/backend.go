@@ -23,6 +23,7 @@ import (
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
 	"golang.org/x/net/context"
 )
 
@@ -39,6 +40,10 @@ var (
 	// This error is returned by WaitDeployed if contract creation leaves an
 	// empty contract behind.
 	ErrNoCodeAfterDeploy = errors.New("no contract code after deployment")
+
+	// This error is raised when the ECF pre-flight runs on a backend that
+	// doesn't implement ECFSimulator.
+	ErrNoECFSimulation = errors.New("backend does not support ECF simulation")
 )
 
 // ContractCaller defines the methods needed to allow operating with contract on a read
@@ -68,6 +73,15 @@ type PendingContractCaller interface {
 	PendingCallContract(ctx context.Context, call ethereum.CallMsg) ([]byte, error)
 }
 
+// ECFSimulator defines the methods needed to check a transaction for ECF against
+// the pending state before sending it. ECFPreFlight will try to discover this
+// interface on the transactor, and fails with ErrNoECFSimulation without it.
+type ECFSimulator interface {
+	// PendingCheckECF executes a contract call against the pending state and
+	// checks its execution for ECF. The result is nil if the call ran no code.
+	PendingCheckECF(ctx context.Context, call ethereum.CallMsg) (*vm.ECFResult, error)
+}
+
 // ContractTransactor defines the methods needed to allow operating with contract
 // on a write only basis. Beside the transacting method, the remainder are helpers
 // used when the user does not provide some needed values, but rather leaves it up
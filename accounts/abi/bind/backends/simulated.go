@@ -41,6 +41,9 @@ var chainConfig = &params.ChainConfig{HomesteadBlock: big.NewInt(0), EIP150Block
 // This nil assignment ensures compile time that SimulatedBackend implements bind.ContractBackend.
 var _ bind.ContractBackend = (*SimulatedBackend)(nil)
 
+// This nil assignment ensures compile time that SimulatedBackend implements bind.ECFSimulator.
+var _ bind.ECFSimulator = (*SimulatedBackend)(nil)
+
 var errBlockNumberUnsupported = errors.New("SimulatedBackend cannot access blocks other than the latest block")
 
 // SimulatedBackend implements bind.ContractBackend, simulating a blockchain in
@@ -53,6 +56,8 @@ type SimulatedBackend struct {
 	pendingBlock *types.Block   // Currently pending block that will be imported on request
 	pendingState *state.StateDB // Currently pending state that will be the active on on request
 
+	verdicts ecfVerdicts // ECF checker results of the sent transactions
+
 	config *params.ChainConfig
 }
 
@@ -62,7 +67,7 @@ func NewSimulatedBackend(accounts ...core.GenesisAccount) *SimulatedBackend {
 	database, _ := ethdb.NewMemDatabase()
 	core.WriteGenesisBlockForTesting(database, accounts...)
 	blockchain, _ := core.NewBlockChain(database, chainConfig, new(core.FakePow), new(event.TypeMux), vm.Config{})
-	backend := &SimulatedBackend{database: database, blockchain: blockchain}
+	backend := &SimulatedBackend{database: database, blockchain: blockchain, verdicts: newECFVerdicts()}
 	backend.rollback()
 	return backend
 }
@@ -84,6 +89,7 @@ func (b *SimulatedBackend) Rollback() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	b.verdicts.forget(b.pendingBlock)
 	b.rollback()
 }
 
@@ -167,7 +173,7 @@ func (b *SimulatedBackend) CallContract(ctx context.Context, call ethereum.CallM
 	if err != nil {
 		return nil, err
 	}
-	rval, _, err := b.callContract(ctx, call, b.blockchain.CurrentBlock(), state)
+	rval, _, err := b.callContract(ctx, call, b.blockchain.CurrentBlock(), state, nil)
 	return rval, err
 }
 
@@ -177,7 +183,7 @@ func (b *SimulatedBackend) PendingCallContract(ctx context.Context, call ethereu
 	defer b.mu.Unlock()
 	defer b.pendingState.RevertToSnapshot(b.pendingState.Snapshot())
 
-	rval, _, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState)
+	rval, _, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState, nil)
 	return rval, err
 }
 
@@ -215,7 +221,7 @@ func (b *SimulatedBackend) EstimateGas(ctx context.Context, call ethereum.CallMs
 		call.Gas = new(big.Int).SetUint64(mid)
 
 		snapshot := b.pendingState.Snapshot()
-		_, gas, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState)
+		_, gas, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState, nil)
 		b.pendingState.RevertToSnapshot(snapshot)
 
 		// If the transaction became invalid or used all the gas (failed), raise the gas limit
@@ -230,8 +236,9 @@ func (b *SimulatedBackend) EstimateGas(ctx context.Context, call ethereum.CallMs
 }
 
 // callContract implemens common code between normal and pending contract calls.
-// state is modified during execution, make sure to copy it if necessary.
-func (b *SimulatedBackend) callContract(ctx context.Context, call ethereum.CallMsg, block *types.Block, statedb *state.StateDB) ([]byte, *big.Int, error) {
+// state is modified during execution, make sure to copy it if necessary. The
+// ECF verdict on the call is put in ecf, if given.
+func (b *SimulatedBackend) callContract(ctx context.Context, call ethereum.CallMsg, block *types.Block, statedb *state.StateDB, ecf *vm.ECFResultSlot) ([]byte, *big.Int, error) {
 	// Ensure message is initialized properly.
 	if call.GasPrice == nil {
 		call.GasPrice = big.NewInt(1)
@@ -251,7 +258,7 @@ func (b *SimulatedBackend) callContract(ctx context.Context, call ethereum.CallM
 	evmContext := core.NewEVMContext(msg, block.Header(), b.blockchain)
 	// Create a new environment which holds all relevant information
 	// about the transaction and calling mechanisms.
-	vmenv := vm.NewEVM(evmContext, statedb, chainConfig, vm.Config{})
+	vmenv := vm.NewEVM(evmContext, statedb, chainConfig, vm.Config{ECFContext: vm.ExecCall, ECFResult: ecf})
 	gaspool := new(core.GasPool).AddGas(common.MaxBig)
 	ret, gasUsed, _, err := core.NewStateTransition(vmenv, msg, gaspool).TransitionDb()
 	return ret, gasUsed, err
@@ -272,14 +279,17 @@ func (b *SimulatedBackend) SendTransaction(ctx context.Context, tx *types.Transa
 		panic(fmt.Errorf("invalid transaction nonce: got %d, want %d", tx.Nonce(), nonce))
 	}
 
+	var ecfResults []*vm.ECFResultSlot
 	blocks, _ := core.GenerateChain(chainConfig, b.blockchain.CurrentBlock(), b.database, 1, func(number int, block *core.BlockGen) {
 		for _, tx := range b.pendingBlock.Transactions() {
 			block.AddTx(tx)
 		}
 		block.AddTx(tx)
+		ecfResults = block.ECFResults()
 	})
 	b.pendingBlock = blocks[0]
 	b.pendingState, _ = state.New(b.pendingBlock.Root(), b.database)
+	b.verdicts.record(b.pendingBlock, ecfResults)
 	return nil
 }
 
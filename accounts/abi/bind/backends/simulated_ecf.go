@@ -0,0 +1,100 @@
+// Shelly
+
+package backends
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"golang.org/x/net/context"
+)
+
+// ecfVerdicts keeps the ECF checker results of the transactions sent to the
+// simulated chain, pending or committed, in the order they were sent.
+type ecfVerdicts struct {
+	results map[common.Hash]*vm.ECFResult // nil for transactions that ran no code
+	order   []common.Hash
+}
+
+func newECFVerdicts() ecfVerdicts {
+	return ecfVerdicts{results: make(map[common.Hash]*vm.ECFResult)}
+}
+
+// record takes the results of the transactions of the pending block, from the
+// run of each transaction that built it. Committing the block runs them again on
+// the same state, so their results are kept.
+func (v *ecfVerdicts) record(block *types.Block, results []*vm.ECFResultSlot) {
+	for i, tx := range block.Transactions() {
+		hash := tx.Hash()
+		if _, known := v.results[hash]; !known {
+			v.order = append(v.order, hash)
+		}
+		v.results[hash] = results[i].Result
+	}
+}
+
+// forget drops the results of the transactions of a block that was rolled back.
+func (v *ecfVerdicts) forget(block *types.Block) {
+	for _, tx := range block.Transactions() {
+		delete(v.results, tx.Hash())
+	}
+	order := v.order[:0]
+	for _, hash := range v.order {
+		if _, known := v.results[hash]; known {
+			order = append(order, hash)
+		}
+	}
+	v.order = order
+}
+
+// ECFVerdict returns the ECF checker result of a sent transaction, and whether
+// the transaction is known. The result is nil if the transaction ran no code (or
+// the checker is disabled, see EVM_DISABLE_ECF_CHECK), which is trivially ECF.
+func (b *SimulatedBackend) ECFVerdict(txHash common.Hash) (*vm.ECFResult, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	result, known := b.verdicts.results[txHash]
+	return result, known
+}
+
+// AssertAllECF returns an error listing the sent transactions that are not ECF,
+// or nil if all of them are.
+func (b *SimulatedBackend) AssertAllECF() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	failures := make([]string, 0)
+	for _, hash := range b.verdicts.order {
+		result := b.verdicts.results[hash]
+		if result == nil || result.IsECF() {
+			continue
+		}
+		violations := result.NonExempt()
+		first := violations[0]
+		failures = append(failures, fmt.Sprintf("%s: %d violation(s), first by contract %s at depth %d",
+			hash.Hex(), len(violations), first.Contract.Hex(), first.Depth))
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d of %d transactions not ECF:\n%s", len(failures), len(b.verdicts.order), strings.Join(failures, "\n"))
+	}
+	return nil
+}
+
+// PendingCheckECF implements bind.ECFSimulator, executing a contract call on the
+// pending state and returning the checker's verdict on it.
+func (b *SimulatedBackend) PendingCheckECF(ctx context.Context, call ethereum.CallMsg) (*vm.ECFResult, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	defer b.pendingState.RevertToSnapshot(b.pendingState.Snapshot())
+
+	slot := new(vm.ECFResultSlot)
+	if _, _, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState, slot); err != nil {
+		return nil, err
+	}
+	return slot.Result, nil
+}
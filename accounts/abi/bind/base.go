@@ -33,6 +33,10 @@ import (
 // sign the transaction before submission.
 type SignerFn func(types.Signer, common.Address, *types.Transaction) (*types.Transaction, error)
 
+// PreFlightFn is a check callback run on a fully resolved transaction before it
+// is signed and sent. Returning an error refuses the transaction.
+type PreFlightFn func(ctx context.Context, backend ContractTransactor, call ethereum.CallMsg) error
+
 // CallOpts is the collection of options to fine tune a contract call request.
 type CallOpts struct {
 	Pending bool // Whether to operate on the pending state or the last known one
@@ -51,6 +55,8 @@ type TransactOpts struct {
 	GasPrice *big.Int // Gas price to use for the transaction execution (nil = gas price oracle)
 	GasLimit *big.Int // Gas limit to set for the transaction execution (nil = estimate + 10%)
 
+	PreFlight PreFlightFn // Check to run before sending the transaction, e.g. ECFPreFlight (nil = no check)
+
 	Context context.Context // Network context to support cancellation and timeouts (nil = no timeout)
 }
 
@@ -204,6 +210,13 @@ func (c *BoundContract) transact(opts *TransactOpts, contract *common.Address, i
 			return nil, fmt.Errorf("failed to estimate gas needed: %v", err)
 		}
 	}
+	// Run the pre-flight check on the transaction as it will be sent
+	if opts.PreFlight != nil {
+		msg := ethereum.CallMsg{From: opts.From, To: contract, Gas: gasLimit, GasPrice: gasPrice, Value: value, Data: input}
+		if err := opts.PreFlight(ensureContext(opts.Context), c.transactor, msg); err != nil {
+			return nil, err
+		}
+	}
 	// Create the transaction, sign it and schedule it for execution
 	var rawTx *types.Transaction
 	if contract == nil {
@@ -0,0 +1,43 @@
+// Shelly
+
+package bind
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"golang.org/x/net/context"
+)
+
+// ECFViolationError is returned by a transaction refused by ECFPreFlight. It
+// holds the verdict of the simulated transaction.
+type ECFViolationError struct {
+	Call   ethereum.CallMsg
+	Result *vm.ECFResult
+}
+
+func (err *ECFViolationError) Error() string {
+	violations := err.Result.NonExempt()
+	first := violations[0]
+	return fmt.Sprintf("transaction refused, not ECF: %d violation(s), first by contract %s at depth %d",
+		len(violations), first.Contract.Hex(), first.Depth)
+}
+
+// ECFPreFlight is a PreFlightFn simulating the transaction against the pending
+// state and refusing it with an *ECFViolationError if it is not ECF. The
+// transactor must implement ECFSimulator.
+func ECFPreFlight(ctx context.Context, backend ContractTransactor, call ethereum.CallMsg) error {
+	simulator, ok := backend.(ECFSimulator)
+	if !ok {
+		return ErrNoECFSimulation
+	}
+	result, err := simulator.PendingCheckECF(ctx, call)
+	if err != nil {
+		return fmt.Errorf("failed to check transaction for ECF: %v", err)
+	}
+	if result != nil && !result.IsECF() {
+		return &ECFViolationError{Call: call, Result: result}
+	}
+	return nil
+}
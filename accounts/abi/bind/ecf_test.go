@@ -0,0 +1,180 @@
+// Shelly
+
+package bind_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/net/context"
+)
+
+// ecfTransactor is a transactor whose ECF simulation returns a fixed verdict
+type ecfTransactor struct {
+	result *vm.ECFResult
+	sent   int
+}
+
+func (b *ecfTransactor) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	return []byte{0x00}, nil
+}
+func (b *ecfTransactor) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return 0, nil
+}
+func (b *ecfTransactor) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1), nil
+}
+func (b *ecfTransactor) EstimateGas(ctx context.Context, call ethereum.CallMsg) (*big.Int, error) {
+	return big.NewInt(21000), nil
+}
+func (b *ecfTransactor) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	b.sent++
+	return nil
+}
+func (b *ecfTransactor) PendingCheckECF(ctx context.Context, call ethereum.CallMsg) (*vm.ECFResult, error) {
+	return b.result, nil
+}
+
+func TestECFPreFlight(t *testing.T) {
+	violation := vm.ECFViolation{Contract: common.HexToAddress("0x01"), Depth: 1, Length: 3}
+	tests := map[string]struct {
+		result  *vm.ECFResult
+		refused bool
+	}{
+		"no code":   {nil, false},
+		"ecf":       {&vm.ECFResult{Segments: 3}, false},
+		"violation": {&vm.ECFResult{Segments: 3, Violations: []vm.ECFViolation{violation}}, true},
+	}
+	for name, test := range tests {
+		backend := &ecfTransactor{result: test.result}
+		contract := bind.NewBoundContract(common.HexToAddress("0x01"), abi.ABI{}, nil, backend)
+
+		opts := bind.NewKeyedTransactor(testKey)
+		opts.PreFlight = bind.ECFPreFlight
+		_, err := contract.Transfer(opts)
+		if !test.refused {
+			if err != nil || backend.sent != 1 {
+				t.Errorf("%s: transaction not sent: %v", name, err)
+			}
+			continue
+		}
+		if verr, ok := err.(*bind.ECFViolationError); !ok || verr.Result.Violations[0] != violation {
+			t.Errorf("%s: error mismatch: have %v, want violation of %x", name, err, violation.Contract)
+		}
+		if backend.sent != 0 {
+			t.Errorf("%s: refused transaction was sent", name)
+		}
+	}
+}
+
+func TestECFPreFlightUnsupported(t *testing.T) {
+	if err := bind.ECFPreFlight(context.Background(), nil, ethereum.CallMsg{}); err != bind.ErrNoECFSimulation {
+		t.Errorf("error mismatch: have %v, want %v", err, bind.ErrNoECFSimulation)
+	}
+}
+
+func TestSimulatedBackendECF(t *testing.T) {
+	backend := backends.NewSimulatedBackend(core.GenesisAccount{
+		Address: crypto.PubkeyToAddress(testKey.PublicKey),
+		Balance: big.NewInt(10000000000),
+	})
+	deploy := func(nonce uint64) *types.Transaction {
+		code := common.FromHex(waitDeployedTests["successful deploy"].code)
+		tx := types.NewContractCreation(nonce, big.NewInt(0), big.NewInt(3000000), big.NewInt(1), code)
+		tx, _ = types.SignTx(tx, types.HomesteadSigner{}, testKey)
+		if err := backend.SendTransaction(context.Background(), tx); err != nil {
+			t.Fatalf("failed to send transaction: %v", err)
+		}
+		return tx
+	}
+	committed := deploy(0)
+	backend.Commit()
+	if result, known := backend.ECFVerdict(committed.Hash()); !known {
+		t.Fatalf("no verdict for committed transaction")
+	} else if !vm.DISABLE_CHECKER && (result == nil || !result.IsECF()) {
+		t.Fatalf("verdict mismatch: have %v, want ECF", result)
+	}
+	if err := backend.AssertAllECF(); err != nil {
+		t.Fatalf("unexpected violations: %v", err)
+	}
+
+	rolledBack := deploy(1)
+	if _, known := backend.ECFVerdict(rolledBack.Hash()); !known {
+		t.Fatalf("no verdict for pending transaction")
+	}
+	backend.Rollback()
+	if _, known := backend.ECFVerdict(rolledBack.Hash()); known {
+		t.Fatalf("verdict kept for rolled back transaction")
+	}
+	if _, known := backend.ECFVerdict(committed.Hash()); !known {
+		t.Fatalf("verdict of committed transaction dropped by rollback")
+	}
+}
+
+// reentrantContracts returns the init code of B, which calls its caller back, and of A, which reads slot 0, calls B
+// and writes 5 to slot 0, while A re-entered by B reads slot 0 and writes 7 to it in between. A transaction calling A
+// is thus not ECF.
+func reentrantContracts(b common.Address) (bCode, aCode []byte) {
+	deployed := func(runtime string) []byte {
+		code := common.FromHex(runtime)
+		return append([]byte{0x60, byte(len(code)), 0x60, 0x0c, 0x60, 0x00, 0x39, 0x60, byte(len(code)), 0x60, 0x00, 0xf3}, code...)
+	}
+	bCode = deployed("600060006000600060003361c350f15000")
+	aCode = deployed("323314601057600054506007600055005b600054506000600060006000600073" + common.Bytes2Hex(b.Bytes()) + "620186a0f150600560005500")
+	return bCode, aCode
+}
+
+func TestSimulatedBackendNonECF(t *testing.T) {
+	if vm.DISABLE_CHECKER {
+		t.Skip("the ECF checker is disabled")
+	}
+	sender := crypto.PubkeyToAddress(testKey.PublicKey)
+	backend := backends.NewSimulatedBackend(core.GenesisAccount{Address: sender, Balance: big.NewInt(10000000000)})
+	send := func(tx *types.Transaction) *types.Transaction {
+		tx, _ = types.SignTx(tx, types.HomesteadSigner{}, testKey)
+		if err := backend.SendTransaction(context.Background(), tx); err != nil {
+			t.Fatalf("failed to send transaction: %v", err)
+		}
+		return tx
+	}
+	b, a := crypto.CreateAddress(sender, 0), crypto.CreateAddress(sender, 1)
+	bCode, aCode := reentrantContracts(b)
+	send(types.NewContractCreation(0, big.NewInt(0), big.NewInt(3000000), big.NewInt(1), bCode))
+	send(types.NewContractCreation(1, big.NewInt(0), big.NewInt(3000000), big.NewInt(1), aCode))
+	backend.Commit()
+	if err := backend.AssertAllECF(); err != nil {
+		t.Fatalf("unexpected violations deploying: %v", err)
+	}
+
+	// The pre-flight check refuses the transaction
+	contract := bind.NewBoundContract(a, abi.ABI{}, backend, backend)
+	opts := bind.NewKeyedTransactor(testKey)
+	opts.GasLimit = big.NewInt(300000)
+	opts.PreFlight = bind.ECFPreFlight
+	_, err := contract.Transfer(opts)
+	if verr, ok := err.(*bind.ECFViolationError); !ok || verr.Result.Violations[0].Contract != a {
+		t.Fatalf("error mismatch: have %v, want violation of %x", err, a)
+	}
+	if nonce, _ := backend.PendingNonceAt(context.Background(), sender); nonce != 2 {
+		t.Fatalf("refused transaction was sent, pending nonce %d", nonce)
+	}
+
+	// Sent anyway, the transaction fails the assertion
+	tx := send(types.NewTransaction(2, a, big.NewInt(0), big.NewInt(300000), big.NewInt(1), nil))
+	backend.Commit()
+	if result, known := backend.ECFVerdict(tx.Hash()); !known || result == nil || result.IsECF() {
+		t.Fatalf("verdict mismatch: have %v, want a violation", result)
+	}
+	if err := backend.AssertAllECF(); err == nil {
+		t.Fatalf("expected the reentrant transaction to fail the assertion")
+	}
+}
@@ -67,6 +67,12 @@ var (
 		Name:  "trace",
 		Usage: "Enable VM tracing",
 	}
+	ECFSummaryFlag = cli.StringFlag{
+		Name:  "ecfsummary",
+		Usage: "Write the ECF checker's verdicts on the state and block tests run to this JSON file, and print them",
+	}
+
+	ecfSummary *tests.ECFSummary
 )
 
 func runTestWithReader(test string, r io.Reader) error {
@@ -167,6 +173,9 @@ func runSuite(test, file string) {
 			}
 			defer r.Close()
 
+			if ecfSummary != nil {
+				ecfSummary.Source = curFile
+			}
 			err = runTestWithReader(curTest, r)
 			if err != nil {
 				if continueOnError {
@@ -186,6 +195,12 @@ func setupApp(c *cli.Context) error {
 	useStdIn := c.GlobalBool(ReadStdInFlag.Name)
 	skipTests = strings.Split(c.GlobalString(SkipTestsFlag.Name), " ")
 
+	summaryFile := c.GlobalString(ECFSummaryFlag.Name)
+	if summaryFile != "" {
+		ecfSummary = new(tests.ECFSummary)
+		tests.CollectECFSummary(ecfSummary)
+	}
+
 	if !useStdIn {
 		runSuite(flagTest, flagFile)
 	} else {
@@ -193,9 +208,28 @@ func setupApp(c *cli.Context) error {
 			glog.Fatalln(err)
 		}
 	}
+	if ecfSummary != nil {
+		writeECFSummary(summaryFile)
+	}
 	return nil
 }
 
+// writeECFSummary writes the collected ECF verdicts to the file as JSON and prints them
+func writeECFSummary(path string) {
+	f, err := os.Create(path)
+	if err != nil {
+		glog.Fatalln(err)
+	}
+	defer f.Close()
+
+	if err := ecfSummary.WriteJSON(f); err != nil {
+		glog.Fatalln(err)
+	}
+	if err := ecfSummary.WriteText(os.Stdout); err != nil {
+		glog.Fatalln(err)
+	}
+}
+
 func main() {
 	glog.SetToStderr(true)
 
@@ -213,6 +247,7 @@ func main() {
 		ReadStdInFlag,
 		SkipTestsFlag,
 		TraceFlag,
+		ECFSummaryFlag,
 	}
 
 	if err := app.Run(os.Args); err != nil {
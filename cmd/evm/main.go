@@ -32,6 +32,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/logger/glog"
+	"gopkg.in/urfave/cli.v1"
 )
 
 var gitCommit = "" // Git SHA1 commit hash of the release (set via linker flags)
@@ -90,6 +91,14 @@ var (
 		Name:  "nogasmetering",
 		Usage: "disable gas metering",
 	}
+	ECFModeFlag = cli.StringFlag{
+		Name:  "ecfmode",
+		Usage: "how the ECF checker decides recursive subtraces (heuristic, exact, compare)",
+	}
+	ECFAttributionFlag = cli.StringFlag{
+		Name:  "ecfattribution",
+		Usage: "what the ECF checker records about the code making each storage access (context, tagged, subsegments)",
+	}
 )
 
 func init() {
@@ -106,6 +115,17 @@ func init() {
 		DumpFlag,
 		InputFlag,
 		DisableGasMeteringFlag,
+		ECFModeFlag,
+		ECFAttributionFlag,
+		StateDumpFlag,
+		AccountsFlag,
+		TxFlag,
+		BlockNumberFlag,
+		TimestampFlag,
+		CoinbaseFlag,
+		DifficultyFlag,
+		BlockGasLimitFlag,
+		TestnetFlag,
 	}
 	app.Action = run
 }
@@ -120,12 +140,34 @@ func run(ctx *cli.Context) error {
 
 	logger := vm.NewStructLogger(nil)
 
+	ecfMode, err := vm.ParseECFCheckMode(ctx.GlobalString(ECFModeFlag.Name))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if ctx.GlobalIsSet(ECFAttributionFlag.Name) {
+		attribution, err := vm.ParseStorageAttribution(ctx.GlobalString(ECFAttributionFlag.Name))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		vm.TheChecker().SetAttribution(attribution)
+	}
+
+	if ctx.GlobalIsSet(StateDumpFlag.Name) {
+		return replay(ctx, logger, vm.Config{
+			Tracer:             logger,
+			Debug:              ctx.GlobalBool(DebugFlag.Name),
+			DisableGasMetering: ctx.GlobalBool(DisableGasMeteringFlag.Name),
+			ECFMode:            ecfMode,
+		})
+	}
+
 	tstart := time.Now()
 
 	var (
 		code []byte
 		ret  []byte
-		err  error
 	)
 
 	if ctx.GlobalString(CodeFlag.Name) != "" {
@@ -162,6 +204,7 @@ func run(ctx *cli.Context) error {
 				Tracer:             logger,
 				Debug:              ctx.GlobalBool(DebugFlag.Name),
 				DisableGasMetering: ctx.GlobalBool(DisableGasMeteringFlag.Name),
+				ECFMode:            ecfMode,
 			},
 		})
 	} else {
@@ -178,6 +221,7 @@ func run(ctx *cli.Context) error {
 				Tracer:             logger,
 				Debug:              ctx.GlobalBool(DebugFlag.Name),
 				DisableGasMetering: ctx.GlobalBool(DisableGasMeteringFlag.Name),
+				ECFMode:            ecfMode,
 			},
 		})
 	}
@@ -202,6 +246,8 @@ num gc:     %d
 `, mem.Alloc, mem.TotalAlloc, mem.Mallocs, mem.HeapAlloc, mem.HeapObjects, mem.NumGC)
 	}
 
+	printECFResult()
+
 	fmt.Printf("OUT: 0x%x", ret)
 	if err != nil {
 		fmt.Printf(" error: %v", err)
@@ -210,6 +256,27 @@ num gc:     %d
 	return nil
 }
 
+// printECFResult prints the checker's verdict on the code run, if any
+func printECFResult() {
+	if result := vm.TheChecker().LastResult(); result != nil {
+		fmt.Printf("ECF (%v): %v", result.Mode, result.IsECF())
+		if !result.IsConclusive() {
+			fmt.Printf(" inconclusive (%s limit exceeded)", result.Inconclusive)
+		}
+		for _, violation := range result.Violations {
+			fmt.Printf(" [contract %x depth %d start %d length %d", violation.Contract, violation.Depth, violation.StartIndex, violation.Length)
+			if violation.Code != nil {
+				fmt.Printf(" code %x", *violation.Code)
+			}
+			fmt.Print("]")
+		}
+		for _, disagreement := range result.Disagreements {
+			fmt.Printf(" [disagreement on %x: heuristic %v exact %v]", disagreement.Contract, disagreement.HeuristicECF, disagreement.ExactECF)
+		}
+		fmt.Println()
+	}
+}
+
 func main() {
 	if err := app.Run(os.Args); err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -0,0 +1,133 @@
+// Shelly
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	StateDumpFlag = cli.StringFlag{
+		Name:  "statedump",
+		Usage: "replay --tx against the state in this JSON file, as written by geth dump, instead of running code",
+	}
+	AccountsFlag = cli.StringFlag{
+		Name:  "accounts",
+		Usage: "comma separated addresses of the accounts of --statedump to load (default: all)",
+	}
+	TxFlag = cli.StringFlag{
+		Name:  "tx",
+		Usage: "RLP encoded signed transaction to replay, in hex",
+	}
+	BlockNumberFlag = cli.StringFlag{
+		Name:  "blocknumber",
+		Usage: "number of the block the transaction is replayed in, required with --statedump",
+	}
+	TimestampFlag = cli.StringFlag{
+		Name:  "timestamp",
+		Usage: "timestamp of the block the transaction is replayed in (default: now)",
+	}
+	CoinbaseFlag = cli.StringFlag{
+		Name:  "coinbase",
+		Usage: "coinbase of the block the transaction is replayed in",
+	}
+	DifficultyFlag = cli.StringFlag{
+		Name:  "difficulty",
+		Usage: "difficulty of the block the transaction is replayed in",
+	}
+	BlockGasLimitFlag = cli.StringFlag{
+		Name:  "blockgaslimit",
+		Usage: "gas limit of the block the transaction is replayed in (default: unlimited)",
+	}
+	TestnetFlag = cli.BoolFlag{
+		Name:  "testnet",
+		Usage: "replay with the rules of the test network instead of the main network",
+	}
+)
+
+// replay loads the state dump and applies the transaction to it in the block described by the flags
+func replay(ctx *cli.Context, logger *vm.StructLogger, evmConfig vm.Config) error {
+	// The block number decides the rules, and the signer, the transaction is replayed with
+	if !ctx.GlobalIsSet(TxFlag.Name) || !ctx.GlobalIsSet(BlockNumberFlag.Name) {
+		return fmt.Errorf("--%s requires --%s and --%s", StateDumpFlag.Name, TxFlag.Name, BlockNumberFlag.Name)
+	}
+	dumpFile, err := os.Open(ctx.GlobalString(StateDumpFlag.Name))
+	if err != nil {
+		return err
+	}
+	defer dumpFile.Close()
+
+	var dump state.Dump
+	if err := json.NewDecoder(dumpFile).Decode(&dump); err != nil {
+		return fmt.Errorf("invalid state dump: %v", err)
+	}
+	var accounts []common.Address
+	for _, account := range strings.Split(ctx.GlobalString(AccountsFlag.Name), ",") {
+		if account = strings.TrimSpace(account); account == "" {
+			continue
+		}
+		if !common.IsHexAddress(account) {
+			return fmt.Errorf("invalid account address %q", account)
+		}
+		accounts = append(accounts, common.HexToAddress(account))
+	}
+	statedb, err := state.NewFromDump(dump, accounts)
+	if err != nil {
+		return err
+	}
+
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(common.FromHex(ctx.GlobalString(TxFlag.Name)), tx); err != nil {
+		return fmt.Errorf("invalid transaction: %v", err)
+	}
+
+	cfg := &runtime.Config{
+		ChainConfig: params.MainnetChainConfig,
+		State:       statedb,
+		Coinbase:    common.HexToAddress(ctx.GlobalString(CoinbaseFlag.Name)),
+		BlockNumber: common.Big(ctx.GlobalString(BlockNumberFlag.Name)),
+		Difficulty:  common.Big(ctx.GlobalString(DifficultyFlag.Name)),
+		EVMConfig:   evmConfig,
+	}
+	if ctx.GlobalBool(TestnetFlag.Name) {
+		cfg.ChainConfig = params.TestnetChainConfig
+	}
+	if ctx.GlobalIsSet(TimestampFlag.Name) {
+		cfg.Time = common.Big(ctx.GlobalString(TimestampFlag.Name))
+	}
+	if ctx.GlobalIsSet(BlockGasLimitFlag.Name) {
+		cfg.GasLimit = common.Big(ctx.GlobalString(BlockGasLimitFlag.Name))
+	}
+
+	tstart := time.Now()
+	ret, gasUsed, err := runtime.Replay(tx, cfg)
+	if err != nil {
+		return fmt.Errorf("transaction %x could not be applied: %v", tx.Hash(), err)
+	}
+	if ctx.GlobalBool(SysStatFlag.Name) {
+		fmt.Printf("vm took %v\n", time.Since(tstart))
+	}
+	if ctx.GlobalBool(DumpFlag.Name) {
+		statedb.Commit(cfg.ChainConfig.IsEIP158(cfg.BlockNumber))
+		fmt.Println(string(statedb.Dump()))
+	}
+	vm.StdErrFormat(logger.StructLogs())
+	printECFResult()
+
+	fmt.Printf("TX: %x gas used %v\n", tx.Hash(), gasUsed)
+	fmt.Printf("OUT: 0x%x\n", ret)
+	return nil
+}
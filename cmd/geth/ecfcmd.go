@@ -0,0 +1,423 @@
+// Shelly
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/ecffuzz"
+	"github.com/ethereum/go-ethereum/rlp"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	ecfFuzzABIFlag = cli.StringFlag{
+		Name:  "abi",
+		Usage: "File holding the ABI of the target contract",
+	}
+	ecfFuzzBinFlag = cli.StringFlag{
+		Name:  "bin",
+		Usage: "File holding the creation code of the target contract (hex, constructor arguments appended)",
+	}
+	ecfFuzzIterationsFlag = cli.IntFlag{
+		Name:  "iterations",
+		Value: ecffuzz.DefaultConfig.Iterations,
+		Usage: "Number of random attacks to run",
+	}
+	ecfFuzzDepthFlag = cli.IntFlag{
+		Name:  "depth",
+		Value: ecffuzz.DefaultConfig.MaxDepth,
+		Usage: "Maximum number of re-entries per call",
+	}
+	ecfFuzzStepsFlag = cli.IntFlag{
+		Name:  "steps",
+		Value: ecffuzz.DefaultConfig.MaxSteps,
+		Usage: "Maximum number of calls per attack",
+	}
+	ecfFuzzSeedFlag = cli.Int64Flag{
+		Name:  "seed",
+		Usage: "Seed of the random generator (default: current time)",
+	}
+	ecfFuzzValueFlag = cli.StringFlag{
+		Name:  "value",
+		Value: ecffuzz.DefaultConfig.Value.String(),
+		Usage: "Maximum value in wei sent along a call",
+	}
+	ecffuzzCommand = cli.Command{
+		Action:    ecfFuzz,
+		Name:      "ecffuzz",
+		Usage:     "Search for reentrancy attacks on a contract",
+		ArgsUsage: " ",
+		Category:  "MISCELLANEOUS COMMANDS",
+		Flags: []cli.Flag{
+			ecfFuzzABIFlag,
+			ecfFuzzBinFlag,
+			ecfFuzzIterationsFlag,
+			ecfFuzzDepthFlag,
+			ecfFuzzStepsFlag,
+			ecfFuzzSeedFlag,
+			ecfFuzzValueFlag,
+		},
+		Description: `
+Deploys the contract on a simulated chain, along with attacker contracts whose
+fallback re-enters a random method of the contract with random arguments, up to
+--depth times. Random sequences of calls are then sent to the contract, either
+directly or through the attacker, and each call is checked for ECF.
+
+The attacks that are not ECF are minimised and reported with the runtime code
+of their attacker. Contracts whose constructor takes arguments are supported
+by appending the encoded arguments to the creation code.
+`,
+	}
+	ecftraceCommand = cli.Command{
+		Action:    ecfTrace,
+		Name:      "ecftrace",
+		Usage:     "Write the segment trace of a local transaction to a file",
+		ArgsUsage: "<txHash> <filename>",
+		Category:  "BLOCKCHAIN COMMANDS",
+		Description: `
+Replays the block of the transaction up to the transaction and writes the full
+segment trace the ECF checker recorded for it. The trace is encoded as RLP if
+the file name ends in .rlp, as JSON otherwise. Nothing is written to the chain.
+`,
+	}
+	ecfCheckDotFlag = cli.StringFlag{
+		Name:  "dot",
+		Usage: "File to write the checked trace to as a Graphviz DOT graph",
+	}
+	ecfcheckCommand = cli.Command{
+		Action:    ecfCheck,
+		Name:      "ecfcheck",
+		Usage:     "Check a segment trace file for ECF",
+		ArgsUsage: "<filename>",
+		Category:  "MISCELLANEOUS COMMANDS",
+		Flags: []cli.Flag{
+			ecfCheckDotFlag,
+		},
+		Description: `
+Loads a segment trace written by ecftrace or returned by ecf_traceTransaction,
+in JSON or RLP, and runs the ECF check on it without any chain. The mode is
+taken from --ecfmode.
+
+With --dot, the trace is also written as a Graphviz graph with a lane per
+contract, highlighting the segments and storage locations of each violation.
+`,
+	}
+	ecfscanCommand = cli.Command{
+		Action:    ecfScan,
+		Name:      "ecfscan",
+		Usage:     "Re-execute a range of local blocks through the ECF checker",
+		ArgsUsage: "<blockNumFirst> [<blockNumLast>]",
+		Category:  "BLOCKCHAIN COMMANDS",
+		Description: `
+Replays the transactions of the given canonical blocks on top of their parent
+state and checks each of them for ECF. Nothing is written to the chain.
+
+Unless --ecfmode is given the scan runs in compare mode, reporting both the
+verdicts of the cutpoint heuristic and every transaction on which the exact
+search disagrees with it. The exact search is bounded by --ecfexactsegments
+and --ecfexacttimeout.
+`,
+	}
+)
+
+// ecfScanStats accumulates the verdicts of an ecfscan run
+type ecfScanStats struct {
+	blocks        int
+	transactions  int
+	nonECF        int
+	disagreements int
+	exactAborted  int
+	inconclusive  int
+}
+
+func ecfScan(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	first, err := strconv.ParseUint(ctx.Args().Get(0), 10, 64)
+	if err != nil {
+		utils.Fatalf("Invalid first block number: %v", err)
+	}
+	last := first
+	if len(ctx.Args()) > 1 {
+		if last, err = strconv.ParseUint(ctx.Args().Get(1), 10, 64); err != nil {
+			utils.Fatalf("Invalid last block number: %v", err)
+		}
+	}
+	if first == 0 || last < first {
+		utils.Fatalf("Invalid block range %d-%d (the genesis block has no transactions to check)", first, last)
+	}
+
+	mode := vm.ECFModeCompare
+	if ctx.GlobalIsSet(utils.ECFCheckModeFlag.Name) {
+		mode = vm.TheChecker().Mode()
+	}
+
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	var (
+		config = chain.Config()
+		stats  ecfScanStats
+		start  = time.Now()
+	)
+	for number := first; number <= last; number++ {
+		block := chain.GetBlockByNumber(number)
+		if block == nil {
+			utils.Fatalf("Block #%d not found", number)
+		}
+		parent := chain.GetBlock(block.ParentHash(), number-1)
+		if parent == nil {
+			utils.Fatalf("Parent of block #%d not found", number)
+		}
+		statedb, err := chain.StateAt(parent.Root())
+		if err != nil {
+			utils.Fatalf("Could not load the state of block #%d: %v", number-1, err)
+		}
+
+		var (
+			header  = block.Header()
+			gp      = new(core.GasPool).AddGas(block.GasLimit())
+			usedGas = new(big.Int)
+		)
+		if config.DAOForkSupport && config.DAOForkBlock != nil && config.DAOForkBlock.Cmp(block.Number()) == 0 {
+			core.ApplyDAOHardFork(statedb)
+		}
+		for i, tx := range block.Transactions() {
+			slot := new(vm.ECFResultSlot)
+
+			statedb.StartRecord(tx.Hash(), block.Hash(), i)
+			if _, _, err := core.ApplyTransaction(config, chain, gp, statedb, header, tx, usedGas, vm.Config{ECFMode: mode, ECFContext: vm.ExecScan, ECFResult: slot}); err != nil {
+				utils.Fatalf("Failed to replay transaction %x in block #%d: %v", tx.Hash(), number, err)
+			}
+
+			result := slot.Result
+			if result == nil { // No code was run
+				continue
+			}
+			stats.transactions++
+			stats.exactAborted += result.ExactAborted
+			if !result.IsConclusive() {
+				stats.inconclusive++
+				fmt.Printf("block %d tx %x: inconclusive, %s limit exceeded\n", number, tx.Hash(), result.Inconclusive)
+			}
+			if !result.IsECF() {
+				stats.nonECF++
+				for _, violation := range result.Violations {
+					fmt.Printf("block %d tx %x: not ECF, contract %x, depth %d, start index %d, length %d", number, tx.Hash(), violation.Contract, violation.Depth, violation.StartIndex, violation.Length)
+					if violation.Code != nil {
+						fmt.Printf(", through code %x", *violation.Code)
+					}
+					fmt.Println()
+				}
+			}
+			for _, disagreement := range result.Disagreements {
+				stats.disagreements++
+				fmt.Printf("block %d tx %x: contract %x, heuristic ECF %v, exact ECF %v\n", number, tx.Hash(), disagreement.Contract, disagreement.HeuristicECF, disagreement.ExactECF)
+			}
+		}
+		stats.blocks++
+	}
+
+	fmt.Printf("Scanned %d blocks (%d transactions) in %v using %v mode\n", stats.blocks, stats.transactions, time.Since(start), mode)
+	fmt.Printf("Not ECF:              %d\n", stats.nonECF)
+	fmt.Printf("Inconclusive:         %d\n", stats.inconclusive)
+	if mode == vm.ECFModeCompare {
+		fmt.Printf("Disagreements:        %d\n", stats.disagreements)
+	}
+	if mode == vm.ECFModeExact || mode == vm.ECFModeCompare {
+		fmt.Printf("Exact search aborted: %d\n", stats.exactAborted)
+	}
+	return nil
+}
+
+func ecfFuzz(ctx *cli.Context) error {
+	if !ctx.IsSet(ecfFuzzABIFlag.Name) || !ctx.IsSet(ecfFuzzBinFlag.Name) {
+		utils.Fatalf("The --%s and --%s flags are required.", ecfFuzzABIFlag.Name, ecfFuzzBinFlag.Name)
+	}
+	abiFile, err := os.Open(ctx.String(ecfFuzzABIFlag.Name))
+	if err != nil {
+		utils.Fatalf("Failed to open ABI: %v", err)
+	}
+	defer abiFile.Close()
+	contractABI, err := abi.JSON(abiFile)
+	if err != nil {
+		utils.Fatalf("Failed to parse ABI: %v", err)
+	}
+	bin, err := ioutil.ReadFile(ctx.String(ecfFuzzBinFlag.Name))
+	if err != nil {
+		utils.Fatalf("Failed to read creation code: %v", err)
+	}
+	code := common.FromHex(strings.TrimSpace(string(bin)))
+
+	config := ecffuzz.Config{
+		Iterations: ctx.Int(ecfFuzzIterationsFlag.Name),
+		MaxDepth:   ctx.Int(ecfFuzzDepthFlag.Name),
+		MaxSteps:   ctx.Int(ecfFuzzStepsFlag.Name),
+		Value:      new(big.Int),
+		Seed:       time.Now().UnixNano(),
+	}
+	if ctx.IsSet(ecfFuzzSeedFlag.Name) {
+		config.Seed = ctx.Int64(ecfFuzzSeedFlag.Name)
+	}
+	if _, ok := config.Value.SetString(ctx.String(ecfFuzzValueFlag.Name), 10); !ok {
+		utils.Fatalf("Invalid value: %s", ctx.String(ecfFuzzValueFlag.Name))
+	}
+	fuzzer, err := ecffuzz.New(contractABI, code, config)
+	if err != nil {
+		utils.Fatalf("Failed to set up fuzzing: %v", err)
+	}
+
+	start := time.Now()
+	findings, err := fuzzer.Run()
+	if err != nil {
+		utils.Fatalf("Fuzzing failed: %v", err)
+	}
+	for i, finding := range findings {
+		fmt.Printf("Attack %d, re-entering %v up to %d time(s):\n", i+1, finding.Reentry, finding.Depth)
+		for j, step := range finding.Steps {
+			fmt.Printf("  %d. %v\n", j+1, step)
+		}
+		for _, violation := range finding.Violations {
+			fmt.Printf("  not ECF: contract %x, depth %d, start index %d, length %d\n", violation.Contract, violation.Depth, violation.StartIndex, violation.Length)
+		}
+		fmt.Printf("  attacker code: %x\n", []byte(finding.AttackerCode))
+	}
+	fmt.Printf("Ran %d attacks in %v (seed %d), %d not ECF\n", config.Iterations, time.Since(start), config.Seed, len(findings))
+	return nil
+}
+
+func ecfTrace(ctx *cli.Context) error {
+	if len(ctx.Args()) < 2 {
+		utils.Fatalf("This command requires two arguments.")
+	}
+	txHash := common.HexToHash(ctx.Args().Get(0))
+	filename := ctx.Args().Get(1)
+
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	target, blockHash, number, _ := core.GetTransaction(chainDb, txHash)
+	if target == nil {
+		utils.Fatalf("Transaction %x not found", txHash)
+	}
+	block := chain.GetBlock(blockHash, number)
+	if block == nil {
+		utils.Fatalf("Block #%d of the transaction not found", number)
+	}
+	parent := chain.GetBlock(block.ParentHash(), number-1)
+	if parent == nil {
+		utils.Fatalf("Parent of block #%d not found", number)
+	}
+	statedb, err := chain.StateAt(parent.Root())
+	if err != nil {
+		utils.Fatalf("Could not load the state of block #%d: %v", number-1, err)
+	}
+
+	var (
+		config  = chain.Config()
+		header  = block.Header()
+		gp      = new(core.GasPool).AddGas(block.GasLimit())
+		usedGas = new(big.Int)
+		result  = new(vm.ECFResultSlot)
+		slot    = new(vm.ECFTraceSlot)
+	)
+	if config.DAOForkSupport && config.DAOForkBlock != nil && config.DAOForkBlock.Cmp(block.Number()) == 0 {
+		core.ApplyDAOHardFork(statedb)
+	}
+	for i, tx := range block.Transactions() {
+		cfg := vm.Config{ECFSkip: true}
+		if tx.Hash() == txHash {
+			cfg = vm.Config{ECFContext: vm.ExecTrace, ECFResult: result, ECFTrace: slot}
+		}
+		statedb.StartRecord(tx.Hash(), block.Hash(), i)
+		if _, _, err := core.ApplyTransaction(config, chain, gp, statedb, header, tx, usedGas, cfg); err != nil {
+			utils.Fatalf("Failed to replay transaction %x in block #%d: %v", tx.Hash(), number, err)
+		}
+		if tx.Hash() != txHash {
+			continue
+		}
+		if result.Result == nil {
+			utils.Fatalf("Transaction %x ran no code", txHash)
+		}
+		break
+	}
+	trace := slot.Trace
+	if trace == nil {
+		utils.Fatalf("No segment trace recorded for transaction %x", txHash)
+	}
+	trace.Transaction = txHash
+
+	var data []byte
+	if filepath.Ext(filename) == ".rlp" {
+		data, err = rlp.EncodeToBytes(trace)
+	} else {
+		data, err = json.MarshalIndent(trace, "", "  ")
+	}
+	if err != nil {
+		utils.Fatalf("Failed to encode the segment trace: %v", err)
+	}
+	if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+		utils.Fatalf("Failed to write the segment trace: %v", err)
+	}
+	fmt.Printf("Wrote the trace of transaction %x (%d segments) to %s\n", txHash, len(trace.Segments), filename)
+	return nil
+}
+
+func ecfCheck(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	data, err := ioutil.ReadFile(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Failed to read the segment trace: %v", err)
+	}
+	trace, err := vm.DecodeSegmentTrace(data)
+	if err != nil {
+		utils.Fatalf("Failed to decode the segment trace: %v", err)
+	}
+	result, err := vm.TheChecker().CheckTrace(trace, vm.ECFModeDefault)
+	if err != nil {
+		utils.Fatalf("Invalid segment trace: %v", err)
+	}
+
+	fmt.Printf("Transaction %x in block %d: %d segments, %s attribution, %v mode\n", trace.Transaction, trace.Block, result.Segments, trace.Attribution, result.Mode)
+	for _, violation := range result.Violations {
+		fmt.Printf("not ECF: contract %x, depth %d, start index %d, length %d", violation.Contract, violation.Depth, violation.StartIndex, violation.Length)
+		if violation.Code != nil {
+			fmt.Printf(", through code %x", *violation.Code)
+		}
+		fmt.Println()
+	}
+	for _, disagreement := range result.Disagreements {
+		fmt.Printf("contract %x: heuristic ECF %v, exact ECF %v\n", disagreement.Contract, disagreement.HeuristicECF, disagreement.ExactECF)
+	}
+	if !result.IsConclusive() {
+		fmt.Printf("inconclusive, %s limit exceeded\n", result.Inconclusive)
+	} else if result.IsECF() {
+		fmt.Println("ECF")
+	}
+	if path := ctx.String(ecfCheckDotFlag.Name); path != "" {
+		if err := ioutil.WriteFile(path, []byte(trace.DOT(result)), 0644); err != nil {
+			utils.Fatalf("Failed to write the graph: %v", err)
+		}
+		fmt.Printf("Graph written to %s\n", path)
+	}
+	return nil
+}
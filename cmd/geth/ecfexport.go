@@ -0,0 +1,311 @@
+// Shelly
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	ecfExportFormatFlag = cli.StringFlag{
+		Name:  "format",
+		Value: "csv",
+		Usage: "Output format: csv, json or html",
+	}
+	ecfExportOutputFlag = cli.StringFlag{
+		Name:  "output",
+		Usage: "File to write the findings to (default: standard output)",
+	}
+	ecfExportFromBlockFlag = cli.Uint64Flag{
+		Name:  "fromblock",
+		Usage: "First block of the exported findings",
+	}
+	ecfExportToBlockFlag = cli.Uint64Flag{
+		Name:  "toblock",
+		Usage: "Last block of the exported findings",
+	}
+	ecfExportContractFlag = cli.StringFlag{
+		Name:  "contract",
+		Usage: "Only export the findings on this contract",
+	}
+	ecfExportContextFlag = cli.StringFlag{
+		Name:  "context",
+		Usage: "Only export the findings of this execution context (import, mine, pool-sim, call, trace, scan)",
+	}
+	ecfExportNonCanonicalFlag = cli.BoolFlag{
+		Name:  "noncanonical",
+		Usage: "Also export the findings of side chains and orphaned blocks",
+	}
+	ecfCommand = cli.Command{
+		Name:      "ecf",
+		Usage:     "Manage the findings of the ECF checker",
+		ArgsUsage: "",
+		Category:  "BLOCKCHAIN COMMANDS",
+		Description: `
+Manage the findings the ECF checker stored in ecf.db.`,
+		Subcommands: []cli.Command{
+			{
+				Action:    ecfExport,
+				Name:      "export",
+				Usage:     "Export the stored findings with their block and transaction details",
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					ecfExportFormatFlag,
+					ecfExportOutputFlag,
+					ecfExportFromBlockFlag,
+					ecfExportToBlockFlag,
+					ecfExportContractFlag,
+					ecfExportContextFlag,
+					ecfExportNonCanonicalFlag,
+				},
+				Description: `
+    geth ecf export --format csv|json|html [--output <file>]
+
+Writes the violations stored in ecf.db, joined with the details of their block
+(hash, miner) and transaction (sender, recipient, value, nonce, index, gas
+used) from the local chain, e.g. for sharing with auditors. Only the findings
+of the canonical chain are exported unless --noncanonical is given.
+
+Violations stored by older versions carry no transaction hash. Their block is
+then taken from the canonical chain, and their transaction details are left
+empty.
+`,
+			},
+		},
+	}
+)
+
+// ecfFinding is a stored violation along with the details of its block and transaction. Details missing from the
+// local chain are left empty.
+type ecfFinding struct {
+	*vm.StoredViolation
+	Miner   *common.Address `json:"miner,omitempty"`
+	From    *common.Address `json:"from,omitempty"`
+	To      *common.Address `json:"to,omitempty"` // Empty for contract creations
+	Value   *big.Int        `json:"value,omitempty"`
+	Nonce   *uint64         `json:"nonce,omitempty"`
+	TxIndex *uint64         `json:"txIndex,omitempty"`
+	GasUsed *big.Int        `json:"gasUsed,omitempty"`
+}
+
+func ecfExport(ctx *cli.Context) error {
+	write, ok := ecfExportFormats[strings.ToLower(ctx.String(ecfExportFormatFlag.Name))]
+	if !ok {
+		utils.Fatalf("Unknown format %q (want csv, json or html)", ctx.String(ecfExportFormatFlag.Name))
+	}
+	filter := vm.ViolationFilter{NonCanonical: ctx.Bool(ecfExportNonCanonicalFlag.Name)}
+	if ctx.IsSet(ecfExportFromBlockFlag.Name) {
+		from := ctx.Uint64(ecfExportFromBlockFlag.Name)
+		filter.FromBlock = &from
+	}
+	if ctx.IsSet(ecfExportToBlockFlag.Name) {
+		to := ctx.Uint64(ecfExportToBlockFlag.Name)
+		filter.ToBlock = &to
+	}
+	if ctx.IsSet(ecfExportContractFlag.Name) {
+		if !common.IsHexAddress(ctx.String(ecfExportContractFlag.Name)) {
+			utils.Fatalf("Invalid contract address %q", ctx.String(ecfExportContractFlag.Name))
+		}
+		contract := common.HexToAddress(ctx.String(ecfExportContractFlag.Name))
+		filter.Contract = &contract
+	}
+	if ctx.IsSet(ecfExportContextFlag.Name) {
+		context, err := vm.ParseExecutionContext(ctx.String(ecfExportContextFlag.Name))
+		if err != nil {
+			utils.Fatalf("%v", err)
+		}
+		name := context.String()
+		filter.Context = &name
+	}
+
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	violations, err := vm.QueryViolations(stack.GetDbHandler(), filter)
+	if err != nil {
+		utils.Fatalf("Failed to read the stored violations: %v", err)
+	}
+	findings := make([]*ecfFinding, len(violations))
+	for i, violation := range violations {
+		findings[i] = joinFinding(chain.Config(), chainDb, violation)
+	}
+
+	out := io.Writer(os.Stdout)
+	if path := ctx.String(ecfExportOutputFlag.Name); path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			utils.Fatalf("Failed to create %s: %v", path, err)
+		}
+		defer file.Close()
+		out = file
+	}
+	if err := write(out, findings); err != nil {
+		utils.Fatalf("Failed to export the findings: %v", err)
+	}
+	if out != io.Writer(os.Stdout) {
+		fmt.Printf("Exported %d findings to %s\n", len(findings), ctx.String(ecfExportOutputFlag.Name))
+	}
+	return nil
+}
+
+// joinFinding looks up the block and transaction of a violation in the chain database
+func joinFinding(config *params.ChainConfig, db ethdb.Database, violation *vm.StoredViolation) *ecfFinding {
+	finding := &ecfFinding{StoredViolation: violation}
+
+	blockHash := violation.BlockHash
+	if violation.TxHash != (common.Hash{}) {
+		if tx, hash, number, index := core.GetTransaction(db, violation.TxHash); tx != nil {
+			blockHash = hash
+			nonce := tx.Nonce()
+			finding.Nonce, finding.TxIndex, finding.To, finding.Value = &nonce, &index, tx.To(), tx.Value()
+			if from, err := types.Sender(types.MakeSigner(config, new(big.Int).SetUint64(number)), tx); err == nil {
+				finding.From = &from
+			}
+			if receipt := core.GetReceipt(db, violation.TxHash); receipt != nil {
+				finding.GasUsed = receipt.GasUsed
+			}
+		}
+	}
+	if blockHash == (common.Hash{}) {
+		blockHash = core.GetCanonicalHash(db, violation.Block)
+	}
+	if header := core.GetHeader(db, blockHash, violation.Block); header != nil {
+		violation.BlockHash = header.Hash()
+		finding.Miner = &header.Coinbase
+	}
+	return finding
+}
+
+// ecfExportFormats writes the findings in each supported format
+var ecfExportFormats = map[string]func(io.Writer, []*ecfFinding) error{
+	"csv":  writeFindingsCSV,
+	"json": writeFindingsJSON,
+	"html": writeFindingsHTML,
+}
+
+var ecfExportColumns = []string{"transactionId", "block", "blockHash", "time", "miner", "canonical", "context", "txHash", "txIndex", "from", "to", "value", "nonce", "gasUsed", "contract", "code", "depth", "startIndex", "length", "exempt"}
+
+// row returns the finding's values, in the order of ecfExportColumns, as text
+func (finding *ecfFinding) row() []string {
+	optional := func(value fmt.Stringer, present bool) string {
+		if !present {
+			return ""
+		}
+		return value.String()
+	}
+	hash := func(hash common.Hash) string {
+		if hash == (common.Hash{}) {
+			return ""
+		}
+		return hash.Hex()
+	}
+	address := func(address *common.Address) string {
+		if address == nil {
+			return ""
+		}
+		return address.Hex()
+	}
+	number := func(number *uint64) string {
+		if number == nil {
+			return ""
+		}
+		return strconv.FormatUint(*number, 10)
+	}
+	return []string{
+		strconv.Itoa(finding.TransactionID),
+		strconv.FormatUint(finding.Block, 10),
+		hash(finding.BlockHash),
+		strconv.FormatUint(finding.Time, 10),
+		address(finding.Miner),
+		strconv.FormatBool(finding.Canonical),
+		finding.Context,
+		hash(finding.TxHash),
+		number(finding.TxIndex),
+		address(finding.From),
+		address(finding.To),
+		optional(finding.Value, finding.Value != nil),
+		number(finding.Nonce),
+		optional(finding.GasUsed, finding.GasUsed != nil),
+		finding.Contract.Hex(),
+		address(finding.Code),
+		strconv.Itoa(finding.Depth),
+		strconv.Itoa(finding.StartIndex),
+		strconv.Itoa(finding.Length),
+		strconv.FormatBool(finding.Exempt),
+	}
+}
+
+func writeFindingsCSV(out io.Writer, findings []*ecfFinding) error {
+	w := csv.NewWriter(out)
+	if err := w.Write(ecfExportColumns); err != nil {
+		return err
+	}
+	for _, finding := range findings {
+		if err := w.Write(finding.row()); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func writeFindingsJSON(out io.Writer, findings []*ecfFinding) error {
+	encoded, err := json.MarshalIndent(findings, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s\n", encoded)
+	return err
+}
+
+var ecfExportHTML = template.Must(template.New("findings").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>ECF findings</title>
+<style>
+table { border-collapse: collapse; font-family: monospace; font-size: small; }
+th, td { border: 1px solid #ccc; padding: 2px 6px; white-space: nowrap; }
+th { background: #eee; }
+</style>
+</head>
+<body>
+<h1>ECF findings</h1>
+<p>{{len .Rows}} violation(s)</p>
+<table>
+<tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+func writeFindingsHTML(out io.Writer, findings []*ecfFinding) error {
+	rows := make([][]string, len(findings))
+	for i, finding := range findings {
+		rows[i] = finding.row()
+	}
+	return ecfExportHTML.Execute(out, struct {
+		Columns []string
+		Rows    [][]string
+	}{ecfExportColumns, rows})
+}
@@ -0,0 +1,177 @@
+// Shelly
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gizak/termui"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// The charts of the ECF panel. The time chart is named so updateChart labels it with time units.
+var ecfMonitorCharts = []string{
+	"ecf/checks/second",
+	"ecf/violations/block",
+	"ecf/time/check",
+	"ecf/segments/transaction",
+}
+
+const ecfMonitorViolations = 32 // Number of violations requested for the scrolling list
+
+// ecfSample is a snapshot of the checker counters of the attached node
+type ecfSample struct {
+	stats vm.ECFStats
+	block uint64
+	taken time.Time
+}
+
+// monitorECF shows the ECF panel: charts of the checker activity computed from
+// the deltas of its counters between refreshes, and the latest violations.
+func monitorECF(ctx *cli.Context, client *rpc.Client) error {
+	if _, err := retrieveECFSample(client); err != nil {
+		utils.Fatalf("Failed to retrieve ECF stats (is the ecf API exposed?): %v", err)
+	}
+	if err := termui.Init(); err != nil {
+		utils.Fatalf("Unable to initialize terminal UI: %v", err)
+	}
+	defer termui.Close()
+
+	footer := termui.NewPar("")
+	footer.Block.Border = true
+	footer.Height = 3
+
+	violations := termui.NewList()
+	violations.BorderLabel = "ecf/violations/latest"
+	violations.ItemFgColor = termui.ColorYellow
+
+	chartHeight := func() int { return (termui.TermHeight() - footer.Height) / 2 }
+	charts := make([]*termui.LineChart, len(ecfMonitorCharts))
+	units := make([]int, len(ecfMonitorCharts))
+	data := make([][]float64, len(ecfMonitorCharts))
+
+	for i := range charts {
+		charts[i] = createChart(chartHeight())
+	}
+	violations.Height = 2 * chartHeight()
+	termui.Body.AddRows(
+		termui.NewRow(
+			termui.NewCol(4, 0, charts[0], charts[2]),
+			termui.NewCol(4, 0, charts[1], charts[3]),
+			termui.NewCol(4, 0, violations),
+		),
+		termui.NewRow(termui.NewCol(12, 0, footer)),
+	)
+	previous, _ := retrieveECFSample(client)
+	refreshECF(client, previous, violations, data, units, charts, ctx, footer)
+	termui.Body.Align()
+	termui.Render(termui.Body)
+
+	termui.Handle("/sys/kbd/C-c", func(termui.Event) {
+		termui.StopLoop()
+	})
+	termui.Handle("/sys/wnd/resize", func(termui.Event) {
+		termui.Body.Width = termui.TermWidth()
+		for _, chart := range charts {
+			chart.Height = chartHeight()
+		}
+		violations.Height = 2 * chartHeight()
+		termui.Body.Align()
+		termui.Render(termui.Body)
+	})
+	go func() {
+		tick := time.NewTicker(time.Duration(ctx.Int(monitorCommandRefreshFlag.Name)) * time.Second)
+		for range tick.C {
+			var realign bool
+			if previous, realign = refreshECF(client, previous, violations, data, units, charts, ctx, footer); realign {
+				termui.Body.Align()
+			}
+			termui.Render(termui.Body)
+		}
+	}()
+	termui.Loop()
+	return nil
+}
+
+// retrieveECFSample reads the checker counters and the head block of the attached node.
+func retrieveECFSample(client *rpc.Client) (*ecfSample, error) {
+	sample := &ecfSample{taken: time.Now()}
+	if err := client.Call(&sample.stats, "ecf_stats"); err != nil {
+		return nil, err
+	}
+	var block hexutil.Uint64
+	if err := client.Call(&block, "eth_blockNumber"); err != nil {
+		return nil, err
+	}
+	sample.block = uint64(block)
+	return sample, nil
+}
+
+// ecfRates derives the charted values from two consecutive samples, in the
+// order of ecfMonitorCharts.
+func ecfRates(previous, current *ecfSample) []float64 {
+	rates := make([]float64, len(ecfMonitorCharts))
+	if previous == nil || current == nil {
+		return rates
+	}
+	transactions := float64(current.stats.Transactions - previous.stats.Transactions)
+	if elapsed := current.taken.Sub(previous.taken).Seconds(); elapsed > 0 {
+		rates[0] = transactions / elapsed
+	}
+	if blocks := current.block - previous.block; current.block > previous.block {
+		rates[1] = float64(current.stats.Violations-previous.stats.Violations) / float64(blocks)
+	}
+	if transactions > 0 {
+		rates[2] = float64(current.stats.CheckTime-previous.stats.CheckTime) / transactions
+		rates[3] = float64(current.stats.Segments-previous.stats.Segments) / transactions
+	}
+	return rates
+}
+
+// refreshECF takes a new sample, pushes the derived values into the charts and
+// reloads the list of violations. It returns the sample to compare the next one to.
+func refreshECF(client *rpc.Client, previous *ecfSample, list *termui.List, data [][]float64, units []int, charts []*termui.LineChart, ctx *cli.Context, footer *termui.Par) (*ecfSample, bool) {
+	current, err := retrieveECFSample(client)
+	rates := ecfRates(previous, current)
+
+	var realign bool
+	for i, metric := range ecfMonitorCharts {
+		if len(data[i]) < 512 {
+			data[i] = append([]float64{rates[i]}, data[i]...)
+		} else {
+			data[i] = append([]float64{rates[i]}, data[i][:len(data[i])-1]...)
+		}
+		if updateChart(metric, data[i], &units[i], charts[i], err) {
+			realign = true
+		}
+	}
+	if err == nil {
+		var violations []*vm.StoredViolation
+		if err = client.Call(&violations, "ecf_recentViolations", ecfMonitorViolations); err == nil {
+			list.Items = formatECFViolations(violations)
+		}
+	}
+	updateFooter(ctx, err, footer)
+
+	if current == nil { // Keep the last good sample so the next deltas span the failure
+		current = previous
+	}
+	return current, realign
+}
+
+// formatECFViolations renders one line per violation, the most recent first.
+func formatECFViolations(violations []*vm.StoredViolation) []string {
+	if len(violations) == 0 {
+		return []string{"No violations found"}
+	}
+	items := make([]string, len(violations))
+	for i, violation := range violations {
+		items[i] = fmt.Sprintf("#%d %s depth %d", violation.Block, violation.Contract.Hex(), violation.Depth)
+	}
+	return items
+}
@@ -70,6 +70,13 @@ func init() {
 		upgradedbCommand,
 		removedbCommand,
 		dumpCommand,
+		// See ecfcmd.go:
+		ecfscanCommand,
+		ecffuzzCommand,
+		ecftraceCommand,
+		ecfcheckCommand,
+		// See ecfexport.go:
+		ecfCommand,
 		// See monitorcmd.go:
 		monitorCommand,
 		// See accountcmd.go:
@@ -136,6 +143,19 @@ func init() {
 		utils.VMJitCacheFlag,
 		utils.VMEnableJitFlag,
 		utils.VMEnableDebugFlag,
+		utils.ECFCheckModeFlag,
+		utils.ECFExactMaxSegmentsFlag,
+		utils.ECFExactTimeoutFlag,
+		utils.ECFMaxSegmentsFlag,
+		utils.ECFMaxLocationsFlag,
+		utils.ECFMaxCheckWorkFlag,
+		utils.ECFMaxCheckTimeFlag,
+		utils.ECFAttributionFlag,
+		utils.ECFNoStaticFlag,
+		utils.ECFLogsFlag,
+		utils.ECFIndexFlag,
+		utils.ECFPersistFlag,
+		utils.ECFRegistryFlag,
 		utils.NetworkIdFlag,
 		utils.RPCCORSDomainFlag,
 		utils.EthStatsURLFlag,
@@ -167,6 +187,7 @@ func init() {
 		eth.EnableBadBlockReporting = true
 
 		utils.SetupNetwork(ctx)
+		utils.SetupECFChecker(ctx)
 		return nil
 	}
 
@@ -48,6 +48,10 @@ var (
 		Value: 3,
 		Usage: "Refresh interval in seconds",
 	}
+	monitorCommandECFFlag = cli.BoolFlag{
+		Name:  "ecf",
+		Usage: "Show the ECF checker panel instead of metrics",
+	}
 	monitorCommand = cli.Command{
 		Action:    monitor,
 		Name:      "monitor",
@@ -58,11 +62,16 @@ var (
 The Geth monitor is a tool to collect and visualize various internal metrics
 gathered by the node, supporting different chart types as well as the capacity
 to display multiple metrics simultaneously.
+
+With --ecf it shows the ECF checker panel instead: checks per second,
+violations per block, average check time and segments per transaction, along
+with the latest violations found by the attached node.
 `,
 		Flags: []cli.Flag{
 			monitorCommandAttachFlag,
 			monitorCommandRowsFlag,
 			monitorCommandRefreshFlag,
+			monitorCommandECFFlag,
 		},
 	}
 )
@@ -80,6 +89,9 @@ func monitor(ctx *cli.Context) error {
 	}
 	defer client.Close()
 
+	if ctx.Bool(monitorCommandECFFlag.Name) {
+		return monitorECF(ctx, client)
+	}
 	// Retrieve all the available metrics and resolve the user pattens
 	metrics, err := retrieveMetrics(client)
 	if err != nil {
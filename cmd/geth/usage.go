@@ -158,6 +158,24 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.VMEnableDebugFlag,
 		},
 	},
+	{
+		Name: "ECF CHECKER",
+		Flags: []cli.Flag{
+			utils.ECFCheckModeFlag,
+			utils.ECFExactMaxSegmentsFlag,
+			utils.ECFExactTimeoutFlag,
+			utils.ECFMaxSegmentsFlag,
+			utils.ECFMaxLocationsFlag,
+			utils.ECFMaxCheckWorkFlag,
+			utils.ECFMaxCheckTimeFlag,
+			utils.ECFAttributionFlag,
+			utils.ECFNoStaticFlag,
+			utils.ECFLogsFlag,
+			utils.ECFIndexFlag,
+			utils.ECFPersistFlag,
+			utils.ECFRegistryFlag,
+		},
+	},
 	{
 		Name: "LOGGING AND DEBUGGING",
 		Flags: append([]cli.Flag{
@@ -27,6 +27,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ethereum/ethash"
 	"github.com/ethereum/go-ethereum/accounts"
@@ -232,10 +233,71 @@ var (
 		Name:  "vmdebug",
 		Usage: "Record information useful for VM and contract debugging",
 	}
+	// ECF checker settings
+	ECFCheckModeFlag = cli.StringFlag{
+		Name:  "ecfmode",
+		Usage: "How the ECF checker decides recursive subtraces (heuristic, exact, compare)",
+		Value: "heuristic",
+	}
+	ECFExactMaxSegmentsFlag = cli.IntFlag{
+		Name:  "ecfexactsegments",
+		Usage: "Largest recursive subtrace the exact ECF search attempts (0 = unlimited)",
+		Value: 64,
+	}
+	ECFExactTimeoutFlag = cli.DurationFlag{
+		Name:  "ecfexacttimeout",
+		Usage: "Time limit of the exact ECF search per recursive subtrace (0 = unlimited)",
+		Value: time.Second,
+	}
+	ECFMaxSegmentsFlag = cli.IntFlag{
+		Name:  "ecfmaxsegments",
+		Usage: "Most segments of a transaction the ECF checker checks, its verdict is inconclusive past that (0 = unlimited)",
+		Value: 10000,
+	}
+	ECFMaxLocationsFlag = cli.IntFlag{
+		Name:  "ecfmaxlocations",
+		Usage: "Most storage locations accessed by the segments of a transaction the ECF checker checks (0 = unlimited)",
+		Value: 100000,
+	}
+	ECFMaxCheckWorkFlag = cli.IntFlag{
+		Name:  "ecfmaxcheckwork",
+		Usage: "Most segments the ECF check of a transaction visits, its verdict is inconclusive past that (0 = unlimited)",
+		Value: 1000000,
+	}
+	ECFMaxCheckTimeFlag = cli.DurationFlag{
+		Name:  "ecfmaxchecktime",
+		Usage: "Time limit of the ECF check of a transaction, its verdict is inconclusive past that (0 = unlimited, verdicts then do not depend on the machine)",
+		Value: 0,
+	}
+	ECFAttributionFlag = cli.StringFlag{
+		Name:  "ecfattribution",
+		Usage: "What the ECF checker records about the code making each storage access (context, tagged, subsegments)",
+		Value: "context",
+	}
+	ECFNoStaticFlag = cli.BoolFlag{
+		Name:  "ecfnostatic",
+		Usage: "Check every contract, including those whose code cannot be harmed by a callback",
+	}
+	ECFLogsFlag = cli.BoolFlag{
+		Name:  "ecflogs",
+		Usage: "Treat emitted logs as writes to the emitting contract's event stream, so that callbacks may not reorder events",
+	}
+	ECFIndexFlag = cli.BoolFlag{
+		Name:  "ecfindex",
+		Usage: "Keep the ECF verdicts on imported and mined transactions in the chain database, for eth_getTransactionReceipt and eth_getBlockBy*",
+	}
+	ECFPersistFlag = cli.StringFlag{
+		Name:  "ecfpersist",
+		Usage: "Comma separated execution contexts whose ECF findings are stored (import, mine, pool-sim, call, trace, scan; default all)",
+	}
+	ECFRegistryFlag = cli.StringFlag{
+		Name:  "ecfregistry",
+		Usage: "Address of the ECFExemptions registry whose exempt methods' violations are marked exempt",
+	}
 	// Logging and debug settings
 	EthStatsURLFlag = cli.StringFlag{
 		Name:  "ethstats",
-		Usage: "Reporting URL of a ethstats service (nodename:secret@host:port)",
+		Usage: "Reporting URL of a ethstats service (nodename:secret@host:port, or nodename:secret@ws://host:port for a local one)",
 	}
 	MetricsEnabledFlag = cli.BoolFlag{
 		Name:  metrics.MetricsEnabledFlag,
@@ -823,6 +885,56 @@ func SetupNetwork(ctx *cli.Context) {
 	params.TargetGasLimit = common.String2Big(ctx.GlobalString(TargetGasLimitFlag.Name))
 }
 
+// SetupECFChecker configures the ECF checker from the command line. Flags which were not given leave the
+// environment based configuration of the checker in place.
+func SetupECFChecker(ctx *cli.Context) {
+	checker := vm.TheChecker()
+	if ctx.GlobalIsSet(ECFCheckModeFlag.Name) {
+		mode, err := vm.ParseECFCheckMode(ctx.GlobalString(ECFCheckModeFlag.Name))
+		if err != nil {
+			Fatalf("%v", err)
+		}
+		checker.SetMode(mode)
+	}
+	if ctx.GlobalIsSet(ECFExactMaxSegmentsFlag.Name) || ctx.GlobalIsSet(ECFExactTimeoutFlag.Name) {
+		checker.SetExactLimits(ctx.GlobalInt(ECFExactMaxSegmentsFlag.Name), ctx.GlobalDuration(ECFExactTimeoutFlag.Name))
+	}
+	if ctx.GlobalIsSet(ECFMaxSegmentsFlag.Name) || ctx.GlobalIsSet(ECFMaxLocationsFlag.Name) || ctx.GlobalIsSet(ECFMaxCheckWorkFlag.Name) || ctx.GlobalIsSet(ECFMaxCheckTimeFlag.Name) {
+		checker.SetLimits(ctx.GlobalInt(ECFMaxSegmentsFlag.Name), ctx.GlobalInt(ECFMaxLocationsFlag.Name), ctx.GlobalInt(ECFMaxCheckWorkFlag.Name), ctx.GlobalDuration(ECFMaxCheckTimeFlag.Name))
+	}
+	if ctx.GlobalIsSet(ECFAttributionFlag.Name) {
+		attribution, err := vm.ParseStorageAttribution(ctx.GlobalString(ECFAttributionFlag.Name))
+		if err != nil {
+			Fatalf("%v", err)
+		}
+		checker.SetAttribution(attribution)
+	}
+	if ctx.GlobalBool(ECFNoStaticFlag.Name) {
+		checker.SetStaticFastPath(false)
+	}
+	if ctx.GlobalBool(ECFLogsFlag.Name) {
+		checker.SetLogsAsWrites(true)
+	}
+	if ctx.GlobalBool(ECFIndexFlag.Name) {
+		checker.SetVerdictIndex(true)
+	}
+	if ctx.GlobalIsSet(ECFPersistFlag.Name) {
+		contexts, err := vm.ParseExecutionContexts(ctx.GlobalString(ECFPersistFlag.Name))
+		if err != nil {
+			Fatalf("%v", err)
+		}
+		checker.SetPersistedContexts(contexts)
+	}
+	if ctx.GlobalIsSet(ECFRegistryFlag.Name) {
+		registryStr := ctx.GlobalString(ECFRegistryFlag.Name)
+		if !common.IsHexAddress(registryStr) {
+			Fatalf("Invalid ECF exemption registry address %q", registryStr)
+		}
+		registry := common.HexToAddress(registryStr)
+		checker.SetExemptionRegistry(&registry)
+	}
+}
+
 // MakeChainConfig reads the chain configuration from the database in ctx.Datadir.
 func MakeChainConfig(ctx *cli.Context, stack *node.Node) *params.ChainConfig {
 	db := MakeChainDatabase(ctx, stack)
@@ -927,6 +1039,7 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	chain, err = core.NewBlockChain(chainDb, chainConfig, pow, new(event.TypeMux), vm.Config{EnablePreimageRecording: ctx.GlobalBool(VMEnableDebugFlag.Name)})
 	fmt.Println("Setting DB handler on blockchain")
 	chain.DbHandler = stack.DbHandler()
+	vm.TheChecker().SetCodeTraitsDatabase(chainDb)
 	if err != nil {
 		Fatalf("Could not start chainmanager: %v", err)
 	}
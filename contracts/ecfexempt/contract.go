@@ -0,0 +1,207 @@
+// This file is an automatically generated Go binding. Do not modify as any
+// change will likely be lost upon the next re-generation!
+
+package ecfexempt
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ECFExemptionsABI is the input ABI used to generate the binding from.
+const ECFExemptionsABI = "[{\"constant\":false,\"inputs\":[{\"name\":\"target\",\"type\":\"address\"},{\"name\":\"selector\",\"type\":\"bytes4\"},{\"name\":\"expiry\",\"type\":\"uint256\"}],\"name\":\"exempt\",\"outputs\":[],\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"\",\"type\":\"address\"},{\"name\":\"\",\"type\":\"bytes4\"}],\"name\":\"expiries\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"type\":\"function\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"name\":\"target\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"selector\",\"type\":\"bytes4\"},{\"indexed\":false,\"name\":\"expiry\",\"type\":\"uint256\"}],\"name\":\"Exempted\",\"type\":\"event\"}]"
+
+// ECFExemptionsBin is the compiled bytecode used for deploying new contracts.
+const ECFExemptionsBin = `0x6101618061000d6000396000f360043573ffffffffffffffffffffffffffffffffffffffff1680600052600060205260406000206020526024357fffffffff000000000000000000000000000000000000000000000000000000001660005260406000206000357c010000000000000000000000000000000000000000000000000000000090048063ba2b348c1461009c57806310c48dcb1461009157fe5b505460005260206000f35b5081331461010657813b1561010457638da5cb5b7c01000000000000000000000000000000000000000000000000000000000260005260206000600460006000865af1156101045760005173ffffffffffffffffffffffffffffffffffffffff163314610106575bfe5b60443590556024357fffffffff00000000000000000000000000000000000000000000000000000000166000526044356020527f7e074a463047764f1dedfde002f714986fd2d90ae51671e3535e287955dda5f560406000a200`
+
+// DeployECFExemptions deploys a new Ethereum contract, binding an instance of ECFExemptions to it.
+func DeployECFExemptions(auth *bind.TransactOpts, backend bind.ContractBackend) (common.Address, *types.Transaction, *ECFExemptions, error) {
+	parsed, err := abi.JSON(strings.NewReader(ECFExemptionsABI))
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	address, tx, contract, err := bind.DeployContract(auth, parsed, common.FromHex(ECFExemptionsBin), backend)
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	return address, tx, &ECFExemptions{ECFExemptionsCaller: ECFExemptionsCaller{contract: contract}, ECFExemptionsTransactor: ECFExemptionsTransactor{contract: contract}}, nil
+}
+
+// ECFExemptions is an auto generated Go binding around an Ethereum contract.
+type ECFExemptions struct {
+	ECFExemptionsCaller     // Read-only binding to the contract
+	ECFExemptionsTransactor // Write-only binding to the contract
+}
+
+// ECFExemptionsCaller is an auto generated read-only Go binding around an Ethereum contract.
+type ECFExemptionsCaller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// ECFExemptionsTransactor is an auto generated write-only Go binding around an Ethereum contract.
+type ECFExemptionsTransactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// ECFExemptionsSession is an auto generated Go binding around an Ethereum contract,
+// with pre-set call and transact options.
+type ECFExemptionsSession struct {
+	Contract     *ECFExemptions    // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts     // Call options to use throughout this session
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// ECFExemptionsCallerSession is an auto generated read-only Go binding around an Ethereum contract,
+// with pre-set call options.
+type ECFExemptionsCallerSession struct {
+	Contract *ECFExemptionsCaller // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts        // Call options to use throughout this session
+}
+
+// ECFExemptionsTransactorSession is an auto generated write-only Go binding around an Ethereum contract,
+// with pre-set transact options.
+type ECFExemptionsTransactorSession struct {
+	Contract     *ECFExemptionsTransactor // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts        // Transaction auth options to use throughout this session
+}
+
+// ECFExemptionsRaw is an auto generated low-level Go binding around an Ethereum contract.
+type ECFExemptionsRaw struct {
+	Contract *ECFExemptions // Generic contract binding to access the raw methods on
+}
+
+// ECFExemptionsCallerRaw is an auto generated low-level read-only Go binding around an Ethereum contract.
+type ECFExemptionsCallerRaw struct {
+	Contract *ECFExemptionsCaller // Generic read-only contract binding to access the raw methods on
+}
+
+// ECFExemptionsTransactorRaw is an auto generated low-level write-only Go binding around an Ethereum contract.
+type ECFExemptionsTransactorRaw struct {
+	Contract *ECFExemptionsTransactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewECFExemptions creates a new instance of ECFExemptions, bound to a specific deployed contract.
+func NewECFExemptions(address common.Address, backend bind.ContractBackend) (*ECFExemptions, error) {
+	contract, err := bindECFExemptions(address, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &ECFExemptions{ECFExemptionsCaller: ECFExemptionsCaller{contract: contract}, ECFExemptionsTransactor: ECFExemptionsTransactor{contract: contract}}, nil
+}
+
+// NewECFExemptionsCaller creates a new read-only instance of ECFExemptions, bound to a specific deployed contract.
+func NewECFExemptionsCaller(address common.Address, caller bind.ContractCaller) (*ECFExemptionsCaller, error) {
+	contract, err := bindECFExemptions(address, caller, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &ECFExemptionsCaller{contract: contract}, nil
+}
+
+// NewECFExemptionsTransactor creates a new write-only instance of ECFExemptions, bound to a specific deployed contract.
+func NewECFExemptionsTransactor(address common.Address, transactor bind.ContractTransactor) (*ECFExemptionsTransactor, error) {
+	contract, err := bindECFExemptions(address, nil, transactor)
+	if err != nil {
+		return nil, err
+	}
+	return &ECFExemptionsTransactor{contract: contract}, nil
+}
+
+// bindECFExemptions binds a generic wrapper to an already deployed contract.
+func bindECFExemptions(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor) (*bind.BoundContract, error) {
+	parsed, err := abi.JSON(strings.NewReader(ECFExemptionsABI))
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, parsed, caller, transactor), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_ECFExemptions *ECFExemptionsRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+	return _ECFExemptions.Contract.ECFExemptionsCaller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_ECFExemptions *ECFExemptionsRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _ECFExemptions.Contract.ECFExemptionsTransactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_ECFExemptions *ECFExemptionsRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _ECFExemptions.Contract.ECFExemptionsTransactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_ECFExemptions *ECFExemptionsCallerRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+	return _ECFExemptions.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_ECFExemptions *ECFExemptionsTransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _ECFExemptions.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_ECFExemptions *ECFExemptionsTransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _ECFExemptions.Contract.contract.Transact(opts, method, params...)
+}
+
+// Expiries is a free data retrieval call binding the contract method 0x10c48dcb.
+//
+// Solidity: function expiries( address,  bytes4) constant returns(uint256)
+func (_ECFExemptions *ECFExemptionsCaller) Expiries(opts *bind.CallOpts, arg0 common.Address, arg1 [4]byte) (*big.Int, error) {
+	var (
+		ret0 = new(*big.Int)
+	)
+	out := ret0
+	err := _ECFExemptions.contract.Call(opts, out, "expiries", arg0, arg1)
+	return *ret0, err
+}
+
+// Expiries is a free data retrieval call binding the contract method 0x10c48dcb.
+//
+// Solidity: function expiries( address,  bytes4) constant returns(uint256)
+func (_ECFExemptions *ECFExemptionsSession) Expiries(arg0 common.Address, arg1 [4]byte) (*big.Int, error) {
+	return _ECFExemptions.Contract.Expiries(&_ECFExemptions.CallOpts, arg0, arg1)
+}
+
+// Expiries is a free data retrieval call binding the contract method 0x10c48dcb.
+//
+// Solidity: function expiries( address,  bytes4) constant returns(uint256)
+func (_ECFExemptions *ECFExemptionsCallerSession) Expiries(arg0 common.Address, arg1 [4]byte) (*big.Int, error) {
+	return _ECFExemptions.Contract.Expiries(&_ECFExemptions.CallOpts, arg0, arg1)
+}
+
+// Exempt is a paid mutator transaction binding the contract method 0xba2b348c.
+//
+// Solidity: function exempt(target address, selector bytes4, expiry uint256) returns()
+func (_ECFExemptions *ECFExemptionsTransactor) Exempt(opts *bind.TransactOpts, target common.Address, selector [4]byte, expiry *big.Int) (*types.Transaction, error) {
+	return _ECFExemptions.contract.Transact(opts, "exempt", target, selector, expiry)
+}
+
+// Exempt is a paid mutator transaction binding the contract method 0xba2b348c.
+//
+// Solidity: function exempt(target address, selector bytes4, expiry uint256) returns()
+func (_ECFExemptions *ECFExemptionsSession) Exempt(target common.Address, selector [4]byte, expiry *big.Int) (*types.Transaction, error) {
+	return _ECFExemptions.Contract.Exempt(&_ECFExemptions.TransactOpts, target, selector, expiry)
+}
+
+// Exempt is a paid mutator transaction binding the contract method 0xba2b348c.
+//
+// Solidity: function exempt(target address, selector bytes4, expiry uint256) returns()
+func (_ECFExemptions *ECFExemptionsTransactorSession) Exempt(target common.Address, selector [4]byte, expiry *big.Int) (*types.Transaction, error) {
+	return _ECFExemptions.Contract.Exempt(&_ECFExemptions.TransactOpts, target, selector, expiry)
+}
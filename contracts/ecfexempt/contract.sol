@@ -0,0 +1,35 @@
+// Shelly
+
+pragma solidity ^0.4.10;
+
+// Owned is the part of a contract telling who owns it.
+contract Owned {
+  function owner() constant returns (address);
+}
+
+// ECFExemptions is a registry of the methods of contracts that are re-entrant on
+// purpose, e.g. tokens calling hooks of their holders or oracles calling back
+// their clients. Nodes consulting the registry still report the violations of an
+// exempt method, but mark them as exempt, so that they do not make a transaction
+// non ECF.
+//
+// The ECF checker of the nodes reads expiries from storage directly, so its
+// layout must not change.
+contract ECFExemptions {
+  // The last block each method of each contract is exempt in, 0 if it is not
+  mapping (address => mapping (bytes4 => uint)) public expiries;
+
+  // Fired when the exemption of a method changes, an expiry of 0 revoking it
+  event Exempted(address indexed target, bytes4 selector, uint expiry);
+
+  // exempt sets the last block the method of the target contract is exempt in,
+  // or revokes its exemption if expiry is 0. Only the contract itself or its
+  // owner() may do so.
+  function exempt(address target, bytes4 selector, uint expiry) {
+    if (msg.sender != target && msg.sender != Owned(target).owner()) {
+      throw;
+    }
+    expiries[target][selector] = expiry;
+    Exempted(target, selector, expiry);
+  }
+}
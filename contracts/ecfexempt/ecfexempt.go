@@ -0,0 +1,78 @@
+// Shelly
+
+// Package ecfexempt contains the Go bindings of the ECFExemptions registry, whose
+// exempt methods the ECF checker reports as exempt rather than as plain violations.
+package ecfexempt
+
+//go:generate abigen --sol ./contract.sol --pkg ecfexempt --out ./contract.go
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Registry manages the exemptions of an ECFExemptions registry
+type Registry struct {
+	*ECFExemptionsSession
+	contractBackend bind.ContractBackend
+}
+
+// NewRegistry creates a struct exposing the exemptions of the registry at the given address.
+func NewRegistry(transactOpts *bind.TransactOpts, registryAddr common.Address, contractBackend bind.ContractBackend) (*Registry, error) {
+	registry, err := NewECFExemptions(registryAddr, contractBackend)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Registry{
+		&ECFExemptionsSession{
+			Contract:     registry,
+			TransactOpts: *transactOpts,
+		},
+		contractBackend,
+	}, nil
+}
+
+// DeployRegistry deploys a new ECFExemptions registry.
+func DeployRegistry(transactOpts *bind.TransactOpts, contractBackend bind.ContractBackend) (common.Address, *Registry, error) {
+	registryAddr, _, _, err := DeployECFExemptions(transactOpts, contractBackend)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	registry, err := NewRegistry(transactOpts, registryAddr, contractBackend)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	return registryAddr, registry, nil
+}
+
+// Selector returns the selector of a method given by its signature, e.g. "transfer(address,uint256)".
+func Selector(signature string) [4]byte {
+	var selector [4]byte
+	copy(selector[:], crypto.Keccak256([]byte(signature)))
+	return selector
+}
+
+// ExemptUntil exempts the method of the target contract up to and including the given block. The transaction must
+// be sent by the target contract's owner().
+func (self *Registry) ExemptUntil(target common.Address, selector [4]byte, block uint64) (*types.Transaction, error) {
+	return self.Exempt(target, selector, new(big.Int).SetUint64(block))
+}
+
+// Revoke ends the exemption of the method of the target contract.
+func (self *Registry) Revoke(target common.Address, selector [4]byte) (*types.Transaction, error) {
+	return self.Exempt(target, selector, new(big.Int))
+}
+
+// Expiry returns the last block the method of the target contract is exempt in, or 0 if it is not exempt.
+func (self *Registry) Expiry(target common.Address, selector [4]byte) (uint64, error) {
+	expiry, err := self.Expiries(target, selector)
+	if err != nil {
+		return 0, err
+	}
+	return expiry.Uint64(), nil
+}
@@ -0,0 +1,78 @@
+// Shelly
+
+package ecfexempt
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/net/context"
+)
+
+var (
+	key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	addr   = crypto.PubkeyToAddress(key.PublicKey)
+)
+
+// Tests that a contract exempts its own methods, that the expiries are stored where the checker reads them, and that
+// nobody else may exempt them.
+func TestRegistry(t *testing.T) {
+	contractBackend := backends.NewSimulatedBackend(core.GenesisAccount{Address: addr, Balance: big.NewInt(1000000000)})
+	transactOpts := bind.NewKeyedTransactor(key)
+
+	registryAddr, registry, err := DeployRegistry(transactOpts, contractBackend)
+	if err != nil {
+		t.Fatalf("can't deploy registry: %v", err)
+	}
+	contractBackend.Commit()
+
+	selector := Selector("transfer(address,uint256)")
+	if _, err := registry.ExemptUntil(addr, selector, 100); err != nil {
+		t.Fatalf("can't exempt: %v", err)
+	}
+	contractBackend.Commit()
+
+	expiry, err := registry.Expiry(addr, selector)
+	if err != nil {
+		t.Fatalf("can't read expiry: %v", err)
+	}
+	if expiry != 100 {
+		t.Fatalf("expiry %d, want 100", expiry)
+	}
+	stored, err := contractBackend.StorageAt(context.Background(), registryAddr, vm.ExemptionSlot(addr, selector), nil)
+	if err != nil {
+		t.Fatalf("can't read storage: %v", err)
+	}
+	if new(big.Int).SetBytes(stored).Uint64() != 100 {
+		t.Fatalf("expiry stored as %x at the checker's slot, want 100", stored)
+	}
+	if other, _ := registry.Expiry(addr, Selector("approve(address,uint256)")); other != 0 {
+		t.Errorf("other method exempt until %d", other)
+	}
+
+	// Exempting another account's methods calls its owner(), which it lacks, so the transaction fails
+	other := common.HexToAddress("0x0102")
+	registry.TransactOpts.GasLimit = big.NewInt(200000)
+	if _, err := registry.ExemptUntil(other, selector, 100); err != nil {
+		t.Fatalf("can't send exemption: %v", err)
+	}
+	contractBackend.Commit()
+	registry.TransactOpts.GasLimit = nil
+	if expiry, _ := registry.Expiry(other, selector); expiry != 0 {
+		t.Errorf("exempted the method of another account until %d", expiry)
+	}
+
+	if _, err := registry.Revoke(addr, selector); err != nil {
+		t.Fatalf("can't revoke: %v", err)
+	}
+	contractBackend.Commit()
+	if expiry, _ := registry.Expiry(addr, selector); expiry != 0 {
+		t.Errorf("revoked method exempt until %d", expiry)
+	}
+}
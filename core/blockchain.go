@@ -264,6 +264,7 @@ func (bc *BlockChain) SetHead(head uint64) {
 	if bc.currentFastBlock == nil {
 		bc.currentFastBlock = bc.genesisBlock
 	}
+	vm.TheChecker().MarkRewound(bc.currentBlock.NumberU64())
 
 	if err := WriteHeadBlockHash(bc.chainDb, bc.currentBlock.Hash()); err != nil {
 		glog.Fatalf("failed to reset head block hash: %v", err)
@@ -854,6 +855,7 @@ func (self *BlockChain) WriteBlock(block *types.Block) (status WriteStatus, err
 	} else {
 		status = SideStatTy
 	}
+	vm.TheChecker().MarkBlock(block.Hash(), status == CanonStatTy)
 
 	self.futureBlocks.Remove(block.Hash())
 
@@ -976,7 +978,7 @@ func (self *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 			return i, err
 		}
 		// Process block using the parent state as reference point.
-		receipts, logs, usedGas, err := self.processor.Process(block, self.stateCache, self.vmConfig)
+		receipts, logs, usedGas, ecfResults, err := self.processor.Process(block, self.stateCache, self.vmConfig)
 		if err != nil {
 			self.reportBlock(block, receipts, err)
 			return i, err
@@ -999,6 +1001,12 @@ func (self *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 		if err := WriteBlockReceipts(self.chainDb, block.Hash(), block.NumberU64(), receipts); err != nil {
 			return i, err
 		}
+		verdicts := BlockECFVerdicts(block, ecfResults)
+		if verdicts != nil {
+			if err := WriteBlockECFVerdicts(self.chainDb, block.Hash(), block.NumberU64(), verdicts); err != nil {
+				return i, err
+			}
+		}
 
 		// write the block to the chain and get the status
 		status, err := self.WriteBlock(block)
@@ -1022,6 +1030,10 @@ func (self *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 			if err := WriteReceipts(self.chainDb, receipts); err != nil {
 				return i, err
 			}
+			// index the ECF verdicts by transaction
+			if err := WriteECFVerdicts(self.chainDb, block, verdicts); err != nil {
+				return i, err
+			}
 			// Write map map bloom filters
 			if err := WriteMipmapBloom(self.chainDb, block.NumberU64(), receipts); err != nil {
 				return i, err
@@ -1040,6 +1052,7 @@ func (self *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 		case SplitStatTy:
 			events = append(events, ChainSplitEvent{block, logs})
 		}
+		vm.TheChecker().BlockChecked(block.NumberU64())
 
 		stats.processed++
 		if glog.V(logger.Info) {
@@ -1183,6 +1196,14 @@ func (self *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 			newFirst.Hash().Bytes()[:4], newLast.Hash().Bytes()[:4])
 	}
 
+	// Move the ECF violations found in the blocks along with them
+	for _, block := range oldChain {
+		vm.TheChecker().MarkBlock(block.Hash(), false)
+	}
+	for _, block := range newChain {
+		vm.TheChecker().MarkBlock(block.Hash(), true)
+	}
+
 	var addedTxs types.Transactions
 	// insert blocks. Order does not matter. Last block will be written in ImportChain itself which creates the new head properly
 	for _, block := range newChain {
@@ -1197,6 +1218,10 @@ func (self *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		if err := WriteReceipts(self.chainDb, receipts); err != nil {
 			return err
 		}
+		// index the ECF verdicts by transaction
+		if err := WriteECFVerdicts(self.chainDb, block, GetBlockECFVerdicts(self.chainDb, block.Hash(), block.NumberU64())); err != nil {
+			return err
+		}
 		// Write map map bloom filters
 		if err := WriteMipmapBloom(self.chainDb, block.NumberU64(), receipts); err != nil {
 			return err
@@ -1210,6 +1235,7 @@ func (self *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 	// receipts that were created in the fork must also be deleted
 	for _, tx := range diff {
 		DeleteReceipt(self.chainDb, tx.Hash())
+		DeleteECFVerdict(self.chainDb, tx.Hash())
 		DeleteTransaction(self.chainDb, tx.Hash())
 	}
 	// Must be posted in a goroutine because of the transaction pool trying
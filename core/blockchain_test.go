@@ -141,7 +141,7 @@ func testBlockChainImport(chain types.Blocks, blockchain *BlockChain) error {
 		if err != nil {
 			return err
 		}
-		receipts, _, usedGas, err := blockchain.Processor().Process(block, statedb, vm.Config{})
+		receipts, _, usedGas, _, err := blockchain.Processor().Process(block, statedb, vm.Config{})
 		if err != nil {
 			blockchain.reportBlock(block, receipts, err)
 			return err
@@ -435,8 +435,8 @@ func (bproc) ValidateHeader(*types.Header, *types.Header, bool) error { return n
 func (bproc) ValidateState(block, parent *types.Block, state *state.StateDB, receipts types.Receipts, usedGas *big.Int) error {
 	return nil
 }
-func (bproc) Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, *big.Int, error) {
-	return nil, nil, new(big.Int), nil
+func (bproc) Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, *big.Int, []*vm.ECFResultSlot, error) {
+	return nil, nil, new(big.Int), nil, nil
 }
 
 func makeHeaderChainWithDiff(genesis *types.Block, d []int, seed byte) []*types.Header {
@@ -875,6 +875,10 @@ func TestChainTxReorgs(t *testing.T) {
 		db, _   = ethdb.NewMemDatabase()
 		signer  = types.NewEIP155Signer(big.NewInt(1))
 	)
+	// The ECF verdicts follow the transactions
+	defer vm.TheChecker().SetVerdictIndex(vm.TheChecker().VerdictIndex())
+	vm.TheChecker().SetVerdictIndex(true)
+
 	genesis := WriteGenesisBlockForTesting(db,
 		GenesisAccount{addr1, big.NewInt(1000000)},
 		GenesisAccount{addr2, big.NewInt(1000000)},
@@ -952,6 +956,9 @@ func TestChainTxReorgs(t *testing.T) {
 		if GetReceipt(db, tx.Hash()) != nil {
 			t.Errorf("drop %d: receipt found while shouldn't have been", i)
 		}
+		if GetECFVerdict(db, tx.Hash()) != nil {
+			t.Errorf("drop %d: ECF verdict found while shouldn't have been", i)
+		}
 	}
 	// added tx
 	for i, tx := range (types.Transactions{pastAdd, freshAdd, futureAdd}) {
@@ -961,6 +968,9 @@ func TestChainTxReorgs(t *testing.T) {
 		if GetReceipt(db, tx.Hash()) == nil {
 			t.Errorf("add %d: expected receipt to be found", i)
 		}
+		if GetECFVerdict(db, tx.Hash()) == nil {
+			t.Errorf("add %d: expected ECF verdict to be found", i)
+		}
 	}
 	// shared tx
 	for i, tx := range (types.Transactions{postponed, swapped}) {
@@ -970,6 +980,9 @@ func TestChainTxReorgs(t *testing.T) {
 		if GetReceipt(db, tx.Hash()) == nil {
 			t.Errorf("share %d: expected receipt to be found", i)
 		}
+		if GetECFVerdict(db, tx.Hash()) == nil {
+			t.Errorf("share %d: expected ECF verdict to be found", i)
+		}
 	}
 }
 
@@ -69,10 +69,11 @@ type BlockGen struct {
 	header  *types.Header
 	statedb *state.StateDB
 
-	gasPool  *GasPool
-	txs      []*types.Transaction
-	receipts []*types.Receipt
-	uncles   []*types.Header
+	gasPool    *GasPool
+	txs        []*types.Transaction
+	receipts   []*types.Receipt
+	ecfResults []*vm.ECFResultSlot
+	uncles     []*types.Header
 
 	config *params.ChainConfig
 }
@@ -108,12 +109,20 @@ func (b *BlockGen) AddTx(tx *types.Transaction) {
 		b.SetCoinbase(common.Address{})
 	}
 	b.statedb.StartRecord(tx.Hash(), common.Hash{}, len(b.txs))
-	receipt, _, err := ApplyTransaction(b.config, nil, b.gasPool, b.statedb, b.header, tx, b.header.GasUsed, vm.Config{})
+	ecfResult := new(vm.ECFResultSlot)
+	receipt, _, err := ApplyTransaction(b.config, nil, b.gasPool, b.statedb, b.header, tx, b.header.GasUsed, vm.Config{ECFContext: vm.ExecPoolSim, ECFResult: ecfResult})
 	if err != nil {
 		panic(err)
 	}
 	b.txs = append(b.txs, tx)
 	b.receipts = append(b.receipts, receipt)
+	b.ecfResults = append(b.ecfResults, ecfResult)
+}
+
+// ECFResults returns the results of checking the transactions added so far, in
+// their order.
+func (b *BlockGen) ECFResults() []*vm.ECFResultSlot {
+	return b.ecfResults
 }
 
 // Number returns the block number of the block being generated.
@@ -51,6 +51,10 @@ var (
 	txMetaSuffix   = []byte{0x01}
 	receiptsPrefix = []byte("receipts-")
 
+	// Not part of consensus, see ECFVerdict
+	ecfVerdictPrefix       = []byte("ecf-verdict-")        // ecfVerdictPrefix + tx hash -> ECF verdict, canonical transactions only
+	blockECFVerdictsPrefix = []byte("ecf-block-verdicts-") // blockECFVerdictsPrefix + num (uint64 big endian) + hash -> ECF verdicts
+
 	mipmapPre    = []byte("mipmap-log-bloom-")
 	MIPMapLevels = []uint64{1000000, 500000, 100000, 50000, 1000}
 
@@ -519,6 +523,7 @@ func DeleteTd(db ethdb.Database, hash common.Hash, number uint64) {
 // DeleteBlock removes all block data associated with a hash.
 func DeleteBlock(db ethdb.Database, hash common.Hash, number uint64) {
 	DeleteBlockReceipts(db, hash, number)
+	DeleteBlockECFVerdicts(db, hash, number)
 	DeleteHeader(db, hash, number)
 	DeleteBody(db, hash, number)
 	DeleteTd(db, hash, number)
@@ -22,10 +22,12 @@ import (
 	"io/ioutil"
 	"math/big"
 	"os"
+	"reflect"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/crypto/sha3"
 	"github.com/ethereum/go-ethereum/ethdb"
@@ -500,6 +502,79 @@ func TestBlockReceiptStorage(t *testing.T) {
 	}
 }
 
+// Tests that the ECF verdicts on a block's transactions can be stored, indexed by transaction and deleted.
+func TestECFVerdictStorage(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+
+	checker := vm.TheChecker()
+	defer checker.SetVerdictIndex(checker.VerdictIndex())
+	checker.SetVerdictIndex(true)
+
+	contract, balance := common.BytesToAddress([]byte{0xaa}), common.BytesToHash([]byte{0x01})
+	nonECF, err := vm.Trace().
+		Call(contract).Read(balance).
+		Call(common.BytesToAddress([]byte{0xbb})).
+		Call(contract).Read(balance).Write(balance).Ret().
+		Ret().
+		Write(balance).Ret().Check(nil, vm.ECFModeHeuristic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txs := []*types.Transaction{
+		types.NewTransaction(1, common.BytesToAddress([]byte{0xaa}), big.NewInt(0), big.NewInt(0), big.NewInt(0), []byte{0xec, 0xf1}),
+		types.NewTransaction(2, common.BytesToAddress([]byte{0x22}), big.NewInt(1), big.NewInt(0), big.NewInt(0), []byte{0xec, 0xf2}),
+	}
+	block := types.NewBlock(&types.Header{Number: big.NewInt(314)}, txs, nil, nil)
+
+	// A block is only indexed if the checker followed all of its transactions, whatever other runs of them recorded
+	results := []*vm.ECFResultSlot{{Result: nonECF, Checked: true}, {}}
+	checker.RecordTransactionResult(txs[1].Hash(), nil)
+	if verdicts := BlockECFVerdicts(block, results); verdicts != nil {
+		t.Fatalf("verdicts of a partially checked block returned: %v", verdicts)
+	}
+	if verdicts := BlockECFVerdicts(block, results[:1]); verdicts != nil {
+		t.Fatalf("verdicts of a block with missing results returned: %v", verdicts)
+	}
+	results[1].Checked = true
+	verdicts := BlockECFVerdicts(block, results)
+	want := []*ECFVerdict{
+		{Mode: "heuristic", ECF: false, Segments: 5, Violations: []common.Address{contract}},
+		{ECF: true, Violations: []common.Address{}},
+	}
+	if !reflect.DeepEqual(verdicts, want) {
+		t.Fatalf("verdicts mismatch: have %+v %+v, want %+v %+v", verdicts[0], verdicts[1], want[0], want[1])
+	}
+
+	// Store them with the block, then index them by transaction
+	if err := WriteBlockECFVerdicts(db, block.Hash(), block.NumberU64(), verdicts); err != nil {
+		t.Fatalf("failed to write block verdicts: %v", err)
+	}
+	if have := GetBlockECFVerdicts(db, block.Hash(), block.NumberU64()); !reflect.DeepEqual(have, want) {
+		t.Fatalf("stored verdicts mismatch: have %v, want %v", have, want)
+	}
+	if verdict := GetECFVerdict(db, txs[0].Hash()); verdict != nil {
+		t.Fatalf("verdict of a non canonical transaction returned: %v", verdict)
+	}
+	if err := WriteECFVerdicts(db, block, verdicts); err != nil {
+		t.Fatalf("failed to index verdicts: %v", err)
+	}
+	for i, tx := range txs {
+		if verdict := GetECFVerdict(db, tx.Hash()); !reflect.DeepEqual(verdict, want[i]) {
+			t.Fatalf("verdict #%d mismatch: have %v, want %v", i, verdict, want[i])
+		}
+	}
+
+	// Delete them and check purge
+	DeleteECFVerdict(db, txs[0].Hash())
+	if verdict := GetECFVerdict(db, txs[0].Hash()); verdict != nil {
+		t.Fatalf("deleted verdict returned: %v", verdict)
+	}
+	DeleteBlock(db, block.Hash(), block.NumberU64())
+	if verdicts := GetBlockECFVerdicts(db, block.Hash(), block.NumberU64()); verdicts != nil {
+		t.Fatalf("deleted block verdicts returned: %v", verdicts)
+	}
+}
+
 func TestMipmapBloom(t *testing.T) {
 	db, _ := ethdb.NewMemDatabase()
 
@@ -0,0 +1,70 @@
+// Shelly
+
+package core
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+)
+
+// RecheckECF checks again the canonical blocks imported after the last checked block stored in ecf.db, whose findings
+// a crash may have lost (see vm.Checker.BlockChecked). Their stored findings are dropped first, so none is stored
+// twice. A database without a checked block, e.g. a new one, is checkpointed at the current head. It is called on
+// startup, before blocks are imported.
+func (self *BlockChain) RecheckECF() error {
+	checker := vm.TheChecker()
+	checked, ok, err := checker.LastCheckedBlock()
+	if err != nil {
+		return err
+	}
+	head := self.CurrentBlock().NumberU64()
+	if !ok {
+		checker.BlockChecked(head)
+		return nil
+	}
+	if checked >= head {
+		return nil
+	}
+	if !checker.Enabled() {
+		glog.V(logger.Warn).Infof("ECF checker disabled, blocks #%d to #%d are not checked again", checked+1, head)
+		return nil
+	}
+	glog.V(logger.Info).Infof("Checking again blocks #%d to #%d for ECF", checked+1, head)
+	for number := checked + 1; number <= head; number++ {
+		block := self.GetBlockByNumber(number)
+		if block == nil {
+			return fmt.Errorf("canonical block #%d not found", number)
+		}
+		parent := self.GetBlock(block.ParentHash(), number-1)
+		if parent == nil {
+			return ParentError(block.ParentHash())
+		}
+		statedb, err := state.New(parent.Root(), self.chainDb)
+		if err != nil {
+			// The state of fast synced blocks is not kept, they were not checked either
+			glog.V(logger.Debug).Infof("block #%d not checked again, no state: %v", number, err)
+			checker.BlockChecked(number)
+			continue
+		}
+		checker.ForgetBlockFindings(block.Hash())
+		_, _, _, ecfResults, err := self.processor.Process(block, statedb, self.vmConfig)
+		if err != nil {
+			return fmt.Errorf("block #%d: %v", number, err)
+		}
+		checker.MarkBlock(block.Hash(), true)
+		if verdicts := BlockECFVerdicts(block, ecfResults); verdicts != nil {
+			if err := WriteBlockECFVerdicts(self.chainDb, block.Hash(), number, verdicts); err != nil {
+				return err
+			}
+			if err := WriteECFVerdicts(self.chainDb, block, verdicts); err != nil {
+				return err
+			}
+		}
+		checker.BlockChecked(number)
+	}
+	return checker.SyncFindings()
+}
@@ -0,0 +1,135 @@
+// Shelly
+
+package core
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// ECFVerdict is the checker's verdict on a transaction of a block, kept in the chain database when the verdict index
+// is on (see vm.Checker.SetVerdictIndex) for the RPC APIs. It is not part of consensus: nodes checking with other
+// settings, or not at all, keep other verdicts or none.
+type ECFVerdict struct {
+	Mode         string           `json:"mode"`                   // Empty if the transaction ran no code
+	ECF          bool             `json:"ecf"`                    // Conclusively free of non-exempt violations
+	Inconclusive string           `json:"inconclusive,omitempty"` // The limit exceeded, if the checker gave up
+	Segments     uint64           `json:"segments"`
+	Violations   []common.Address `json:"violations"` // The contracts with non-exempt violations, in the order found
+}
+
+// newECFVerdict summarizes the result of checking a transaction. A transaction that ran no code has a nil result and
+// gets an ECF verdict with an empty mode.
+func newECFVerdict(result *vm.ECFResult) *ECFVerdict {
+	verdict := &ECFVerdict{ECF: true, Violations: make([]common.Address, 0)}
+	if result == nil {
+		return verdict
+	}
+	verdict.Mode = result.Mode.String()
+	verdict.ECF = result.IsECF() && result.IsConclusive()
+	verdict.Inconclusive = result.Inconclusive
+	verdict.Segments = uint64(result.Segments)
+	seen := make(map[common.Address]bool)
+	for _, violation := range result.NonExempt() {
+		if !seen[violation.Contract] {
+			seen[violation.Contract] = true
+			verdict.Violations = append(verdict.Violations, violation.Contract)
+		}
+	}
+	return verdict
+}
+
+// BlockECFVerdicts returns the verdicts on the transactions of a block just processed or mined, given the results of
+// its own run of each transaction (see StateProcessor.Process). It returns nil if the index is off or the checker did
+// not follow all of them, e.g. because it was disabled while the block was processed.
+func BlockECFVerdicts(block *types.Block, results []*vm.ECFResultSlot) []*ECFVerdict {
+	if !vm.TheChecker().VerdictIndex() {
+		return nil
+	}
+	txs := block.Transactions()
+	if len(results) != len(txs) {
+		glog.V(logger.Debug).Infof("block #%d [%x…] not indexed, %d results for %d transactions", block.Number(), block.Hash().Bytes()[:4], len(results), len(txs))
+		return nil
+	}
+	verdicts := make([]*ECFVerdict, len(txs))
+	for i, tx := range txs {
+		if results[i] == nil || !results[i].Checked {
+			glog.V(logger.Debug).Infof("block #%d [%x…] not indexed, transaction %x was not checked", block.Number(), block.Hash().Bytes()[:4], tx.Hash())
+			return nil
+		}
+		verdicts[i] = newECFVerdict(results[i].Result)
+	}
+	return verdicts
+}
+
+// WriteBlockECFVerdicts stores the verdicts on the transactions of a block, in their order
+func WriteBlockECFVerdicts(db ethdb.Database, hash common.Hash, number uint64, verdicts []*ECFVerdict) error {
+	data, err := rlp.EncodeToBytes(verdicts)
+	if err != nil {
+		return err
+	}
+	return db.Put(append(append(blockECFVerdictsPrefix, encodeBlockNumber(number)...), hash.Bytes()...), data)
+}
+
+// GetBlockECFVerdicts returns the verdicts on the transactions of a block, or nil if it was not indexed
+func GetBlockECFVerdicts(db ethdb.Database, hash common.Hash, number uint64) []*ECFVerdict {
+	data, _ := db.Get(append(append(blockECFVerdictsPrefix, encodeBlockNumber(number)...), hash.Bytes()...))
+	if len(data) == 0 {
+		return nil
+	}
+	var verdicts []*ECFVerdict
+	if err := rlp.DecodeBytes(data, &verdicts); err != nil {
+		glog.V(logger.Error).Infof("invalid ECF verdicts of block %x: %v", hash, err)
+		return nil
+	}
+	return verdicts
+}
+
+// DeleteBlockECFVerdicts removes the verdicts on the transactions of a block
+func DeleteBlockECFVerdicts(db ethdb.Database, hash common.Hash, number uint64) {
+	db.Delete(append(append(blockECFVerdictsPrefix, encodeBlockNumber(number)...), hash.Bytes()...))
+}
+
+// WriteECFVerdicts indexes the verdicts on the transactions of a canonical block by transaction hash. Nothing is
+// written if the block was not indexed.
+func WriteECFVerdicts(db ethdb.Database, block *types.Block, verdicts []*ECFVerdict) error {
+	txs := block.Transactions()
+	if verdicts == nil || len(verdicts) != len(txs) {
+		return nil
+	}
+	batch := db.NewBatch()
+	for i, tx := range txs {
+		data, err := rlp.EncodeToBytes(verdicts[i])
+		if err != nil {
+			return err
+		}
+		if err := batch.Put(append(ecfVerdictPrefix, tx.Hash().Bytes()...), data); err != nil {
+			return err
+		}
+	}
+	return batch.Write()
+}
+
+// GetECFVerdict returns the verdict on a canonical transaction, or nil if it was not indexed
+func GetECFVerdict(db ethdb.Database, txHash common.Hash) *ECFVerdict {
+	data, _ := db.Get(append(ecfVerdictPrefix, txHash.Bytes()...))
+	if len(data) == 0 {
+		return nil
+	}
+	var verdict ECFVerdict
+	if err := rlp.DecodeBytes(data, &verdict); err != nil {
+		glog.V(logger.Error).Infof("invalid ECF verdict of transaction %x: %v", txHash, err)
+		return nil
+	}
+	return &verdict
+}
+
+// DeleteECFVerdict removes the verdict on a transaction leaving the canonical chain
+func DeleteECFVerdict(db ethdb.Database, txHash common.Hash) {
+	db.Delete(append(ecfVerdictPrefix, txHash.Bytes()...))
+}
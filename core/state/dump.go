@@ -19,8 +19,11 @@ package state
 import (
 	"encoding/json"
 	"fmt"
+	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
@@ -78,3 +81,69 @@ func (self *StateDB) Dump() []byte {
 
 	return json
 }
+
+// NewFromDump returns an in-memory state holding the accounts of a dump, or only the given ones if any, see
+// ImportDump. The dump's root is not checked, since a subset of the accounts has another root.
+func NewFromDump(dump Dump, accounts []common.Address) (*StateDB, error) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, err := New(common.Hash{}, db)
+	if err != nil {
+		return nil, err
+	}
+	if err := statedb.ImportDump(dump, accounts); err != nil {
+		return nil, err
+	}
+	return statedb, nil
+}
+
+// ImportDump sets the balance, nonce, code and storage of the accounts of a dump, as written by RawDump, or only of
+// the given ones if any. The storage of a dump made without the preimages of its keys cannot be imported.
+func (self *StateDB) ImportDump(dump Dump, accounts []common.Address) error {
+	if accounts == nil {
+		for addr := range dump.Accounts {
+			accounts = append(accounts, common.HexToAddress(addr))
+		}
+	}
+	byAddress := make(map[common.Address]DumpAccount, len(dump.Accounts))
+	for addr, account := range dump.Accounts {
+		byAddress[common.HexToAddress(addr)] = account
+	}
+	for _, addr := range accounts {
+		account, ok := byAddress[addr]
+		if !ok {
+			return fmt.Errorf("account %x is not in the dump", addr)
+		}
+		if err := self.importAccount(addr, account); err != nil {
+			return fmt.Errorf("account %x: %v", addr, err)
+		}
+	}
+	return nil
+}
+
+func (self *StateDB) importAccount(addr common.Address, account DumpAccount) error {
+	balance, ok := new(big.Int).SetString(account.Balance, 10)
+	if !ok {
+		return fmt.Errorf("invalid balance %q", account.Balance)
+	}
+	code := common.FromHex(account.Code)
+	if account.CodeHash != "" && common.HexToHash(account.CodeHash) != crypto.Keccak256Hash(code) {
+		return fmt.Errorf("code hash mismatch: dump %s, code %x", account.CodeHash, crypto.Keccak256Hash(code))
+	}
+	self.CreateAccount(addr)
+	self.SetBalance(addr, balance)
+	self.SetNonce(addr, account.Nonce)
+	if len(code) > 0 {
+		self.SetCode(addr, code)
+	}
+	for key, encoded := range account.Storage {
+		if key == "" {
+			return fmt.Errorf("storage key preimage missing")
+		}
+		var value []byte
+		if err := rlp.DecodeBytes(common.FromHex(encoded), &value); err != nil {
+			return fmt.Errorf("invalid storage value %q of key %s: %v", encoded, key, err)
+		}
+		self.SetState(addr, common.HexToHash(key), common.BytesToHash(value))
+	}
+	return nil
+}
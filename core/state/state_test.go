@@ -91,6 +91,48 @@ func (s *StateSuite) SetUpTest(c *checker.C) {
 	s.state, _ = New(common.Hash{}, db)
 }
 
+func TestImportDump(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	state, _ := New(common.Hash{}, db)
+
+	code := []byte{3, 3, 3, 3, 3, 3, 3}
+	state.GetOrNewStateObject(toAddr([]byte{0x01})).AddBalance(big.NewInt(22))
+	obj := state.GetOrNewStateObject(toAddr([]byte{0x01, 0x02}))
+	obj.SetCode(crypto.Keccak256Hash(code), code)
+	obj.SetNonce(7)
+	state.SetState(toAddr([]byte{0x01, 0x02}), common.HexToHash("01"), common.HexToHash("0100"))
+	state.SetState(toAddr([]byte{0x01, 0x02}), common.HexToHash("ff"), common.HexToHash("02"))
+	root, _ := state.Commit(false)
+	dump := state.RawDump()
+
+	// The whole dump has the same root
+	imported, err := NewFromDump(dump, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if importedRoot, _ := imported.Commit(false); importedRoot != root {
+		t.Errorf("root mismatch: have %x, want %x", importedRoot, root)
+	}
+
+	// A subset has only its accounts
+	imported, err = NewFromDump(dump, []common.Address{toAddr([]byte{0x01, 0x02})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.Exist(toAddr([]byte{0x01})) {
+		t.Errorf("account 01 imported, not in the subset")
+	}
+	if imported.GetNonce(toAddr([]byte{0x01, 0x02})) != 7 || !bytes.Equal(imported.GetCode(toAddr([]byte{0x01, 0x02})), code) {
+		t.Errorf("account 0102 mismatch: nonce %d code %x", imported.GetNonce(toAddr([]byte{0x01, 0x02})), imported.GetCode(toAddr([]byte{0x01, 0x02})))
+	}
+	if value := imported.GetState(toAddr([]byte{0x01, 0x02}), common.HexToHash("01")); value != common.HexToHash("0100") {
+		t.Errorf("storage mismatch: have %x", value)
+	}
+	if _, err := NewFromDump(dump, []common.Address{toAddr([]byte{0x03})}); err == nil {
+		t.Errorf("expected an error for an account missing from the dump")
+	}
+}
+
 func TestNull(t *testing.T) {
 	db, _ := ethdb.NewMemDatabase()
 	state, _ := New(common.Hash{}, db)
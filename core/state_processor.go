@@ -19,6 +19,7 @@ package core
 import (
 	"math/big"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
@@ -57,15 +58,20 @@ func NewStateProcessor(config *params.ChainConfig, bc *BlockChain) *StateProcess
 // Process returns the receipts and logs accumulated during the process and
 // returns the amount of gas that was used in the process. If any of the
 // transactions failed to execute due to insufficient gas it will return an error.
-func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, *big.Int, error) {
+// It also returns the results of checking each transaction, see core.BlockECFVerdicts.
+func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, *big.Int, []*vm.ECFResultSlot, error) {
 	var (
 		receipts     types.Receipts
+		ecfResults   []*vm.ECFResultSlot
 		totalUsedGas = big.NewInt(0)
 		err          error
 		header       = block.Header()
 		allLogs      []*types.Log
 		gp           = new(GasPool).AddGas(block.GasLimit())
 	)
+	if cfg.ECFContext == vm.ExecUnknown {
+		cfg.ECFContext = vm.ExecImport
+	}
 	// Mutate the the block and state according to any hard-fork specs
 	if p.config.DAOForkSupport && p.config.DAOForkBlock != nil && p.config.DAOForkBlock.Cmp(block.Number()) == 0 {
 		ApplyDAOHardFork(statedb)
@@ -74,16 +80,18 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 	for i, tx := range block.Transactions() {
 		//fmt.Println("tx:", i)
 		statedb.StartRecord(tx.Hash(), block.Hash(), i)
+		cfg.ECFResult = new(vm.ECFResultSlot)
 		receipt, _, err := ApplyTransaction(p.config, p.bc, gp, statedb, header, tx, totalUsedGas, cfg)
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 		receipts = append(receipts, receipt)
+		ecfResults = append(ecfResults, cfg.ECFResult)
 		allLogs = append(allLogs, receipt.Logs...)
 	}
 	AccumulateRewards(statedb, header, block.Uncles())
 
-	return receipts, allLogs, totalUsedGas, err
+	return receipts, allLogs, totalUsedGas, ecfResults, err
 }
 
 // ApplyTransaction attempts to apply a transaction to the given state database
@@ -97,15 +105,27 @@ func ApplyTransaction(config *params.ChainConfig, bc *BlockChain, gp *GasPool, s
 	}
 	// Create a new context to be used in the EVM environment
 	context := NewEVMContext(msg, header, bc)
+	// Take the verdict from this run, other transactions may be checked meanwhile
+	if cfg.ECFResult == nil {
+		cfg.ECFResult = new(vm.ECFResultSlot)
+	}
+	cfg.ECFResult.Result = nil
 	// Create a new environment which holds all relevant information
 	// about the transaction and calling mechanisms.
 	vmenv := vm.NewEVM(context, statedb, config, cfg)
 	// vmenv.dbHandler = bc.dbHandler
+	checker := vm.TheChecker()
+	checker.SetTransactionContext(tx.Hash(), statedb.BlockHash())
 	// Apply the transaction to the current state (included in the env)
 	_, gas, err := ApplyMessage(vmenv, msg, gp)
+	checker.SetTransactionContext(common.Hash{}, common.Hash{})
 	if err != nil {
 		return nil, nil, err
 	}
+	cfg.ECFResult.Checked = cfg.ECFResult.Result != nil || (checker.Enabled() && !cfg.ECFSkip)
+	if cfg.ECFResult.Checked {
+		checker.RecordTransactionResult(tx.Hash(), cfg.ECFResult.Result)
+	}
 
 	// Update the state with pending changes
 	usedGas.Add(usedGas, gas)
@@ -55,8 +55,8 @@ type HeaderValidator interface {
 //
 // Process takes the block to be processed and the statedb upon which the
 // initial state is based. It should return the receipts generated, amount
-// of gas used in the process and return an error if any of the internal rules
-// failed.
+// of gas used in the process, the results of checking each transaction and
+// return an error if any of the internal rules failed.
 type Processor interface {
-	Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, *big.Int, error)
+	Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, *big.Int, []*vm.ECFResultSlot, error)
 }
@@ -9,6 +9,8 @@ import (
 	"math/big"
 	"os"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"sync"
@@ -16,6 +18,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	//"github.com/ethereum/go-ethereum/common/hexutil"
 	GenStack "github.com/golang-collections/collections/stack"
+	lru "github.com/hashicorp/golang-lru"
 	set "gopkg.in/fatih/set.v0"
 )
 
@@ -65,6 +68,11 @@ type Segment struct {
 
 	// For opening segments only - how many times returned to it
 	hitOnCallCount int
+
+	accesses    set.Interface // StorageAccess-es, unless the attribution is by context only
+	subSegments []SubSegment  // With sub-segment attribution only
+
+	call *SegmentCall // The call that opened the segment, for opening segments only
 }
 
 func (s Segment) String() string {
@@ -78,9 +86,126 @@ func (s Segment) String() string {
 	// 	return fmt.Sprintf("{%v %v %v %v(/%v) %v %v}", s.contract.Hex(), s.depth, s.indexInTransaction, s.indexInCall, s.hitOnCallCount, s.readSet.String(), s.writeSet.String())
 	// }
 
+	if len(s.subSegments) > 0 {
+		return fmt.Sprintf("{%v %v %v %v %v}", s.contract.Hex(), s.depth, s.indexInTransaction, s.indexInCall, s.subSegments)
+	}
+	if s.accesses != nil {
+		return fmt.Sprintf("{%v %v %v %v %v}", s.contract.Hex(), s.depth, s.indexInTransaction, s.indexInCall, s.accesses.String())
+	}
+
 	return fmt.Sprintf("{%v %v %v %v %v %v}", s.contract.Hex(), s.depth, s.indexInTransaction, s.indexInCall, s.readSet.String(), s.writeSet.String())
 }
 
+// ECFCheckMode selects how a minimal recursive subtrace is shown to be equivalent to a callback free one
+type ECFCheckMode int
+
+const (
+	ECFModeDefault   ECFCheckMode = iota // Use the mode the checker was initialized with
+	ECFModeHeuristic                     // Move the inner segments around a single cutpoint (attemptToRemoveRecursion)
+	ECFModeExact                         // Search all serializations of the subtrace, within the exact search limits
+	ECFModeCompare                       // Run both, report the heuristic's verdict and log where the two disagree
+)
+
+func (mode ECFCheckMode) String() string {
+	switch mode {
+	case ECFModeDefault:
+		return "default"
+	case ECFModeHeuristic:
+		return "heuristic"
+	case ECFModeExact:
+		return "exact"
+	case ECFModeCompare:
+		return "compare"
+	}
+	return fmt.Sprintf("ECFCheckMode(%d)", int(mode))
+}
+
+// ParseECFCheckMode converts a mode name as given on the command line or over RPC to an ECFCheckMode
+func ParseECFCheckMode(name string) (ECFCheckMode, error) {
+	switch strings.ToLower(name) {
+	case "", "default":
+		return ECFModeDefault, nil
+	case "heuristic":
+		return ECFModeHeuristic, nil
+	case "exact":
+		return ECFModeExact, nil
+	case "compare":
+		return ECFModeCompare, nil
+	}
+	return ECFModeDefault, fmt.Errorf("unknown ECF check mode %q (want heuristic, exact or compare)", name)
+}
+
+// ECFViolation describes a minimal recursive subtrace which could not be made callback free
+type ECFViolation struct {
+	Contract   common.Address `json:"contract"`
+	Depth      int            `json:"depth"`
+	StartIndex int            `json:"startIndex"` // indexInTransaction of the opening segment
+	Length     int            `json:"length"`     // Number of segments in the subtrace
+	// With sub-segment attribution, the code whose call led to the re-entry (e.g. a proxy's implementation)
+	Code *common.Address `json:"code,omitempty"`
+	// Whether the exemption registry exempts the method of the opening call, see SetExemptionRegistry
+	Exempt bool `json:"exempt,omitempty"`
+}
+
+// ECFDisagreement records a contract for which the heuristic and the exact search reached different verdicts
+type ECFDisagreement struct {
+	Contract     common.Address `json:"contract"`
+	HeuristicECF bool           `json:"heuristicECF"`
+	ExactECF     bool           `json:"exactECF"`
+}
+
+// ECFResult is the outcome of checking a single transaction
+type ECFResult struct {
+	Mode          ECFCheckMode      `json:"mode"`
+	Segments      int               `json:"segments"`
+	Violations    []ECFViolation    `json:"violations"`
+	Disagreements []ECFDisagreement `json:"disagreements,omitempty"`
+	// Number of subtraces the exact search gave up on because of its limits. These were decided by the heuristic.
+	ExactAborted int `json:"exactAborted,omitempty"`
+	// Number of participating contracts whose projection was not checked since their code cannot be harmed
+	StaticSkips int `json:"staticSkips"`
+	// The limit the transaction exceeded, if the checker gave up on it (see SetLimits). The violations found until
+	// then are kept, but there may be more.
+	Inconclusive string `json:"inconclusive,omitempty"`
+
+	counters  map[common.Address]*traceCheckCounters // Per participating contract, for the contract profiles
+	subtraces []violatingSubtrace                    // The subtrace behind each violation, for rendering the trace
+}
+
+// IsECF reports whether no violation was found in the transaction, besides the exempt ones. An inconclusive check may
+// have missed some, see IsConclusive.
+func (result *ECFResult) IsECF() bool {
+	return len(result.NonExempt()) == 0
+}
+
+// IsConclusive reports whether the whole transaction was checked, within the checker's limits
+func (result *ECFResult) IsConclusive() bool {
+	return result.Inconclusive == ""
+}
+
+// NonExempt returns the violations found in the transaction that are not exempt
+func (result *ECFResult) NonExempt() []ECFViolation {
+	violations := make([]ECFViolation, 0, len(result.Violations))
+	for _, violation := range result.Violations {
+		if !violation.Exempt {
+			violations = append(violations, violation)
+		}
+	}
+	return violations
+}
+
+// ECFStats counts what the checker did since it was started
+type ECFStats struct {
+	Transactions uint64 `json:"transactions"` // Transactions checked
+	NonECF       uint64 `json:"nonECF"`
+	Violations   uint64 `json:"violations"`
+	Exempt       uint64 `json:"exempt"`       // Violations exempt by the registry, also counted in Violations
+	Inconclusive uint64 `json:"inconclusive"` // Transactions whose check exceeded a limit, see SetLimits
+	StaticSkips  uint64 `json:"staticSkips"`  // Projections skipped by the static fast path
+	Segments     uint64 `json:"segments"`     // Segments of all checked transactions
+	CheckTime    uint64 `json:"checkTime"`    // Nanoseconds spent checking, excluding the execution itself
+}
+
 // Checker is the type of the to-be-generic checker
 type Checker struct {
 	transactionSegments []Segment
@@ -96,17 +221,132 @@ type Checker struct {
 	blockNumber   *big.Int
 	time          *big.Int
 
+	// The transaction being applied and the block it is in, see SetTransactionContext
+	txHash    common.Hash
+	blockHash common.Hash
+	context   ExecutionContext // What the running transaction is run for
+
 	processTime time.Time
 
 	// DB Handler
 	dbHandler *sql.DB
 
+	writer       *findingsWriter // Writes the findings in the background, see SetDbHandler
+	writerLock   sync.RWMutex
+	checkedBlock uint64 // The last imported block whose findings and profiles are all queued, see BlockChecked
+	passedBlock  uint64 // The last imported block passed to BlockChecked
+
 	numOfTransactionsCheckedSoFar int
+
+	// How recursive subtraces are checked, unless the running EVM's config overrides it
+	mode ECFCheckMode
+	// Bounds on the exact search, per minimal recursive subtrace
+	exactMaxSegments int
+	exactTimeout     time.Duration
+	// Bounds on the check of a transaction, past which its verdict is inconclusive
+	maxSegments  int
+	maxLocations int
+	maxCheckWork int
+	maxCheckTime time.Duration
+
+	lastResult *ECFResult
+
+	profiles contractProfiles
+
+	callCheckIDs callCheckIDs // See StoreCallCheck
+
+	// Static fast path: contracts whose code cannot be harmed by a callback are not checked
+	staticFastPath bool
+	codeTraits     *codeTraitsCache
+	cannotBeHarmed map[common.Address]bool // Per contract of the running transaction
+
+	attribution StorageAttribution
+
+	logsAsWrites bool // Whether emitting a log accesses the contract's event stream, see SetLogsAsWrites
+	verdictIndex bool // Whether the verdicts on block transactions are kept in the chain database, see SetVerdictIndex
+
+	persistedContexts map[ExecutionContext]bool // The contexts whose findings are stored, nil for all
+
+	exemptionRegistry *common.Address // The ECFExemptions registry consulted, if any
+	exempted          exemptionCheck  // Looks up the registry at the state of the running transaction, if consulted
+
+	pendingEnabled int32 // Set by SetEnabled, applied when the next transaction starts
+
+	transactionResults *lru.Cache // Transaction hash -> *ECFResult, for the transactions of recent blocks
+
+	recentViolations violationRing // The last violations found, kept in memory for monitoring
+
+	lastTrace traceSource // The segments of the last checked transaction, see LastTrace
+
+	stats ECFStats
+}
+
+// SetMode sets the check mode used when the EVM config does not request one
+func (checker *Checker) SetMode(mode ECFCheckMode) {
+	if mode == ECFModeDefault {
+		mode = ECFModeHeuristic
+	}
+	checker.mode = mode
+}
+
+// Mode returns the check mode used when the EVM config does not request one
+func (checker *Checker) Mode() ECFCheckMode {
+	return checker.mode
+}
+
+// SetExactLimits bounds the exact search. A subtrace with more than maxSegments segments, or whose search takes longer
+// than timeout, is decided by the heuristic instead.
+func (checker *Checker) SetExactLimits(maxSegments int, timeout time.Duration) {
+	checker.exactMaxSegments = maxSegments
+	checker.exactTimeout = timeout
+}
+
+// LastResult returns the result of the last transaction checked. It may belong to a transaction run concurrently by
+// another interpreter, so callers checking a transaction of their own read it from an ECFResultSlot instead.
+func (checker *Checker) LastResult() *ECFResult {
+	return checker.lastResult
+}
+
+// Stats returns a copy of the checker's counters
+func (checker *Checker) Stats() ECFStats {
+	return ECFStats{
+		Transactions: atomic.LoadUint64(&checker.stats.Transactions),
+		NonECF:       atomic.LoadUint64(&checker.stats.NonECF),
+		Violations:   atomic.LoadUint64(&checker.stats.Violations),
+		Exempt:       atomic.LoadUint64(&checker.stats.Exempt),
+		Inconclusive: atomic.LoadUint64(&checker.stats.Inconclusive),
+		StaticSkips:  atomic.LoadUint64(&checker.stats.StaticSkips),
+		Segments:     atomic.LoadUint64(&checker.stats.Segments),
+		CheckTime:    atomic.LoadUint64(&checker.stats.CheckTime),
+	}
+}
+
+func (checker *Checker) updateStats(result *ECFResult, checkTime time.Duration) {
+	atomic.AddUint64(&checker.stats.Transactions, 1)
+	if !result.IsECF() {
+		atomic.AddUint64(&checker.stats.NonECF, 1)
+	}
+	atomic.AddUint64(&checker.stats.Violations, uint64(len(result.Violations)))
+	atomic.AddUint64(&checker.stats.Exempt, uint64(len(result.Violations)-len(result.NonExempt())))
+	if !result.IsConclusive() {
+		atomic.AddUint64(&checker.stats.Inconclusive, 1)
+		ecfInconclusiveMeter.Mark(1)
+	}
+	atomic.AddUint64(&checker.stats.StaticSkips, uint64(result.StaticSkips))
+	atomic.AddUint64(&checker.stats.Segments, uint64(result.Segments))
+	atomic.AddUint64(&checker.stats.CheckTime, uint64(checkTime))
+
+	ecfChecksMeter.Mark(1)
+	ecfViolationsMeter.Mark(int64(len(result.Violations)))
+	ecfStaticSkipsMeter.Mark(int64(result.StaticSkips))
+	ecfCheckTimer.Update(checkTime)
 }
 
 // SetDbHandler allows to set the db handler from anywhere
 func (checker *Checker) SetDbHandler(db *sql.DB) {
 	checker.dbHandler = db
+	checker.startWriter(db)
+	checker.loadProfiles()
 }
 
 func (checker *Checker) initChecker() {
@@ -121,12 +361,83 @@ func (checker *Checker) initChecker() {
 		ImportantDebug("ECF Check is in place!")
 	}
 
+	checker.mode = ECFModeHeuristic
+	checker.exactMaxSegments = defaultExactMaxSegments
+	checker.exactTimeout = defaultExactTimeout
+	checker.SetLimits(defaultMaxSegments, defaultMaxLocations, defaultMaxCheckWork, defaultMaxCheckTime)
+	checker.codeTraits = newCodeTraitsCache()
+	checker.transactionResults = newTransactionResultsCache()
+	checker.staticFastPath = (os.Getenv("EVM_ECF_DISABLE_STATIC") != "1")
+	checker.logsAsWrites = (os.Getenv("EVM_ECF_LOGS") == "1")
+	checker.verdictIndex = (os.Getenv("EVM_ECF_INDEX") == "1")
+	if modeStr := os.Getenv("EVM_ECF_CHECK_MODE"); modeStr != "" {
+		mode, err := ParseECFCheckMode(modeStr)
+		if err != nil {
+			ImportantDebug("%v, using %v", err, checker.mode)
+		} else {
+			checker.SetMode(mode)
+		}
+	}
+	if maxSegmentsStr := os.Getenv("EVM_ECF_EXACT_MAX_SEGMENTS"); maxSegmentsStr != "" {
+		checker.exactMaxSegments, _ = strconv.Atoi(maxSegmentsStr)
+	}
+	if timeoutStr := os.Getenv("EVM_ECF_EXACT_TIMEOUT"); timeoutStr != "" {
+		if timeout, err := time.ParseDuration(timeoutStr); err == nil {
+			checker.exactTimeout = timeout
+		}
+	}
+	if maxSegmentsStr := os.Getenv("EVM_ECF_MAX_SEGMENTS"); maxSegmentsStr != "" {
+		checker.maxSegments, _ = strconv.Atoi(maxSegmentsStr)
+	}
+	if maxLocationsStr := os.Getenv("EVM_ECF_MAX_LOCATIONS"); maxLocationsStr != "" {
+		checker.maxLocations, _ = strconv.Atoi(maxLocationsStr)
+	}
+	if maxCheckWorkStr := os.Getenv("EVM_ECF_MAX_CHECK_WORK"); maxCheckWorkStr != "" {
+		checker.maxCheckWork, _ = strconv.Atoi(maxCheckWorkStr)
+	}
+	if maxCheckTimeStr := os.Getenv("EVM_ECF_MAX_CHECK_TIME"); maxCheckTimeStr != "" {
+		if maxCheckTime, err := time.ParseDuration(maxCheckTimeStr); err == nil {
+			checker.maxCheckTime = maxCheckTime
+		}
+	}
+	if attributionStr := os.Getenv("EVM_ECF_ATTRIBUTION"); attributionStr != "" {
+		attribution, err := ParseStorageAttribution(attributionStr)
+		if err != nil {
+			ImportantDebug("%v, using %v", err, checker.attribution)
+		} else {
+			checker.attribution = attribution
+		}
+	}
+	if registryStr := os.Getenv("EVM_ECF_REGISTRY"); registryStr != "" {
+		if !common.IsHexAddress(registryStr) {
+			ImportantDebug("Invalid ECF exemption registry address %q, not consulting any", registryStr)
+		} else {
+			registry := common.HexToAddress(registryStr)
+			checker.SetExemptionRegistry(&registry)
+		}
+	}
+	if contextsStr := os.Getenv("EVM_ECF_PERSIST_CONTEXTS"); contextsStr != "" {
+		contexts, err := ParseExecutionContexts(contextsStr)
+		if err != nil {
+			ImportantDebug("%v, storing the findings of all contexts", err)
+		} else {
+			checker.SetPersistedContexts(contexts)
+		}
+	}
+
 	debugLevelStr := os.Getenv("EVM_MONITOR_DEBUG_LEVEL")
 	if debugLevelStr != "" {
 		ImportantDebug("Debug level set to %s", debugLevelStr)
 		debugLevelInt, _ := strconv.Atoi(debugLevelStr)
 		debugLevel = debugLevelInt
 	}
+
+	Debug(1, "ECF check mode is %v", checker.mode)
+	Debug(1, "ECF static fast path is set to: %v", checker.staticFastPath)
+	Debug(1, "ECF storage attribution is %v", checker.attribution)
+	Debug(1, "ECF limits are %v segments, %v locations, %v segments visited and %v per transaction", checker.maxSegments, checker.maxLocations, checker.maxCheckWork, checker.maxCheckTime)
+	Debug(1, "ECF logs as writes is set to: %v", checker.logsAsWrites)
+	Debug(1, "ECF verdict index is set to: %v", checker.verdictIndex)
 }
 
 func (checker *Checker) GetLastSegment() *Segment {
@@ -145,6 +456,7 @@ func (checker *Checker) PushNewSegmentFromStart(contract *Contract) {
 			indexInCall:        0,
 			hitOnCallCount:     0}
 		checker.transactionSegments = make([]Segment, 0)
+		checker.cannotBeHarmed = make(map[common.Address]bool)
 	} else {
 		segment = Segment{contract: contract.Address(),
 			depth:              checker.GetLastSegment().depth + 1,
@@ -156,11 +468,14 @@ func (checker *Checker) PushNewSegmentFromStart(contract *Contract) {
 			hitOnCallCount:     0}
 	}
 
+	segment.call = newSegmentCall(contract)
+
 	Debug(3, "Adding segment %v, also to running segments stack. EVM stack %v (%v), isRealCall %v", segment, checker.evmStack, checker.evmStack.Len(), checker.isRealCall)
 
 	checker.transactionSegments = append(checker.transactionSegments, segment)
 	checker.numberOfSegments++
 	checker.runningSegments.Push(&segment)
+	checker.recordCodeTraits(contract)
 }
 
 func (checker *Checker) PushNewSegmentFromEnd() {
@@ -310,8 +625,11 @@ func findNextOpeningSegment(trace []Segment) int {
 }
 
 // Returns the index of the opening segment which, together with its closing segment and all segments in-between, make for the minimal recursive subtrace in trace.
-// Returns 0 if there are no recursive subtraces (or if in 0 we find the minimal recursive subtrace)
-func findMinimalRecursiveSubTrace(trace []Segment) int { // O(n^2)
+// Returns 0 if there are no recursive subtraces (or if in 0 we find the minimal recursive subtrace), and -1 if the budget ran out
+func findMinimalRecursiveSubTrace(trace []Segment, budget checkBudget) int { // O(n^2)
+	if budget.spend(len(trace)) {
+		return -1
+	}
 	if len(trace) == 0 {
 		Debug(1, "Error in findMinimalRecursiveSubTrace, expecting a trace of size > 0")
 		return -1
@@ -331,7 +649,10 @@ func findMinimalRecursiveSubTrace(trace []Segment) int { // O(n^2)
 		nextOpeningSegmentIdx := findNextOpeningSegment(candidateTrace)
 		nextOpeningSegmentsCloseIdx := findMatchingClosingSegment(nextOpeningSegmentIdx, trace)
 		subtrace := candidateTrace[nextOpeningSegmentIdx : nextOpeningSegmentsCloseIdx+1]
-		indexOfMinimalRecursiveSubtrace := findMinimalRecursiveSubTrace(subtrace)
+		indexOfMinimalRecursiveSubtrace := findMinimalRecursiveSubTrace(subtrace, budget)
+		if indexOfMinimalRecursiveSubtrace < 0 {
+			return -1
+		}
 		if indexOfMinimalRecursiveSubtrace == 0 && !hasRecursion(subtrace) { // In 0 we have a recursive subtrace where in 1 we do not. Thus 0 is start of a minimal recursive subtrace
 			return 0
 		}
@@ -348,7 +669,10 @@ func findMinimalRecursiveSubTrace(trace []Segment) int { // O(n^2)
 
 	suffix := trace[len(candidateTrace):]
 	Debug(3, "working on suffix of trace: %v", suffix)
-	minimalRecursiveSubtraceInSuffix := findMinimalRecursiveSubTrace(suffix)
+	minimalRecursiveSubtraceInSuffix := findMinimalRecursiveSubTrace(suffix, budget)
+	if minimalRecursiveSubtraceInSuffix < 0 {
+		return -1
+	}
 
 	if minimalRecursiveSubtraceInSuffix == 0 && !hasRecursion(suffix) {
 		return 0 // There is no recursion at all in this case!
@@ -358,12 +682,16 @@ func findMinimalRecursiveSubTrace(trace []Segment) int { // O(n^2)
 	return len(candidateTrace) + minimalRecursiveSubtraceInSuffix
 }
 
-func findAndRemoveOmittables(trace []Segment) []Segment {
+// findAndRemoveOmittables returns the trace without its omittable calls. It returns false if the budget ran out first.
+func findAndRemoveOmittables(trace []Segment, budget checkBudget) ([]Segment, bool) {
 	// For each opening segment fetch its call. If no interferences (recursion), check if the unified write set is empty.
 	skippedIndices := make([]int, 0) // Keeps an even number of ints, where the first in each pair marks the start of the range to be skipped, and the second is the end.
 
 	for i := range trace {
 		if isOpeningSegment(trace[i]) {
+			if budget.spend(len(trace) - i) {
+				return nil, false
+			}
 			closingSegmentIdx := findMatchingClosingSegment(i, trace)
 			subtrace := trace[i : closingSegmentIdx+1]
 			if !hasRecursion(subtrace) { // Only if the subtrace containing the call has no recursion, we may check the call
@@ -418,7 +746,7 @@ func findAndRemoveOmittables(trace []Segment) []Segment {
 
 	Debug(2, "Removed omittables from trace %v (%v), got %v (%v)", trace, len(trace), newTrace, len(newTrace))
 
-	return newTrace
+	return newTrace, true
 }
 
 func checkLeftMove(segment Segment, prevReadSet set.Interface, prevWriteSet set.Interface) bool {
@@ -442,11 +770,16 @@ func checkLeftMove(segment Segment, prevReadSet set.Interface, prevWriteSet set.
 	return cond1
 }
 
-func findCutpoint(trace []Segment, baseDepth int) int {
+// findCutpoint returns the cutpoint around which the inner segments can be moved out, or -1 if there is none or the
+// budget ran out first
+func findCutpoint(trace []Segment, baseDepth int, budget checkBudget) int {
 	Debug(2, "Finding cutpoint for %v with baseDepth %v", trace, baseDepth)
 	success := false
 	cutpoint := -1
 	for cutpoint = len(trace); cutpoint > 0; cutpoint-- { // Guessing the cutpoint
+		if budget.spend(len(trace) - cutpoint) {
+			return -1
+		}
 		foundViolation := false
 
 		prefixReadSet := set.New()
@@ -501,12 +834,17 @@ func findCutpoint(trace []Segment, baseDepth int) int {
 }
 
 func attemptToRemoveRecursion(trace []Segment) ([]Segment, bool) {
+	return removeRecursionWithin(trace, checkBudget{})
+}
+
+// removeRecursionWithin is attemptToRemoveRecursion giving up once the budget runs out
+func removeRecursionWithin(trace []Segment, budget checkBudget) ([]Segment, bool) {
 	// Get the segments of the outermost call.
 	outerCall := findAllSegmentsOfCall(0, trace)
 	outerCallDepth := outerCall[0].depth
 
 	// Find the cutpoint
-	cutpoint := findCutpoint(trace, outerCallDepth)
+	cutpoint := findCutpoint(trace, outerCallDepth, budget)
 	if cutpoint == -1 {
 		return nil, false
 	}
@@ -524,7 +862,7 @@ func attemptToRemoveRecursion(trace []Segment) ([]Segment, bool) {
 				after = append(after, trace[i])
 			}
 		} else if trace[i].depth < outerCallDepth {
-			Debug(1, "Error in attemptToRemoveRecursion: when rebuilding the trace, there can't be a lower depth then the outermost call depth")
+			Debug(1, "Error in removeRecursionWithin: when rebuilding the trace, there can't be a lower depth then the outermost call depth")
 			return nil, false
 		} // else: equal depths. take directly from outercall
 	}
@@ -536,36 +874,78 @@ func attemptToRemoveRecursion(trace []Segment) ([]Segment, bool) {
 	return newTrace, true
 }
 
-func reportNonReentrant(segment Segment, traceLen int) {
+func reportNonReentrant(violation ECFViolation) {
 	checker := TheChecker()
+	stored := checker.storedViolation(violation)
+	if checker.counts(checker.context) {
+		checker.recentViolations.add(stored)
+	}
+	if checker.dbHandler == nil { // Running offline (evm, ecfscan), there is nowhere to store the trace
+		return
+	}
+	if !checker.persists(checker.context) {
+		Debug(2, "Not storing violation found in %v context", checker.context)
+		return
+	}
 
-	stmt := fmt.Sprintf("insert into NON_REENTRANT_TRACE(id, origin, block, time, contract, depth, start_index, length) VALUES(%d, '%s', %d, %d, '%s', %d, %d, %d)",
-		checker.TransactionID,
-		checker.origin.Hex(),
-		checker.blockNumber,
-		checker.time,
-		segment.contract.Hex(),
-		segment.depth,
-		segment.indexInTransaction,
-		traceLen)
+	// Rows start out non-canonical, and are marked canonical once their block is written to the chain as such
+	checker.write("store violation", "insert into NON_REENTRANT_TRACE(id, origin, block, time, contract, depth, start_index, length, tx_hash, block_hash, canonical, context, exempt) values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 0, ?, ?)",
+		stored.TransactionID,
+		stored.Origin.Hex(),
+		stored.Block,
+		stored.Time,
+		violation.Contract.Hex(),
+		violation.Depth,
+		violation.StartIndex,
+		violation.Length,
+		hashColumn(stored.TxHash),
+		hashColumn(stored.BlockHash),
+		stored.Context,
+		violation.Exempt)
+}
 
-	_, dberr := checker.dbHandler.Exec(stmt)
-	if dberr != nil {
-		ImportantDebug("Failed to execute %s, %v", stmt, dberr)
-	}
+// subtraceReorderer attempts to rearrange a minimal recursive subtrace into an equivalent one without recursion
+type subtraceReorderer func(trace []Segment) ([]Segment, bool)
+
+// checkTraceForReentrancy returns the violation of the first minimal recursive subtrace of the projected trace which
+// reorder fails on, or nil if the trace is ECF. The counters are updated with the omittable calls and reordered
+// subtraces on the way.
+func checkTraceForReentrancy(trace []Segment, reorder subtraceReorderer, counters *traceCheckCounters) *ECFViolation {
+	subtrace, _ := findViolatingSubtrace(trace, reorder, counters, checkBudget{})
+	return violationOf(subtrace)
 }
 
-func checkTraceForReentrancy(trace []Segment) {
+// findViolatingSubtrace returns the first minimal recursive subtrace of the projected trace which reorder fails on, or
+// nil if the trace is ECF. It returns false if it ran out of time before deciding.
+func findViolatingSubtrace(trace []Segment, reorder subtraceReorderer, counters *traceCheckCounters, budget checkBudget) ([]Segment, bool) {
 	for hasRecursion(trace) {
-		trace = findAndRemoveOmittables(trace)
+		if budget.spend(len(trace)) {
+			return nil, false
+		}
+
+		withOmittables := len(trace)
+		var decided bool
+		if trace, decided = findAndRemoveOmittables(trace, budget); !decided {
+			return nil, false
+		}
+		if len(trace) < withOmittables {
+			counters.omittableRemoved++
+		}
 
 		// If trace is entirely omittable, return. It is obviously reentrant
 		if len(trace) == 0 || !hasRecursion(trace) {
 			Debug(2, "Transaction is ECF after removing omittables.")
-			return
+			return nil, true
 		}
 
-		minimalRecursiveSubTraceOpenIdx := findMinimalRecursiveSubTrace(trace)
+		minimalRecursiveSubTraceOpenIdx := findMinimalRecursiveSubTrace(trace, budget)
+		if minimalRecursiveSubTraceOpenIdx < 0 {
+			if budget.exhausted() {
+				return nil, false
+			}
+			Debug(1, "Error in checkTraceForReentrancy - no minimal recursive subtrace found in %v", trace)
+			return nil, true
+		}
 		minimalRecursiveSubTraceCloseIdx := findMatchingClosingSegment(minimalRecursiveSubTraceOpenIdx, trace)
 
 		minimalRecursiveSubTrace := trace[minimalRecursiveSubTraceOpenIdx : minimalRecursiveSubTraceCloseIdx+1]
@@ -579,19 +959,19 @@ func checkTraceForReentrancy(trace []Segment) {
 
 		if !hasRecursion(minimalRecursiveSubTrace) {
 			Debug(1, "Error in checkTraceForReentrancy - must have recursion in subtrace in this step - 1 %v", minimalRecursiveSubTrace)
-			return
+			return nil, true
 		}
 
-		reorderedSubTrace, success := attemptToRemoveRecursion(minimalRecursiveSubTrace)
+		reorderedSubTrace, success := reorder(minimalRecursiveSubTrace)
 		if !success {
-			firstSegment := minimalRecursiveSubTrace[0]
-			ImportantDebug("Transaction is not ECF! Contract %v, depth %v, index in transaction starting at %v", firstSegment.contract.Hex(), firstSegment.depth, firstSegment.indexInTransaction)
-
-			reportNonReentrant(firstSegment, len(minimalRecursiveSubTrace))
-
-			return
+			if budget.exhausted() { // The reordering may have given up for lack of budget
+				return nil, false
+			}
+			counters.violations++
+			return minimalRecursiveSubTrace, true
 		} else {
 			Debug(2, "Subtrace is ECF. Original: %v, Reordered : %v", minimalRecursiveSubTrace, reorderedSubTrace)
+			counters.repairedByReorder++
 		}
 
 		newTrace := make([]Segment, 0)
@@ -599,31 +979,148 @@ func checkTraceForReentrancy(trace []Segment) {
 		trace = newTrace
 	}
 
+	return nil, true
 }
 
-func (checker *Checker) checkForReentrancy() {
+// violationOf returns the violation of a minimal recursive subtrace, or nil if there is no subtrace
+func violationOf(subtrace []Segment) *ECFViolation {
+	if len(subtrace) == 0 {
+		return nil
+	}
+	violation := &ECFViolation{
+		Contract:   subtrace[0].contract,
+		Depth:      subtrace[0].depth,
+		StartIndex: subtrace[0].indexInTransaction,
+		Length:     len(subtrace),
+	}
+	if code := interruptingCode(subtrace); code != (common.Address{}) {
+		violation.Code = &code
+	}
+	return violation
+}
+
+// checkForReentrancy checks the projection of the transaction on each participating contract, using the given mode
+func (checker *Checker) checkForReentrancy(mode ECFCheckMode) *ECFResult {
+	return checker.checkSegments(checker.transactionSegments, checker.cannotBeHarmed, mode, true)
+}
+
+// checkSegments checks the segments of a transaction for reentrancy with respect to each participating contract,
+// except for the ones whose code cannot be harmed by a callback. Violations are reported if asked to.
+func (checker *Checker) checkSegments(segments []Segment, cannotBeHarmed map[common.Address]bool, mode ECFCheckMode, report bool) *ECFResult {
 	checkedContracts := make(map[string]bool)
+	if mode == ECFModeDefault {
+		mode = checker.mode
+	}
+	result := &ECFResult{Mode: mode, Segments: len(segments), counters: make(map[common.Address]*traceCheckCounters)}
 
-	Debug(2, "Transaction segments: (%v) %v", len(checker.transactionSegments), checker.transactionSegments)
-	if len(checker.transactionSegments) == 1 { // If there is just 1 segment in the transaction, no point in checking it! Optimization
-		return
+	Debug(2, "Transaction segments: (%v) %v", len(segments), segments)
+	if len(segments) == 1 { // If there is just 1 segment in the transaction, no point in checking it! Optimization
+		result.counters[segments[0].contract] = &traceCheckCounters{}
+		return result
+	}
+	if result.Inconclusive = checker.exceededLimit(segments); result.Inconclusive != "" {
+		if report {
+			checker.reportInconclusive(result)
+		}
+		return result
 	}
+	budget := checker.newCheckBudget()
 
-	for i := range checker.transactionSegments {
-		contract := checker.transactionSegments[i].contract
+	heuristic := func(trace []Segment) ([]Segment, bool) {
+		return removeRecursionWithin(trace, budget)
+	}
+	exact := func(trace []Segment) ([]Segment, bool) {
+		reordered, outcome := findCallbackFreeSerialization(trace, checker.exactMaxSegments, budget.bound(checker.exactTimeout))
+		if outcome == exactSearchAborted {
+			result.ExactAborted++
+			return heuristic(trace)
+		}
+		return reordered, outcome == exactSearchFound
+	}
+
+	for i := range segments {
+		contract := segments[i].contract
 
 		if !checkedContracts[contract.Hex()] {
-			projection := GetProjectedTrace(checker.transactionSegments, &contract)
+			if budget.exhausted() {
+				result.Inconclusive = budget.reason()
+				break
+			}
+			projection := GetProjectedTrace(segments, &contract)
 			Debug(2, "Checking contract %v, projection: %v (%v)", contract.Hex(), projection, len(projection))
-			checkTraceForReentrancy(projection)
+
+			counters := &traceCheckCounters{reentries: countReentries(projection)}
+			result.counters[contract] = counters
 			checkedContracts[contract.Hex()] = true
+
+			if cannotBeHarmed[contract] {
+				Debug(2, "Skipping contract %v, its code cannot be harmed by a callback", contract.Hex())
+				result.StaticSkips++
+				continue
+			}
+
+			var (
+				subtrace []Segment
+				decided  bool
+			)
+			switch mode {
+			case ECFModeExact:
+				subtrace, decided = findViolatingSubtrace(projection, exact, counters, budget)
+			case ECFModeCompare:
+				var exactSubtrace []Segment
+				subtrace, decided = findViolatingSubtrace(projection, heuristic, counters, budget)
+				if decided {
+					exactSubtrace, decided = findViolatingSubtrace(projection, exact, &traceCheckCounters{}, budget)
+				}
+				if decided && (subtrace == nil) != (exactSubtrace == nil) {
+					disagreement := ECFDisagreement{Contract: contract, HeuristicECF: subtrace == nil, ExactECF: exactSubtrace == nil}
+					ImportantDebug("Heuristic and exact ECF checks disagree on contract %v: heuristic ECF %v, exact ECF %v", contract.Hex(), disagreement.HeuristicECF, disagreement.ExactECF)
+					result.Disagreements = append(result.Disagreements, disagreement)
+				}
+			default:
+				subtrace, decided = findViolatingSubtrace(projection, heuristic, counters, budget)
+			}
+			if !decided {
+				result.Inconclusive = budget.reason()
+				break
+			}
+
+			if violation := violationOf(subtrace); violation != nil {
+				context := ExecUnknown
+				if report {
+					context = checker.context
+					if checker.exempted != nil {
+						violation.Exempt = checker.exempted(violation.Contract, subtraceSelector(subtrace))
+					}
+				}
+				if violation.Exempt {
+					ImportantDebug("Transaction is not ECF (%v), but the method of contract %v is exempt by the registry, depth %v, index in transaction starting at %v", context, violation.Contract.Hex(), violation.Depth, violation.StartIndex)
+				} else if violation.Code != nil {
+					ImportantDebug("Transaction is not ECF (%v)! Contract %v (re-entered through code %v), depth %v, index in transaction starting at %v", context, violation.Contract.Hex(), violation.Code.Hex(), violation.Depth, violation.StartIndex)
+				} else {
+					ImportantDebug("Transaction is not ECF (%v)! Contract %v, depth %v, index in transaction starting at %v", context, violation.Contract.Hex(), violation.Depth, violation.StartIndex)
+				}
+				if report {
+					reportNonReentrant(*violation)
+				}
+				result.Violations = append(result.Violations, *violation)
+				result.subtraces = append(result.subtraces, describeSubtrace(subtrace))
+			}
 		}
 	}
+	if report && result.Inconclusive != "" {
+		checker.reportInconclusive(result)
+	}
+
+	return result
 }
 
 // UponEVMStart is called each time the EVM is run (due to a call or otherwise)
 func (checker *Checker) UponEVMStart(evm *Interpreter, contract *Contract) {
-	if DISABLE_CHECKER {
+	if evm.env.depth == 0 { // Not yet incremented for this run, so a transaction is starting
+		checker.applyPendingEnabled()
+	}
+	if DISABLE_CHECKER || evm.cfg.ECFSkip {
 		return
 	}
 
@@ -633,7 +1130,7 @@ func (checker *Checker) UponEVMStart(evm *Interpreter, contract *Contract) {
 		Debug(1, "Checked %d transactions so far in this run", checker.numOfTransactionsCheckedSoFar)
 	}
 
-	if checker.TransactionID == 0 {
+	if checker.TransactionID == 0 && checker.dbHandler != nil {
 		// Read from database
 		var lastTransactionID int
 		qErr := checker.dbHandler.QueryRow("select txId from LAST_TRANSACTION_ID").Scan(&lastTransactionID)
@@ -652,12 +1149,20 @@ func (checker *Checker) UponEVMStart(evm *Interpreter, contract *Contract) {
 		checker.origin = &evm.env.Origin
 		checker.blockNumber = evm.env.BlockNumber
 		checker.time = evm.env.Time
+		checker.context = evm.cfg.ECFContext
 		checker.processTime = time.Now()
 	}
 
 	// Create a new Segment
 	if checker.evmStack.Len() == 0 || checker.isRealCall {
+		tracer := segmentTracer(evm)
+		if tracer != nil && checker.evmStack.Len() > 0 {
+			tracer.CaptureExitSegment(checker.GetLastSegment().export())
+		}
 		checker.PushNewSegmentFromStart(contract)
+		if tracer != nil {
+			tracer.CaptureEnterSegment(checker.GetLastSegment().export())
+		}
 	}
 
 	checker.evmStack.Push(checker.evmStack.Len() == 0 || checker.isRealCall)
@@ -668,42 +1173,73 @@ func (checker *Checker) UponEVMStart(evm *Interpreter, contract *Contract) {
 
 // UponEVMEnd is called each time the EVM run's ends (due to a return or otherwise)
 func (checker *Checker) UponEVMEnd(evm *Interpreter, contract *Contract) {
-	if DISABLE_CHECKER {
+	if DISABLE_CHECKER || evm.cfg.ECFSkip {
 		return
 	}
 
 	// We pop from running segments only if the evmStack top is true (i.e. a real call, and not a delegated one)
 	activeCallIsARealCall := checker.evmStack.Pop().(bool)
 	Debug(5, "Finished a real call? %v", activeCallIsARealCall)
+	tracer := segmentTracer(evm)
 	if checker.runningSegments.Len() > 1 && activeCallIsARealCall == true {
+		if tracer != nil {
+			tracer.CaptureExitSegment(checker.GetLastSegment().export())
+		}
 		checker.runningSegments.Pop()
 		checker.PushNewSegmentFromEnd()
-	}
-
-	if checker.runningSegments.Len() == 1 && activeCallIsARealCall {
+		if tracer != nil {
+			tracer.CaptureEnterSegment(checker.GetLastSegment().export())
+		}
+	} else if checker.runningSegments.Len() == 1 && activeCallIsARealCall { // Only once the outermost call returns
+		if tracer != nil {
+			tracer.CaptureExitSegment(checker.GetLastSegment().export())
+		}
 		FirstSegment := checker.runningSegments.Pop().(*Segment)
 
 		Debug(2, "Transaction ended (Block #%v, contract %v). Checking if ECF with respect to all participating contracts.", evm.env.BlockNumber, FirstSegment.contract.Hex())
 
 		reentrancyCheckStartTime := time.Now()
-		checker.checkForReentrancy()
+		checker.exempted = checker.exemptionCheck(evm.env)
+		result := checker.checkForReentrancy(evm.cfg.ECFMode)
+		checker.exempted = nil
 		reentrancyCheckDuration := time.Since(reentrancyCheckStartTime)
+		checker.lastResult = result
+		if evm.cfg.ECFResult != nil {
+			evm.cfg.ECFResult.Result = result
+		}
+		checker.lastTrace = traceSource{segments: checker.transactionSegments, cannotBeHarmed: checker.cannotBeHarmed, origin: checker.origin, block: checker.blockNumber}
+		if evm.cfg.ECFTrace != nil {
+			evm.cfg.ECFTrace.Trace = checker.lastTrace.export(checker.attribution)
+		}
+		if checker.counts(checker.context) {
+			checker.updateProfiles(result.counters, checker.blockNumber)
+			checker.updateStats(result, reentrancyCheckDuration)
+		}
 		totalProcessDuration := time.Since(checker.processTime)
 		Debug(2, "Reentrancy check (Block #%v, contract %v) took %s / %s total", evm.env.BlockNumber, FirstSegment.contract.Hex(), reentrancyCheckDuration, totalProcessDuration)
+		if tracer != nil {
+			tracer.CaptureECFResult(result)
+		}
 	}
 
 	if checker.evmStack.Len() == 0 {
 		checker.origin = nil
 		checker.blockNumber = nil
 		checker.time = nil
+		checker.context = ExecUnknown
 		checker.numberOfSegments = 0
 		// checker.processTime = nil // will be reset properly when we return from quiescent state and run another transaction
 	}
 }
 
+// skipsChecks reports whether the opcodes run by the given EVM are not checked, see Config.ECFSkip
+func skipsChecks(evm *EVM) bool {
+	return evm != nil && evm.vmConfig.ECFSkip
+}
+
 // UponSStore is called upon each SSTORE opcode called
 func (checker *Checker) UponSStore(evm *EVM, contract *Contract, loc common.Hash, val *big.Int) {
-	if DISABLE_CHECKER {
+	if DISABLE_CHECKER || skipsChecks(evm) {
 		return
 	}
 
@@ -712,11 +1248,30 @@ func (checker *Checker) UponSStore(evm *EVM, contract *Contract, loc common.Hash
 	}
 
 	checker.GetLastSegment().writeSet.Add(loc)
+	checker.attributeAccess(contract, loc, true)
+}
+
+// UponLog is called upon each LOG0 to LOG4 opcode called. If logs are writes, the segment reads and writes the event
+// stream of the contract emitting the log.
+func (checker *Checker) UponLog(evm *EVM, contract *Contract, topics []common.Hash) {
+	if DISABLE_CHECKER || skipsChecks(evm) || !checker.logsAsWrites {
+		return
+	}
+
+	if storageDebug {
+		Debug(6, "LOG%d contract %v, topics %v\n", len(topics), contract.Address().Hex(), topics)
+	}
+
+	loc := EventStreamLocation(contract.Address())
+	checker.GetLastSegment().readSet.Add(loc)
+	checker.GetLastSegment().writeSet.Add(loc)
+	checker.attributeAccess(contract, loc, false)
+	checker.attributeAccess(contract, loc, true)
 }
 
 // UponSLoad is called upon each SLOAD opcode called
 func (checker *Checker) UponSLoad(evm *EVM, contract *Contract, loc common.Hash, val *big.Int) {
-	if DISABLE_CHECKER {
+	if DISABLE_CHECKER || skipsChecks(evm) {
 		return
 	}
 
@@ -731,13 +1286,15 @@ func (checker *Checker) UponSLoad(evm *EVM, contract *Contract, loc common.Hash,
 	}
 
 	checker.GetLastSegment().readSet.Add(loc)
+	checker.attributeAccess(contract, loc, false)
 }
 
 // UponCall is called upon each CALL opcode called
 func (checker *Checker) UponCall(evm *EVM, contract *Contract, callee common.Address, value *big.Int, input []byte) {
-	if DISABLE_CHECKER {
+	if DISABLE_CHECKER || skipsChecks(evm) {
 		return
 	}
 
 	checker.isRealCall = true
+	checker.attributeCall(contract)
 }
@@ -0,0 +1,148 @@
+// Shelly
+
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	set "gopkg.in/fatih/set.v0"
+)
+
+// StorageAttribution selects how much the checker records about which code made each storage access. Storage
+// accesses of DELEGATECALL and CALLCODE frames always count for the segment of the calling contract, so the verdicts
+// do not depend on the attribution.
+type StorageAttribution int
+
+const (
+	AttributionContext     StorageAttribution = iota // Accesses are keyed by slot only
+	AttributionTagged                                // Each access is also tagged with the address of the code that made it
+	AttributionSubSegments                           // As tagged, and segments are split into sub-segments per running code
+)
+
+func (attribution StorageAttribution) String() string {
+	switch attribution {
+	case AttributionContext:
+		return "context"
+	case AttributionTagged:
+		return "tagged"
+	case AttributionSubSegments:
+		return "subsegments"
+	}
+	return fmt.Sprintf("StorageAttribution(%d)", int(attribution))
+}
+
+// ParseStorageAttribution converts an attribution name as given on the command line to a StorageAttribution
+func ParseStorageAttribution(name string) (StorageAttribution, error) {
+	switch strings.ToLower(name) {
+	case "", "context":
+		return AttributionContext, nil
+	case "tagged":
+		return AttributionTagged, nil
+	case "subsegments":
+		return AttributionSubSegments, nil
+	}
+	return AttributionContext, fmt.Errorf("unknown storage attribution %q (want context, tagged or subsegments)", name)
+}
+
+// StorageAccess is a storage access tagged with the code that made it
+type StorageAccess struct {
+	Location common.Hash    `json:"location"`
+	Code     common.Address `json:"code"`
+	Write    bool           `json:"write"`
+}
+
+func (access StorageAccess) String() string {
+	kind := "R"
+	if access.Write {
+		kind = "W"
+	}
+	return fmt.Sprintf("%v %x@%v", kind, access.Location, access.Code.Hex())
+}
+
+// SubSegment is the part of a segment run by a single code, e.g. a proxy's code and then, through DELEGATECALL, the
+// code of its implementation
+type SubSegment struct {
+	code     common.Address
+	readSet  set.Interface
+	writeSet set.Interface
+}
+
+func (s SubSegment) String() string {
+	return fmt.Sprintf("<%v %v %v>", s.code.Hex(), s.readSet.String(), s.writeSet.String())
+}
+
+// codeAddress returns the address of the code a frame runs. It differs from the contract's address in DELEGATECALL and
+// CALLCODE frames.
+func codeAddress(contract *Contract) common.Address {
+	if contract.CodeAddr != nil {
+		return *contract.CodeAddr
+	}
+	return contract.Address()
+}
+
+// SetAttribution sets what is recorded about the code making each storage access
+func (checker *Checker) SetAttribution(attribution StorageAttribution) {
+	checker.attribution = attribution
+}
+
+// Attribution returns what is recorded about the code making each storage access
+func (checker *Checker) Attribution() StorageAttribution {
+	return checker.attribution
+}
+
+// currentSubSegment returns the last sub-segment of the segment, starting a new one if it was run by other code
+func currentSubSegment(segment *Segment, code common.Address) *SubSegment {
+	if n := len(segment.subSegments); n > 0 && segment.subSegments[n-1].code == code {
+		return &segment.subSegments[n-1]
+	}
+	segment.subSegments = append(segment.subSegments, SubSegment{code: code, readSet: set.New(), writeSet: set.New()})
+	return &segment.subSegments[len(segment.subSegments)-1]
+}
+
+// attributeAccess records which code made a storage access to the running segment, as far as the attribution asks for
+func (checker *Checker) attributeAccess(contract *Contract, loc common.Hash, write bool) {
+	if checker.attribution == AttributionContext {
+		return
+	}
+
+	segment := checker.GetLastSegment()
+	code := codeAddress(contract)
+	if segment.accesses == nil {
+		segment.accesses = set.New()
+	}
+	segment.accesses.Add(StorageAccess{Location: loc, Code: code, Write: write})
+
+	if checker.attribution == AttributionSubSegments {
+		subSegment := currentSubSegment(segment, code)
+		if write {
+			subSegment.writeSet.Add(loc)
+		} else {
+			subSegment.readSet.Add(loc)
+		}
+	}
+}
+
+// attributeCall notes the code making a call, so that the segment the call interrupts ends in a sub-segment of that code
+func (checker *Checker) attributeCall(contract *Contract) {
+	if checker.attribution != AttributionSubSegments {
+		return
+	}
+	currentSubSegment(checker.GetLastSegment(), codeAddress(contract))
+}
+
+// interruptingCode returns the address of the code whose call led to the first re-entry in a minimal recursive
+// subtrace, i.e. the code running last in the outer segment preceding the first inner segment. It is only known with
+// sub-segments.
+func interruptingCode(subtrace []Segment) common.Address {
+	for i := 1; i < len(subtrace); i++ {
+		if subtrace[i].depth > subtrace[0].depth {
+			if n := len(subtrace[i-1].subSegments); n > 0 {
+				return subtrace[i-1].subSegments[n-1].code
+			}
+			break
+		}
+	}
+	return common.Address{}
+}
@@ -0,0 +1,166 @@
+// Shelly
+
+package vm
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// TraceBuilder declares a transaction as a tree of calls, with the storage each segment reads and writes, and checks it
+// without running an EVM. For example, the DAO attack A1 B1 A'1 B2 A2 is
+//
+//	Trace().Call(A).Read(k).Call(B).Call(A).Read(k).Write(k).Ret().Ret().Write(k).Ret()
+//
+// Call opens a new segment of the callee, Ret returns to the caller and opens its next segment. Reads and writes go
+// to the running segment.
+type TraceBuilder struct {
+	trace  *SegmentTrace
+	frames []traceFrame // The calls not yet returned from, the running one last
+	err    error        // The first misuse of the builder, reported by Build
+}
+
+type traceFrame struct {
+	contract common.Address
+	returns  uint64 // Number of calls returned to this frame, i.e. the index in call of its running segment
+}
+
+// Trace starts declaring a transaction
+func Trace() *TraceBuilder {
+	return &TraceBuilder{trace: &SegmentTrace{Version: SegmentTraceVersion, Attribution: AttributionContext.String()}}
+}
+
+// Origin sets the account sending the transaction
+func (b *TraceBuilder) Origin(origin common.Address) *TraceBuilder {
+	b.trace.Origin = origin
+	return b
+}
+
+// Block sets the number of the block the transaction is in
+func (b *TraceBuilder) Block(number uint64) *TraceBuilder {
+	b.trace.Block = number
+	return b
+}
+
+// Call calls the contract from the running segment, or starts the transaction with it
+func (b *TraceBuilder) Call(contract common.Address) *TraceBuilder {
+	if b.err != nil {
+		return b
+	}
+	if len(b.trace.Segments) > 0 && len(b.frames) == 0 {
+		b.err = fmt.Errorf("call of %x after the transaction ended", contract)
+		return b
+	}
+	b.frames = append(b.frames, traceFrame{contract: contract})
+	b.open(contract, 0)
+	return b
+}
+
+// Ret returns from the running call to its caller
+func (b *TraceBuilder) Ret() *TraceBuilder {
+	if b.err != nil {
+		return b
+	}
+	if len(b.frames) == 0 {
+		b.err = fmt.Errorf("return without a running call (segment %d)", len(b.trace.Segments))
+		return b
+	}
+	b.frames = b.frames[:len(b.frames)-1]
+	if n := len(b.frames); n > 0 {
+		b.frames[n-1].returns++
+		b.open(b.frames[n-1].contract, b.frames[n-1].returns)
+	}
+	return b
+}
+
+// Read adds the locations to the read set of the running segment
+func (b *TraceBuilder) Read(locations ...common.Hash) *TraceBuilder {
+	if segment := b.running("read"); segment != nil {
+		segment.Reads = append(segment.Reads, locations...)
+	}
+	return b
+}
+
+// Write adds the locations to the write set of the running segment
+func (b *TraceBuilder) Write(locations ...common.Hash) *TraceBuilder {
+	if segment := b.running("write"); segment != nil {
+		segment.Writes = append(segment.Writes, locations...)
+	}
+	return b
+}
+
+// Log emits a log from the running segment, which reads and writes the event stream of its contract as the checker
+// records it when logs are treated as writes
+func (b *TraceBuilder) Log() *TraceBuilder {
+	if segment := b.running("log"); segment != nil {
+		stream := EventStreamLocation(segment.Contract)
+		segment.Reads = append(segment.Reads, stream)
+		segment.Writes = append(segment.Writes, stream)
+	}
+	return b
+}
+
+// Unharmable marks the contract as one the static fast path found cannot be harmed by a callback
+func (b *TraceBuilder) Unharmable(contract common.Address) *TraceBuilder {
+	b.trace.Unharmable = append(b.trace.Unharmable, contract)
+	return b
+}
+
+func (b *TraceBuilder) open(contract common.Address, indexInCall uint64) {
+	b.trace.Segments = append(b.trace.Segments, TraceSegment{
+		Contract:           contract,
+		Depth:              uint64(len(b.frames)),
+		IndexInTransaction: uint64(len(b.trace.Segments)),
+		IndexInCall:        indexInCall,
+	})
+}
+
+// running returns the running segment, or nil, noting the misuse, if no call is running
+func (b *TraceBuilder) running(access string) *TraceSegment {
+	if b.err != nil {
+		return nil
+	}
+	if len(b.frames) == 0 {
+		b.err = fmt.Errorf("%s without a running call (segment %d)", access, len(b.trace.Segments))
+		return nil
+	}
+	return &b.trace.Segments[len(b.trace.Segments)-1]
+}
+
+// Build returns the declared transaction as a segment trace, which can also be encoded and checked with ecfcheck.
+// Every call must have returned.
+func (b *TraceBuilder) Build() (*SegmentTrace, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if len(b.trace.Segments) == 0 {
+		return nil, fmt.Errorf("empty trace")
+	}
+	if len(b.frames) > 0 {
+		return nil, fmt.Errorf("%d call(s) not returned from", len(b.frames))
+	}
+	trace := *b.trace
+	trace.Unharmable = append(make([]common.Address, 0, len(b.trace.Unharmable)), b.trace.Unharmable...)
+	trace.Segments = make([]TraceSegment, len(b.trace.Segments))
+	for i, segment := range b.trace.Segments {
+		segment.Reads = sortedLocations(locationSet(segment.Reads))
+		segment.Writes = sortedLocations(locationSet(segment.Writes))
+		trace.Segments[i] = segment
+	}
+	return &trace, nil
+}
+
+// Check checks the declared transaction with the given checker, or with a standalone checker using the default
+// settings if it is nil. Violations are not stored.
+func (b *TraceBuilder) Check(checker *Checker, mode ECFCheckMode) (*ECFResult, error) {
+	trace, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	if checker == nil {
+		checker = &Checker{mode: ECFModeHeuristic, exactMaxSegments: defaultExactMaxSegments, exactTimeout: defaultExactTimeout, staticFastPath: true}
+		checker.SetLimits(defaultMaxSegments, defaultMaxLocations, defaultMaxCheckWork, defaultMaxCheckTime)
+	}
+	return checker.CheckTrace(trace, mode)
+}
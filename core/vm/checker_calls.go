@@ -0,0 +1,107 @@
+// Shelly
+
+package vm
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// CallCheck is the stored verdict of a simulated call (eth_call style) that was checked on request, e.g. by a wallet
+// pre-checking a transaction against a light node
+type CallCheck struct {
+	ID         int64           `json:"id"`
+	Block      uint64          `json:"block"`
+	From       common.Address  `json:"from"`
+	To         *common.Address `json:"to"` // nil for contract creation
+	InputHash  common.Hash     `json:"inputHash"`
+	Mode       string          `json:"mode"`
+	ECF        bool            `json:"ecf"`
+	Violations int             `json:"violations"`
+	Time       int64           `json:"time"` // Unix time of the check
+}
+
+// callCheckIDs assigns the IDs of the stored call verdicts, which are written in the background and so cannot take
+// the ID the database would give them
+type callCheckIDs struct {
+	lock   sync.Mutex
+	last   int64
+	loaded bool // Whether last was read from the database
+}
+
+// nextCallCheckID returns the ID of the next stored call verdict, following the last one stored
+func (checker *Checker) nextCallCheckID() (int64, error) {
+	checker.callCheckIDs.lock.Lock()
+	defer checker.callCheckIDs.lock.Unlock()
+
+	if !checker.callCheckIDs.loaded {
+		if err := checker.dbHandler.QueryRow("select coalesce(max(id), 0) from CALL_CHECK").Scan(&checker.callCheckIDs.last); err != nil {
+			return 0, err
+		}
+		checker.callCheckIDs.loaded = true
+	}
+	checker.callCheckIDs.last++
+	return checker.callCheckIDs.last, nil
+}
+
+// StoreCallCheck queues the verdict of a checked call for writing, setting its ID. It does nothing when running
+// without a database, or if the findings of calls are not persisted.
+func (checker *Checker) StoreCallCheck(check *CallCheck) error {
+	if checker.dbHandler == nil || !checker.persists(ExecCall) {
+		return nil
+	}
+	if check.Time == 0 {
+		check.Time = time.Now().Unix()
+	}
+	id, err := checker.nextCallCheckID()
+	if err != nil {
+		ImportantDebug("Failed to number call check, %v", err)
+		return err
+	}
+	check.ID = id
+
+	var to string
+	if check.To != nil {
+		to = check.To.Hex()
+	}
+	checker.write("store call check", "insert into CALL_CHECK(id, block, origin, recipient, input_hash, mode, ecf, violations, time) values(?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		check.ID, check.Block, check.From.Hex(), to, check.InputHash.Hex(), check.Mode, check.ECF, check.Violations, check.Time)
+	return nil
+}
+
+// RecentCallChecks returns the last n stored call verdicts, the most recent first
+func (checker *Checker) RecentCallChecks(n int) ([]*CallCheck, error) {
+	checks := make([]*CallCheck, 0)
+	if checker.dbHandler == nil {
+		return checks, nil
+	}
+	if err := checker.SyncFindings(); err != nil {
+		return nil, err
+	}
+
+	rows, err := checker.dbHandler.Query("select id, block, origin, recipient, input_hash, mode, ecf, violations, time from CALL_CHECK order by id desc limit ?", n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			check               CallCheck
+			from, to, inputHash string
+		)
+		if err := rows.Scan(&check.ID, &check.Block, &from, &to, &inputHash, &check.Mode, &check.ECF, &check.Violations, &check.Time); err != nil {
+			return nil, err
+		}
+		check.From = common.HexToAddress(from)
+		if to != "" {
+			recipient := common.HexToAddress(to)
+			check.To = &recipient
+		}
+		check.InputHash = common.HexToHash(inputHash)
+		checks = append(checks, &check)
+	}
+	return checks, rows.Err()
+}
@@ -0,0 +1,111 @@
+// Shelly
+
+package vm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExecutionContext tells what a transaction is run for. The same transaction usually goes through the checker several
+// times, e.g. when mined into a pending block and when the sealed block is imported.
+type ExecutionContext int
+
+const (
+	ExecUnknown ExecutionContext = iota // Not labelled by the entry point
+	ExecImport                          // Processing a block being inserted into the chain
+	ExecMine                            // Building a block to mine
+	ExecPoolSim                         // Simulating pending transactions, e.g. in the simulated backend
+	ExecCall                            // eth_call, eth_estimateGas and ecf_checkCall
+	ExecTrace                           // Replaying a transaction or block for tracing or re-checking
+	ExecScan                            // geth ecfscan
+)
+
+var executionContextNames = []string{"unknown", "import", "mine", "pool-sim", "call", "trace", "scan"}
+
+func (context ExecutionContext) String() string {
+	if context >= 0 && int(context) < len(executionContextNames) {
+		return executionContextNames[context]
+	}
+	return fmt.Sprintf("ExecutionContext(%d)", int(context))
+}
+
+// ParseExecutionContext converts a context name as given on the command line or over RPC to an ExecutionContext
+func ParseExecutionContext(name string) (ExecutionContext, error) {
+	for context, contextName := range executionContextNames {
+		if strings.ToLower(name) == contextName {
+			return ExecutionContext(context), nil
+		}
+	}
+	return ExecUnknown, fmt.Errorf("unknown execution context %q (want one of %s)", name, strings.Join(executionContextNames, ", "))
+}
+
+// ParseExecutionContexts converts a comma separated list of context names
+func ParseExecutionContexts(names string) ([]ExecutionContext, error) {
+	contexts := make([]ExecutionContext, 0)
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		context, err := ParseExecutionContext(name)
+		if err != nil {
+			return nil, err
+		}
+		contexts = append(contexts, context)
+	}
+	return contexts, nil
+}
+
+// SetPersistedContexts limits the findings stored in ecf.db (violations and call checks) to those of transactions run
+// in the given contexts, e.g. only import to keep a single row per violation of the chain. No contexts stores all.
+func (checker *Checker) SetPersistedContexts(contexts []ExecutionContext) {
+	if len(contexts) == 0 {
+		checker.persistedContexts = nil
+		return
+	}
+	checker.persistedContexts = make(map[ExecutionContext]bool)
+	for _, context := range contexts {
+		checker.persistedContexts[context] = true
+	}
+}
+
+// PersistedContexts returns the contexts whose findings are stored, nil if all are
+func (checker *Checker) PersistedContexts() []ExecutionContext {
+	if checker.persistedContexts == nil {
+		return nil
+	}
+	contexts := make([]ExecutionContext, 0, len(checker.persistedContexts))
+	for context := range executionContextNames {
+		if checker.persistedContexts[ExecutionContext(context)] {
+			contexts = append(contexts, ExecutionContext(context))
+		}
+	}
+	return contexts
+}
+
+// persists reports whether the findings of a transaction run in the given context are stored
+func (checker *Checker) persists(context ExecutionContext) bool {
+	return checker.persistedContexts == nil || checker.persistedContexts[context]
+}
+
+// counts reports whether a transaction run in the given context is counted in the stats, contract profiles and recent
+// violations, which feed the RPC APIs, ethstats and the monitor. These have no context label, so without a policy
+// only imported transactions are counted, each of which is usually also mined, simulated or called before.
+func (checker *Checker) counts(context ExecutionContext) bool {
+	if checker.persistedContexts == nil {
+		return context == ExecImport
+	}
+	return checker.persistedContexts[context]
+}
+
+func (checker *Checker) persistedContextNames() []string {
+	contexts := checker.PersistedContexts()
+	if contexts == nil {
+		return nil
+	}
+	names := make([]string, len(contexts))
+	for i, context := range contexts {
+		names[i] = context.String()
+	}
+	return names
+}
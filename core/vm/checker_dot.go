@@ -0,0 +1,225 @@
+// Shelly
+
+package vm
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	set "gopkg.in/fatih/set.v0"
+)
+
+const dotMaxLocations = 8 // Locations listed per read or write set in a segment's node, the rest are counted
+
+// violatingSubtrace is the minimal recursive subtrace a violation was found in
+type violatingSubtrace struct {
+	segments  []int             // The indexInTransaction of its segments
+	conflicts []segmentConflict // The accesses keeping its inner segments from moving out of the outer call
+}
+
+// segmentConflict is a pair of conflicting segments of a subtrace, one of the outer call and one of an inner call
+type segmentConflict struct {
+	from, to  int // The indexInTransaction of the segments, in the order they ran
+	locations []common.Hash
+}
+
+// describeSubtrace records which segments a minimal recursive subtrace is made of, and the locations its outer and
+// inner segments conflict on
+func describeSubtrace(subtrace []Segment) violatingSubtrace {
+	described := violatingSubtrace{segments: make([]int, len(subtrace))}
+	for i, segment := range subtrace {
+		described.segments[i] = segment.indexInTransaction
+	}
+	base := subtrace[0].depth
+	for _, outer := range subtrace {
+		if outer.depth != base {
+			continue
+		}
+		for _, inner := range subtrace {
+			if inner.depth == base || !segmentsConflict(outer, inner) {
+				continue
+			}
+			locations := set.Union(set.Intersection(outer.readSet, inner.writeSet), set.Intersection(outer.writeSet, inner.readSet))
+			conflict := segmentConflict{from: outer.indexInTransaction, to: inner.indexInTransaction, locations: sortedLocations(locations)}
+			if conflict.from > conflict.to {
+				conflict.from, conflict.to = conflict.to, conflict.from
+			}
+			described.conflicts = append(described.conflicts, conflict)
+		}
+	}
+	sort.Sort(conflictsByIndex(described.conflicts))
+	return described
+}
+
+type conflictsByIndex []segmentConflict
+
+func (c conflictsByIndex) Len() int { return len(c) }
+func (c conflictsByIndex) Less(i, j int) bool {
+	return c[i].from < c[j].from || (c[i].from == c[j].from && c[i].to < c[j].to)
+}
+func (c conflictsByIndex) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+
+// DOT renders the trace as a Graphviz graph: one lane per contract, one node per segment ordered by index in the
+// transaction, and the call and return edges between them. If result is the check of the trace, the segments of the
+// minimal recursive subtrace of each violation are highlighted, along with the locations they conflict on.
+func (trace *SegmentTrace) DOT(result *ECFResult) string {
+	var (
+		highlighted = make(map[uint64]bool)
+		conflicting = make(map[uint64]map[common.Hash]bool)
+		conflicts   []segmentConflict
+	)
+	if result != nil {
+		for _, subtrace := range result.subtraces {
+			for _, index := range subtrace.segments {
+				highlighted[uint64(index)] = true
+			}
+			for _, conflict := range subtrace.conflicts {
+				for _, index := range []int{conflict.from, conflict.to} {
+					if conflicting[uint64(index)] == nil {
+						conflicting[uint64(index)] = make(map[common.Hash]bool)
+					}
+					for _, location := range conflict.locations {
+						conflicting[uint64(index)][location] = true
+					}
+				}
+			}
+			conflicts = append(conflicts, subtrace.conflicts...)
+		}
+	}
+
+	var dot bytes.Buffer
+	fmt.Fprintf(&dot, "digraph \"ecf\" {\n")
+	fmt.Fprintf(&dot, "\trankdir=LR;\n\tlabelloc=t;\n\tnode [shape=box, fontname=\"monospace\", fontsize=10];\n\tedge [fontname=\"monospace\", fontsize=9];\n")
+	fmt.Fprintf(&dot, "\tlabel=\"%s\";\n", dotEscape(trace.dotTitle(result)))
+
+	// One lane per contract, in the order the contracts are first called
+	lanes := make([]common.Address, 0)
+	segmentsOf := make(map[common.Address][]TraceSegment)
+	for _, segment := range trace.Segments {
+		if _, ok := segmentsOf[segment.Contract]; !ok {
+			lanes = append(lanes, segment.Contract)
+		}
+		segmentsOf[segment.Contract] = append(segmentsOf[segment.Contract], segment)
+	}
+	for i, contract := range lanes {
+		fmt.Fprintf(&dot, "\tsubgraph \"cluster_%d\" {\n\t\tlabel=\"%s\";\n\t\tstyle=rounded;\n", i, contract.Hex())
+		for _, segment := range segmentsOf[contract] {
+			attributes := ""
+			if highlighted[segment.IndexInTransaction] {
+				attributes = ", color=red, penwidth=2, style=filled, fillcolor=\"#ffe0e0\""
+			}
+			fmt.Fprintf(&dot, "\t\t\"s%d\" [label=\"%s\"%s];\n", segment.IndexInTransaction, dotEscape(segment.dotLabel(conflicting[segment.IndexInTransaction])), attributes)
+		}
+		fmt.Fprintf(&dot, "\t}\n")
+	}
+
+	// Each segment but the first is opened by a call from the previous one or a return to it
+	for i := 1; i < len(trace.Segments); i++ {
+		previous, segment := trace.Segments[i-1], trace.Segments[i]
+		label := "return"
+		if segment.Depth > previous.Depth {
+			label = "call"
+			if segment.Call != nil {
+				label = fmt.Sprintf("call %x", []byte(segment.Call.Selector))
+				if segment.Call.Value != nil && segment.Call.Value.Sign() > 0 {
+					label += fmt.Sprintf("\nvalue %v", segment.Call.Value)
+				}
+			}
+		}
+		fmt.Fprintf(&dot, "\t\"s%d\" -> \"s%d\" [label=\"%s\"];\n", previous.IndexInTransaction, segment.IndexInTransaction, dotEscape(label))
+	}
+	for _, conflict := range conflicts {
+		fmt.Fprintf(&dot, "\t\"s%d\" -> \"s%d\" [label=\"%s\", color=red, fontcolor=red, style=dashed, dir=none, constraint=false];\n", conflict.from, conflict.to, dotEscape(dotLocations(conflict.locations, nil)))
+	}
+	fmt.Fprintf(&dot, "}\n")
+	return dot.String()
+}
+
+// dotTitle describes the transaction and the verdict on it
+func (trace *SegmentTrace) dotTitle(result *ECFResult) string {
+	title := fmt.Sprintf("block %d, origin %s", trace.Block, trace.Origin.Hex())
+	if trace.Transaction != (common.Hash{}) {
+		title = fmt.Sprintf("transaction %s, %s", trace.Transaction.Hex(), title)
+	}
+	if result == nil {
+		return title
+	}
+	if !result.IsConclusive() {
+		title = fmt.Sprintf("%s\ninconclusive, %s limit exceeded (%v mode)", title, result.Inconclusive, result.Mode)
+	} else if result.IsECF() {
+		title = fmt.Sprintf("%s\nECF (%v mode)", title, result.Mode)
+	} else {
+		title = fmt.Sprintf("%s\nnot ECF (%v mode)", title, result.Mode)
+	}
+	for _, violation := range result.Violations {
+		title += fmt.Sprintf("\nviolation on %s, depth %d, segments from #%d, length %d", violation.Contract.Hex(), violation.Depth, violation.StartIndex, violation.Length)
+		if violation.Code != nil {
+			title += fmt.Sprintf(", through code %s", violation.Code.Hex())
+		}
+		if violation.Exempt {
+			title += ", exempt"
+		}
+	}
+	return title
+}
+
+// dotLabel lists the segment's position and accesses, marking the conflicting locations with a !
+func (segment TraceSegment) dotLabel(conflicting map[common.Hash]bool) string {
+	label := fmt.Sprintf("#%d depth %d part %d", segment.IndexInTransaction, segment.Depth, segment.IndexInCall)
+	if len(segment.Reads) > 0 {
+		label += "\nR " + dotLocations(segment.Reads, conflicting)
+	}
+	if len(segment.Writes) > 0 {
+		label += "\nW " + dotLocations(segment.Writes, conflicting)
+	}
+	return label
+}
+
+// dotLocations lists the locations in short form
+func dotLocations(locations []common.Hash, conflicting map[common.Hash]bool) string {
+	listed := ""
+	for i, location := range locations {
+		if i == dotMaxLocations {
+			listed += fmt.Sprintf(" +%d", len(locations)-i)
+			break
+		}
+		if i > 0 {
+			listed += " "
+		}
+		hex := location.Hex()
+		listed += hex[:6] + ".." + hex[len(hex)-4:]
+		if conflicting[location] {
+			listed += "!"
+		}
+	}
+	return listed
+}
+
+// dotEscape quotes a label for a DOT string, keeping its line breaks
+func dotEscape(label string) string {
+	var escaped bytes.Buffer
+	for _, c := range label {
+		switch c {
+		case '"', '\\':
+			escaped.WriteRune('\\')
+			escaped.WriteRune(c)
+		case '\n':
+			escaped.WriteString("\\n")
+		default:
+			escaped.WriteRune(c)
+		}
+	}
+	return escaped.String()
+}
+
+// LastTraceDOT renders the last transaction that ran code as a Graphviz graph, highlighting the violations found in
+// it, or returns an empty string if there is none
+func (checker *Checker) LastTraceDOT() string {
+	trace := checker.LastTrace()
+	if trace == nil {
+		return ""
+	}
+	return trace.DOT(checker.lastResult)
+}
@@ -0,0 +1,177 @@
+// Shelly
+
+package vm
+
+import (
+	"time"
+
+	set "gopkg.in/fatih/set.v0"
+)
+
+const (
+	defaultExactMaxSegments = 64
+	defaultExactTimeout     = time.Second
+)
+
+type exactSearchOutcome int
+
+const (
+	exactSearchFound    exactSearchOutcome = iota // A callback free serialization exists
+	exactSearchNotFound                           // Every serialization was ruled out
+	exactSearchAborted                            // The search ran out of its segment or time budget
+)
+
+// segmentsConflict reports whether two segments may not be swapped. This is the negation of condition 1 in checkLeftMove,
+// so that the exact search and the heuristic agree on which segments commute.
+func segmentsConflict(a Segment, b Segment) bool {
+	return !(set.Intersection(a.readSet, b.writeSet)).IsEmpty() || !(set.Intersection(a.writeSet, b.readSet)).IsEmpty()
+}
+
+// splitIntoCalls groups the indices of the trace by the call they belong to, in order of the calls' opening segments
+func splitIntoCalls(trace []Segment) [][]int {
+	calls := make([][]int, 0)
+	openCallAtDepth := make(map[int]int) // depth -> index in calls of the last call opened in that depth
+
+	for i := range trace {
+		call, ok := openCallAtDepth[trace[i].depth]
+		if isOpeningSegment(trace[i]) || !ok { // A continuation whose opening segment is not in the trace is a call of its own
+			openCallAtDepth[trace[i].depth] = len(calls)
+			calls = append(calls, []int{i})
+		} else {
+			calls[call] = append(calls[call], i)
+		}
+	}
+
+	return calls
+}
+
+// exactSearch is a depth first search for an order of the calls of a trace, such that placing each call's segments
+// contiguously in that order keeps every pair of conflicting segments in its original order
+type exactSearch struct {
+	calls       [][]int
+	mustPrecede [][]bool // mustPrecede[u][v] is set if a segment of call u precedes a conflicting segment of call v
+
+	placed   []bool
+	order    []int
+	deadEnds map[string]bool // Sets of placed calls from which no serialization can be completed
+
+	deadline time.Time
+	aborted  bool
+}
+
+func (search *exactSearch) placedKey() string {
+	key := make([]byte, len(search.placed))
+	for i := range search.placed {
+		key[i] = '0'
+		if search.placed[i] {
+			key[i] = '1'
+		}
+	}
+	return string(key)
+}
+
+func (search *exactSearch) canPlace(call int) bool {
+	for other := range search.calls {
+		if other != call && !search.placed[other] && search.mustPrecede[other][call] {
+			return false
+		}
+	}
+	return true
+}
+
+func (search *exactSearch) extend() bool {
+	if len(search.order) == len(search.calls) {
+		return true
+	}
+	if !search.deadline.IsZero() && time.Now().After(search.deadline) {
+		search.aborted = true
+		return false
+	}
+
+	key := search.placedKey()
+	if search.deadEnds[key] {
+		return false
+	}
+
+	for call := range search.calls {
+		if search.placed[call] || !search.canPlace(call) {
+			continue
+		}
+
+		search.placed[call] = true
+		search.order = append(search.order, call)
+		if search.extend() {
+			return true
+		}
+		search.placed[call] = false
+		search.order = search.order[:len(search.order)-1]
+
+		if search.aborted {
+			return false
+		}
+	}
+
+	search.deadEnds[key] = true
+	return false
+}
+
+// findCallbackFreeSerialization searches the serializations of a recursive subtrace in which the segments of every
+// call are contiguous (i.e. there is no recursion) and every two conflicting segments keep their original order.
+// Unlike attemptToRemoveRecursion it is not limited to moving the inner segments around a single cutpoint.
+// A maxSegments or timeout of 0 leaves the corresponding limit off.
+func findCallbackFreeSerialization(trace []Segment, maxSegments int, timeout time.Duration) ([]Segment, exactSearchOutcome) {
+	if maxSegments > 0 && len(trace) > maxSegments {
+		Debug(2, "Exact search skipped, %v segments is more than the limit %v", len(trace), maxSegments)
+		return nil, exactSearchAborted
+	}
+
+	calls := splitIntoCalls(trace)
+	callOf := make([]int, len(trace))
+	for call := range calls {
+		for _, idx := range calls[call] {
+			callOf[idx] = call
+		}
+	}
+
+	mustPrecede := make([][]bool, len(calls))
+	for call := range mustPrecede {
+		mustPrecede[call] = make([]bool, len(calls))
+	}
+	for i := range trace {
+		for j := i + 1; j < len(trace); j++ {
+			if callOf[i] != callOf[j] && segmentsConflict(trace[i], trace[j]) {
+				mustPrecede[callOf[i]][callOf[j]] = true
+			}
+		}
+	}
+
+	search := &exactSearch{
+		calls:       calls,
+		mustPrecede: mustPrecede,
+		placed:      make([]bool, len(calls)),
+		order:       make([]int, 0, len(calls)),
+		deadEnds:    make(map[string]bool),
+	}
+	if timeout > 0 {
+		search.deadline = time.Now().Add(timeout)
+	}
+
+	if !search.extend() {
+		if search.aborted {
+			Debug(2, "Exact search timed out after %v on %v", timeout, trace)
+			return nil, exactSearchAborted
+		}
+		Debug(2, "Exact search found no callback free serialization of %v", trace)
+		return nil, exactSearchNotFound
+	}
+
+	serialization := make([]Segment, 0, len(trace))
+	for _, call := range search.order {
+		for _, idx := range calls[call] {
+			serialization = append(serialization, trace[idx])
+		}
+	}
+	Debug(2, "Exact search found serialization %v of %v", serialization, trace)
+
+	return serialization, exactSearchFound
+}
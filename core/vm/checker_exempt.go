@@ -0,0 +1,64 @@
+// Shelly
+
+package vm
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// exemptionCheck reports whether a method of a contract is exempt from the checker
+type exemptionCheck func(contract common.Address, selector [4]byte) bool
+
+// ExemptionSlot returns the storage slot holding the expiry block of the exemption of a method in the ECFExemptions
+// registry (contracts/ecfexempt), i.e. of expiries[contract][selector], the mapping being in slot 0
+func ExemptionSlot(contract common.Address, selector [4]byte) common.Hash {
+	inner := crypto.Keccak256(common.LeftPadBytes(contract[:], 32), make([]byte, 32))
+	return common.BytesToHash(crypto.Keccak256(common.RightPadBytes(selector[:], 32), inner))
+}
+
+// ExemptionExpiry returns the last block the registry exempts the method of the contract in, 0 if it does not
+func ExemptionExpiry(state StateDB, registry common.Address, contract common.Address, selector [4]byte) uint64 {
+	expiry := state.GetState(registry, ExemptionSlot(contract, selector)).Big()
+	if !expiry.IsUint64() {
+		return ^uint64(0)
+	}
+	return expiry.Uint64()
+}
+
+// SetExemptionRegistry sets the ECFExemptions registry the checker consults, or none if nil. The violations of the
+// exempt methods are still reported, marked as exempt, and do not make a transaction non ECF.
+func (checker *Checker) SetExemptionRegistry(registry *common.Address) {
+	checker.exemptionRegistry = registry
+	if registry != nil {
+		Debug(1, "ECF exemption registry is %v", registry.Hex())
+	}
+}
+
+// ExemptionRegistry returns the ECFExemptions registry the checker consults, or nil if none
+func (checker *Checker) ExemptionRegistry() *common.Address {
+	return checker.exemptionRegistry
+}
+
+// exemptionCheck looks up the registry at the state the transaction ran on, or returns nil if none is consulted
+func (checker *Checker) exemptionCheck(env *EVM) exemptionCheck {
+	registry := checker.exemptionRegistry
+	if registry == nil || env.StateDB == nil || env.BlockNumber == nil {
+		return nil
+	}
+	block := env.BlockNumber.Uint64()
+	return func(contract common.Address, selector [4]byte) bool {
+		expiry := ExemptionExpiry(env.StateDB, *registry, contract, selector)
+		return expiry != 0 && expiry >= block
+	}
+}
+
+// subtraceSelector returns the selector of the call opening a minimal recursive subtrace, zero padded if the input of
+// the call was shorter
+func subtraceSelector(subtrace []Segment) [4]byte {
+	var selector [4]byte
+	if call := subtrace[0].call; call != nil {
+		copy(selector[:], call.Selector)
+	}
+	return selector
+}
@@ -0,0 +1,189 @@
+// Shelly
+
+package vm
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	defaultMaxSegments  = 10000   // Segments of a transaction the checker checks, 0 = unlimited
+	defaultMaxLocations = 100000  // Locations read and written by all segments of a transaction, 0 = unlimited
+	defaultMaxCheckTime = 0       // Time spent checking a transaction, 0 = unlimited. Off so verdicts do not depend on the machine
+	defaultMaxCheckWork = 1000000 // Segments visited while checking a transaction, 0 = unlimited
+)
+
+// The reasons for an inconclusive verdict, i.e. the limit the transaction exceeded
+const (
+	InconclusiveSegments  = "segments"
+	InconclusiveLocations = "locations"
+	InconclusiveTime      = "time"
+	InconclusiveWork      = "work"
+)
+
+// SetLimits bounds the check of a single transaction. A transaction with more than maxSegments segments, or whose
+// segments access more than maxLocations locations in total, is not checked, and a check visiting more than
+// maxCheckWork segments or taking longer than maxCheckTime is stopped. Such transactions get an inconclusive verdict.
+// A limit of 0 is off.
+func (checker *Checker) SetLimits(maxSegments, maxLocations, maxCheckWork int, maxCheckTime time.Duration) {
+	checker.maxSegments = maxSegments
+	checker.maxLocations = maxLocations
+	checker.maxCheckWork = maxCheckWork
+	checker.maxCheckTime = maxCheckTime
+}
+
+// exceededLimit returns the reason the segments are too large to be checked, or an empty string if they are not
+func (checker *Checker) exceededLimit(segments []Segment) string {
+	if checker.maxSegments > 0 && len(segments) > checker.maxSegments {
+		return InconclusiveSegments
+	}
+	if checker.maxLocations > 0 {
+		locations := 0
+		for i := range segments {
+			locations += segments[i].readSet.Size() + segments[i].writeSet.Size()
+			if locations > checker.maxLocations {
+				return InconclusiveLocations
+			}
+		}
+	}
+	return ""
+}
+
+// checkBudget is the work and time left to check a transaction
+type checkBudget struct {
+	work     *int      // Segments visited so far by all steps of the check, nil if the work is unlimited
+	maxWork  int       // Segments the check may visit
+	deadline time.Time // Zero if the check time is unlimited
+}
+
+func (checker *Checker) newCheckBudget() checkBudget {
+	budget := checkBudget{}
+	if checker.maxCheckWork > 0 {
+		budget.work = new(int)
+		budget.maxWork = checker.maxCheckWork
+	}
+	if checker.maxCheckTime > 0 {
+		budget.deadline = time.Now().Add(checker.maxCheckTime)
+	}
+	return budget
+}
+
+// spend counts a step visiting the given number of segments, and reports whether the check ran out of budget
+func (budget checkBudget) spend(segments int) bool {
+	if budget.work != nil {
+		*budget.work += segments
+	}
+	return budget.exhausted()
+}
+
+// exhausted reports whether the check ran out of work or time
+func (budget checkBudget) exhausted() bool {
+	return budget.reason() != ""
+}
+
+// reason returns the inconclusive reason of an exhausted budget, or an empty string if it is not exhausted
+func (budget checkBudget) reason() string {
+	if budget.work != nil && *budget.work > budget.maxWork {
+		return InconclusiveWork
+	}
+	if !budget.deadline.IsZero() && !time.Now().Before(budget.deadline) {
+		return InconclusiveTime
+	}
+	return ""
+}
+
+// bound shortens a timeout, 0 being unlimited, to the time left
+func (budget checkBudget) bound(timeout time.Duration) time.Duration {
+	if budget.deadline.IsZero() {
+		return timeout
+	}
+	left := budget.deadline.Sub(time.Now())
+	if left <= 0 {
+		left = time.Nanosecond
+	}
+	if timeout <= 0 || left < timeout {
+		return left
+	}
+	return timeout
+}
+
+// InconclusiveCheck is a transaction the checker gave up on, as recorded in the INCONCLUSIVE_CHECK table
+type InconclusiveCheck struct {
+	TransactionID int            `json:"transactionId"`
+	Origin        common.Address `json:"origin"`
+	Block         uint64         `json:"block"`
+	BlockHash     common.Hash    `json:"blockHash"`
+	TxHash        common.Hash    `json:"txHash"`
+	Time          uint64         `json:"time"`
+	Canonical     bool           `json:"canonical"`
+	Context       string         `json:"context"`
+	Reason        string         `json:"reason"`
+	Segments      int            `json:"segments"`
+}
+
+// reportInconclusive stores the inconclusive verdict of the running transaction
+func (checker *Checker) reportInconclusive(result *ECFResult) {
+	ImportantDebug("Transaction check is inconclusive (%v), the %v limit was exceeded with %v segments", checker.context, result.Inconclusive, result.Segments)
+	if checker.dbHandler == nil || !checker.persists(checker.context) {
+		return
+	}
+	check := InconclusiveCheck{TransactionID: checker.TransactionID}
+	if checker.origin != nil {
+		check.Origin = *checker.origin
+	}
+	if checker.blockNumber != nil {
+		check.Block = checker.blockNumber.Uint64()
+	}
+	if checker.time != nil {
+		check.Time = checker.time.Uint64()
+	}
+	// Rows start out non-canonical, like the violations, see MarkBlock
+	checker.write("store inconclusive check", "insert into INCONCLUSIVE_CHECK(id, origin, block, time, tx_hash, block_hash, canonical, context, reason, segments) values(?, ?, ?, ?, ?, ?, 0, ?, ?, ?)",
+		check.TransactionID,
+		check.Origin.Hex(),
+		check.Block,
+		check.Time,
+		hashColumn(checker.txHash),
+		hashColumn(checker.blockHash),
+		checker.context.String(),
+		result.Inconclusive,
+		result.Segments)
+}
+
+// InconclusiveChecks returns the last n stored inconclusive verdicts, the most recent first
+func (checker *Checker) InconclusiveChecks(n int) ([]*InconclusiveCheck, error) {
+	checks := make([]*InconclusiveCheck, 0)
+	if checker.dbHandler == nil {
+		return checks, nil
+	}
+	if err := checker.SyncFindings(); err != nil {
+		return nil, err
+	}
+
+	rows, err := checker.dbHandler.Query("select id, origin, block, block_hash, tx_hash, time, canonical, context, reason, segments from INCONCLUSIVE_CHECK order by rowid desc limit ?", n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			check                     InconclusiveCheck
+			origin, blockHash, txHash string
+		)
+		if err := rows.Scan(&check.TransactionID, &origin, &check.Block, &blockHash, &txHash, &check.Time, &check.Canonical, &check.Context, &check.Reason, &check.Segments); err != nil {
+			return nil, err
+		}
+		check.Origin = common.HexToAddress(origin)
+		if blockHash != "" {
+			check.BlockHash = common.HexToHash(blockHash)
+		}
+		if txHash != "" {
+			check.TxHash = common.HexToHash(txHash)
+		}
+		checks = append(checks, &check)
+	}
+	return checks, rows.Err()
+}
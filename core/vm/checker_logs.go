@@ -0,0 +1,29 @@
+// Shelly
+
+package vm
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var eventStreamPrefix = []byte("ecf-event-stream-")
+
+// EventStreamLocation returns the pseudo storage location standing for the stream of events a contract emits. It is
+// the hash of a prefix and the contract's address, which no Solidity storage slot is.
+func EventStreamLocation(contract common.Address) common.Hash {
+	return crypto.Keccak256Hash(eventStreamPrefix, contract[:])
+}
+
+// SetLogsAsWrites turns treating emitted logs as accesses to the event stream of the emitting contract on or off.
+// Emitting a log then both reads and writes the stream, so that two segments emitting logs conflict, and callbacks
+// that would change the order in which off-chain observers see the events make a transaction non ECF.
+func (checker *Checker) SetLogsAsWrites(enabled bool) {
+	checker.logsAsWrites = enabled
+	Debug(1, "ECF logs as writes is set to: %v", enabled)
+}
+
+// LogsAsWrites returns whether emitted logs are treated as accesses to the event stream of the emitting contract
+func (checker *Checker) LogsAsWrites() bool {
+	return checker.logsAsWrites
+}
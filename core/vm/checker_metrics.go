@@ -0,0 +1,23 @@
+// Shelly
+
+package vm
+
+import (
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	ecfChecksMeter       = metrics.NewMeter("ecf/checks")
+	ecfViolationsMeter   = metrics.NewMeter("ecf/violations")
+	ecfStaticSkipsMeter  = metrics.NewMeter("ecf/static/skips")
+	ecfInconclusiveMeter = metrics.NewMeter("ecf/inconclusive")
+	ecfCheckTimer        = metrics.NewTimer("ecf/check")
+
+	// Background writer of the findings: writes queued, written and dropped, time spent waiting for room in the
+	// queue and time to commit a batch
+	ecfWriterQueuedMeter  = metrics.NewMeter("ecf/db/queued")
+	ecfWriterWrittenMeter = metrics.NewMeter("ecf/db/written")
+	ecfWriterFailedMeter  = metrics.NewMeter("ecf/db/failed")
+	ecfWriterStallTimer   = metrics.NewTimer("ecf/db/stall")
+	ecfWriterBatchTimer   = metrics.NewTimer("ecf/db/batch")
+)
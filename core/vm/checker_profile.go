@@ -0,0 +1,296 @@
+// Shelly
+
+package vm
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const profilesSaveInterval = time.Minute // Longest time a changed contract profile waits to be queued for writing
+
+const saveProfileStmt = "insert or replace into CONTRACT_PROFILE(contract, transactions, reentered, omittable, reordered, violations, first_block, last_block) values(?, ?, ?, ?, ?, ?, ?, ?)"
+
+// ContractProfile aggregates what the checker saw of a single contract over all transactions it checked
+type ContractProfile struct {
+	Contract          common.Address `json:"contract"`
+	Transactions      uint64         `json:"transactions"`      // Transactions in which the contract ran
+	Reentered         uint64         `json:"reentered"`         // Calls to the contract made while another call to it was running
+	OmittableRemoved  uint64         `json:"omittableRemoved"`  // Calls removed from recursive traces for having no writes
+	RepairedByReorder uint64         `json:"repairedByReorder"` // Minimal recursive subtraces shown ECF by reordering
+	Violations        uint64         `json:"violations"`
+	FirstSeenBlock    uint64         `json:"firstSeenBlock"`
+	LastSeenBlock     uint64         `json:"lastSeenBlock"`
+}
+
+// ContractProfileMetrics are the metric names by which contracts may be ranked
+var ContractProfileMetrics = []string{"transactions", "reentered", "omittable", "reordered", "violations"}
+
+func (profile *ContractProfile) metric(name string) (uint64, error) {
+	switch name {
+	case "transactions":
+		return profile.Transactions, nil
+	case "reentered":
+		return profile.Reentered, nil
+	case "omittable":
+		return profile.OmittableRemoved, nil
+	case "reordered":
+		return profile.RepairedByReorder, nil
+	case "violations":
+		return profile.Violations, nil
+	}
+	return 0, fmt.Errorf("unknown metric %q (want one of %s)", name, strings.Join(ContractProfileMetrics, ", "))
+}
+
+// profilesByMetric sorts profiles by descending value of a metric, breaking ties by address
+type profilesByMetric struct {
+	profiles []*ContractProfile
+	metric   string
+}
+
+func (s profilesByMetric) Len() int      { return len(s.profiles) }
+func (s profilesByMetric) Swap(i, j int) { s.profiles[i], s.profiles[j] = s.profiles[j], s.profiles[i] }
+func (s profilesByMetric) Less(i, j int) bool {
+	vi, _ := s.profiles[i].metric(s.metric)
+	vj, _ := s.profiles[j].metric(s.metric)
+	if vi != vj {
+		return vi > vj
+	}
+	return s.profiles[i].Contract.Hex() < s.profiles[j].Contract.Hex()
+}
+
+// traceCheckCounters counts what the check of a single projected trace went through
+type traceCheckCounters struct {
+	reentries         int
+	omittableRemoved  int
+	repairedByReorder int
+	violations        int
+}
+
+// contractProfiles is the checker's table of profiles. It is read by the RPC handlers while transactions are checked.
+type contractProfiles struct {
+	lock     sync.RWMutex
+	profiles map[common.Address]*ContractProfile
+	loaded   bool // Whether the profiles stored in the database were read
+
+	dirty  map[common.Address]bool // The profiles changed since they were last queued for writing
+	queued time.Time               // When the changed profiles were last queued
+}
+
+// countReentries returns the number of calls in a projected trace that started while another call to the same
+// contract was still running
+func countReentries(projection []Segment) int {
+	reentries := 0
+	runningDepths := make([]int, 0) // Depths of the calls that are running, the innermost last
+
+	for i := range projection {
+		depth := projection[i].depth
+		// Reaching depth d means all calls deeper than d have returned, and a new call in depth d ends the previous one
+		for len(runningDepths) > 0 && (runningDepths[len(runningDepths)-1] > depth || (isOpeningSegment(projection[i]) && runningDepths[len(runningDepths)-1] == depth)) {
+			runningDepths = runningDepths[:len(runningDepths)-1]
+		}
+		if isOpeningSegment(projection[i]) {
+			if len(runningDepths) > 0 {
+				reentries++
+			}
+			runningDepths = append(runningDepths, depth)
+		}
+	}
+
+	return reentries
+}
+
+// updateProfiles adds the counters of a checked transaction to the profiles of the contracts that took part in it
+func (checker *Checker) updateProfiles(counters map[common.Address]*traceCheckCounters, blockNumber *big.Int) {
+	checker.profiles.lock.Lock()
+	defer checker.profiles.lock.Unlock()
+
+	if checker.profiles.profiles == nil {
+		checker.profiles.profiles = make(map[common.Address]*ContractProfile)
+	}
+	if checker.profiles.dirty == nil {
+		checker.profiles.dirty = make(map[common.Address]bool)
+	}
+	var block uint64
+	if blockNumber != nil {
+		block = blockNumber.Uint64()
+	}
+	for contract, counter := range counters {
+		profile := checker.profiles.profiles[contract]
+		if profile == nil {
+			profile = &ContractProfile{Contract: contract, FirstSeenBlock: block}
+			checker.profiles.profiles[contract] = profile
+		}
+		profile.Transactions++
+		profile.Reentered += uint64(counter.reentries)
+		profile.OmittableRemoved += uint64(counter.omittableRemoved)
+		profile.RepairedByReorder += uint64(counter.repairedByReorder)
+		profile.Violations += uint64(counter.violations)
+		if block < profile.FirstSeenBlock {
+			profile.FirstSeenBlock = block
+		}
+		if block > profile.LastSeenBlock {
+			profile.LastSeenBlock = block
+		}
+		checker.profiles.dirty[contract] = true
+	}
+}
+
+// queueProfiles queues the profiles changed since they were last queued on the findings writer, at most once per
+// profilesSaveInterval. The profiles then include the given checked block, which is checkpointed along with them (see
+// BlockChecked), so that a crash re-counts no block and misses none. It returns false if the changed profiles were
+// not queued yet.
+func (checker *Checker) queueProfiles(checked uint64) bool {
+	if checker.dbHandler == nil {
+		return false
+	}
+	checker.profiles.lock.Lock()
+	if len(checker.profiles.dirty) > 0 && time.Since(checker.profiles.queued) < profilesSaveInterval {
+		checker.profiles.lock.Unlock()
+		return false
+	}
+	changed := make([]ContractProfile, 0, len(checker.profiles.dirty))
+	for contract := range checker.profiles.dirty {
+		changed = append(changed, *checker.profiles.profiles[contract])
+	}
+	if len(changed) > 0 {
+		checker.profiles.dirty = make(map[common.Address]bool)
+		checker.profiles.queued = time.Now()
+	}
+	checker.profiles.lock.Unlock()
+
+	checker.raiseBlock(&checker.checkedBlock, checked)
+	for _, profile := range changed {
+		checker.write("save contract profile", saveProfileStmt, profile.Contract.Hex(), profile.Transactions, profile.Reentered, profile.OmittableRemoved, profile.RepairedByReorder, profile.Violations, profile.FirstSeenBlock, profile.LastSeenBlock)
+	}
+	return true
+}
+
+// ContractProfile returns a copy of the profile of the given contract, or nil if the checker has not seen it
+func (checker *Checker) ContractProfile(contract common.Address) *ContractProfile {
+	checker.profiles.lock.RLock()
+	defer checker.profiles.lock.RUnlock()
+
+	profile, ok := checker.profiles.profiles[contract]
+	if !ok {
+		return nil
+	}
+	copied := *profile
+	return &copied
+}
+
+// TopContracts returns copies of the n profiles ranking highest by the given metric. Contracts with a zero metric are
+// left out.
+func (checker *Checker) TopContracts(metric string, n int) ([]*ContractProfile, error) {
+	if _, err := (&ContractProfile{}).metric(metric); err != nil {
+		return nil, err
+	}
+
+	checker.profiles.lock.RLock()
+	ranked := make([]*ContractProfile, 0, len(checker.profiles.profiles))
+	for _, profile := range checker.profiles.profiles {
+		if value, _ := profile.metric(metric); value > 0 {
+			copied := *profile
+			ranked = append(ranked, &copied)
+		}
+	}
+	checker.profiles.lock.RUnlock()
+
+	sort.Sort(profilesByMetric{ranked, metric})
+	if n >= 0 && len(ranked) > n {
+		ranked = ranked[:n]
+	}
+	return ranked, nil
+}
+
+// loadProfiles reads the stored profiles from the database, the first time a database is set
+func (checker *Checker) loadProfiles() {
+	checker.profiles.lock.Lock()
+	defer checker.profiles.lock.Unlock()
+
+	if checker.profiles.loaded || checker.dbHandler == nil {
+		return
+	}
+	if checker.profiles.profiles == nil {
+		checker.profiles.profiles = make(map[common.Address]*ContractProfile)
+	}
+
+	rows, err := checker.dbHandler.Query("select contract, transactions, reentered, omittable, reordered, violations, first_block, last_block from CONTRACT_PROFILE")
+	if err != nil {
+		ImportantDebug("Failed to read contract profiles, %v", err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			contract string
+			stored   ContractProfile
+		)
+		if err := rows.Scan(&contract, &stored.Transactions, &stored.Reentered, &stored.OmittableRemoved, &stored.RepairedByReorder, &stored.Violations, &stored.FirstSeenBlock, &stored.LastSeenBlock); err != nil {
+			ImportantDebug("Failed to read contract profile, %v", err)
+			continue
+		}
+		stored.Contract = common.HexToAddress(contract)
+
+		// Transactions may already have been checked before the database was set
+		if profile := checker.profiles.profiles[stored.Contract]; profile != nil {
+			stored.Transactions += profile.Transactions
+			stored.Reentered += profile.Reentered
+			stored.OmittableRemoved += profile.OmittableRemoved
+			stored.RepairedByReorder += profile.RepairedByReorder
+			stored.Violations += profile.Violations
+			if profile.FirstSeenBlock < stored.FirstSeenBlock {
+				stored.FirstSeenBlock = profile.FirstSeenBlock
+			}
+			if profile.LastSeenBlock > stored.LastSeenBlock {
+				stored.LastSeenBlock = profile.LastSeenBlock
+			}
+		}
+		checker.profiles.profiles[stored.Contract] = &stored
+	}
+	checker.profiles.loaded = true
+	Debug(1, "Loaded %d contract profiles", len(checker.profiles.profiles))
+}
+
+// SaveProfiles writes all contract profiles to the database, replacing the stored ones, and checkpoints the last block
+// they include. The changed profiles are also written while the checker runs, see queueProfiles.
+func (checker *Checker) SaveProfiles() error {
+	checker.profiles.lock.RLock()
+	defer checker.profiles.lock.RUnlock()
+
+	if checker.dbHandler == nil {
+		return nil
+	}
+	tx, err := checker.dbHandler.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(saveProfileStmt)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, profile := range checker.profiles.profiles {
+		if _, err := stmt.Exec(profile.Contract.Hex(), profile.Transactions, profile.Reentered, profile.OmittableRemoved, profile.RepairedByReorder, profile.Violations, profile.FirstSeenBlock, profile.LastSeenBlock); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if passed := atomic.LoadUint64(&checker.passedBlock); passed > 0 {
+		if _, err := tx.Exec(checkpointBlockStmt, passed, passed); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
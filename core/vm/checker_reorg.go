@@ -0,0 +1,69 @@
+// Shelly
+
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SetTransactionContext tells the checker which transaction is about to be applied, and the block it is in. The block
+// hash is zero while the block is not sealed yet, e.g. when mining. Violations found are stored with both, so they can
+// be followed through chain reorganisations.
+func (checker *Checker) SetTransactionContext(txHash, blockHash common.Hash) {
+	checker.txHash = txHash
+	checker.blockHash = blockHash
+}
+
+// hashColumn is how a hash is stored in ecf.db, empty if it is not known
+func hashColumn(hash common.Hash) string {
+	if hash == (common.Hash{}) {
+		return ""
+	}
+	return hash.Hex()
+}
+
+// MarkBlock records whether the violations and inconclusive checks found in the block are part of the canonical chain. It is called when the
+// block is written to the chain, and for each block a reorganisation adds to or removes from the canonical chain.
+func (checker *Checker) MarkBlock(blockHash common.Hash, canonical bool) {
+	if checker.dbHandler == nil {
+		return
+	}
+	checker.write("mark the violations of block "+blockHash.Hex(), "update NON_REENTRANT_TRACE set canonical = ? where block_hash = ?", canonical, blockHash.Hex())
+	checker.write("mark the inconclusive checks of block "+blockHash.Hex(), "update INCONCLUSIVE_CHECK set canonical = ? where block_hash = ?", canonical, blockHash.Hex())
+}
+
+// AssignBlock gives the violations found in the given transactions, while the block of the given number was built
+// without its hash being known, the hash of the block once sealed, and marks them as MarkBlock does. A pending
+// transaction is run again each time the block is rebuilt, so only the findings of its last run are kept.
+func (checker *Checker) AssignBlock(number uint64, blockHash common.Hash, txHashes []common.Hash, canonical bool) {
+	if checker.dbHandler == nil || len(txHashes) == 0 {
+		return
+	}
+	var (
+		placeholders = make([]string, len(txHashes))
+		pending      = []interface{}{number}
+	)
+	for i, txHash := range txHashes {
+		placeholders[i] = "?"
+		pending = append(pending, txHash.Hex())
+	}
+	condition := " where block = ? and block_hash = '' and tx_hash in (" + strings.Join(placeholders, ", ") + ")"
+	args := append([]interface{}{blockHash.Hex(), canonical}, pending...)
+	for _, table := range []string{"NON_REENTRANT_TRACE", "INCONCLUSIVE_CHECK"} {
+		earlier := " and id < (select max(id) from " + table + " latest where latest.block = " + table + ".block and latest.block_hash = '' and latest.tx_hash = " + table + ".tx_hash)"
+		checker.write("drop the findings of earlier runs of the transactions of mined block "+blockHash.Hex(), "delete from "+table+condition+earlier, pending...)
+		checker.write("assign the findings of "+table+" to mined block "+blockHash.Hex(), "update "+table+" set block_hash = ?, canonical = ?"+condition, args...)
+	}
+}
+
+// MarkRewound marks the violations found in blocks above the new head of a rewound chain as non-canonical
+func (checker *Checker) MarkRewound(head uint64) {
+	if checker.dbHandler == nil {
+		return
+	}
+	checker.write(fmt.Sprintf("mark the violations above block %d non-canonical", head), "update NON_REENTRANT_TRACE set canonical = 0 where block > ?", head)
+	checker.write(fmt.Sprintf("mark the inconclusive checks above block %d non-canonical", head), "update INCONCLUSIVE_CHECK set canonical = 0 where block > ?", head)
+}
@@ -0,0 +1,79 @@
+// Shelly
+
+package vm
+
+import (
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const transactionResultsCacheSize = 8192 // Number of recent transactions whose results are kept, about 40 full blocks
+
+// ECFResultSlot receives the result of checking the transaction run by an interpreter, see Config.ECFResult. Unlike
+// LastResult, it is not overwritten by the transactions other interpreters run meanwhile.
+type ECFResultSlot struct {
+	Result  *ECFResult // nil if the transaction ran no code or the checker is disabled
+	Checked bool       // Whether the checker followed the transaction, set by core.ApplyTransaction
+}
+
+// RecordTransactionResult keeps the result of checking a transaction included in a block (imported or mined), for
+// reporting it along with the block. A nil result records that the transaction was followed but ran no code.
+func (checker *Checker) RecordTransactionResult(txHash common.Hash, result *ECFResult) {
+	if checker.transactionResults == nil {
+		return
+	}
+	checker.transactionResults.Add(txHash, result)
+}
+
+// TransactionResult returns the result of checking a recent transaction, nil if it ran no code, and whether the
+// checker followed it at all, unlike a transaction run while the checker was disabled or not recent
+func (checker *Checker) TransactionResult(txHash common.Hash) (*ECFResult, bool) {
+	if checker.transactionResults == nil {
+		return nil, false
+	}
+	if result, ok := checker.transactionResults.Get(txHash); ok {
+		return result.(*ECFResult), true
+	}
+	return nil, false
+}
+
+// SetVerdictIndex turns keeping the verdicts on the transactions of imported and mined blocks in the chain database
+// on or off, see core.ECFVerdict
+func (checker *Checker) SetVerdictIndex(enabled bool) {
+	checker.verdictIndex = enabled
+	Debug(1, "ECF verdict index is set to: %v", enabled)
+}
+
+// VerdictIndex returns whether the verdicts on the transactions of imported and mined blocks are kept in the chain
+// database
+func (checker *Checker) VerdictIndex() bool {
+	return checker.verdictIndex
+}
+
+// ForgetTransactionResults drops the kept results, e.g. when a test runner starts over with a new chain whose
+// transactions may have the hashes of earlier ones
+func (checker *Checker) ForgetTransactionResults() {
+	if checker.transactionResults != nil {
+		checker.transactionResults.Purge()
+	}
+}
+
+// ReenteredContracts returns the participating contracts the transaction re-entered, i.e. whose projection is
+// recursive, whether checked or skipped by the static fast path
+func (result *ECFResult) ReenteredContracts() []common.Address {
+	reentered := make([]common.Address, 0)
+	for contract, counters := range result.counters {
+		if counters.reentries > 0 {
+			reentered = append(reentered, contract)
+		}
+	}
+	sort.Sort(addressesByValue(reentered))
+	return reentered
+}
+
+func newTransactionResultsCache() *lru.Cache {
+	cache, _ := lru.New(transactionResultsCacheSize)
+	return cache
+}
@@ -0,0 +1,137 @@
+// Shelly
+
+package vm
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const codeTraitsCacheSize = 4096 // Number of code hashes whose traits are kept in memory
+
+var codeTraitsPrefix = []byte("ecf-code-") // codeTraitsPrefix + code hash -> codeTraits
+
+// codeTraits is what the static analysis of a contract's code tells the checker
+type codeTraits byte
+
+const (
+	// The code may run other code while it runs (CALL, CALLCODE, DELEGATECALL or CREATE)
+	traitRunsOtherCode codeTraits = 1 << iota
+	// Storage writes may be attributed to the code's segments (SSTORE, or code it runs in its own context via
+	// CALLCODE, DELEGATECALL or a CREATE's init code)
+	traitMayWrite
+	// Set on every analysed code, so that a stored zero byte is told apart from a missing entry
+	traitAnalysed
+	// The code may emit logs (LOG0 to LOG4), which are writes if logs are treated as such
+	traitMayLog
+	// Set on code analysed for logs, older stored traits lacking it are analysed again
+	traitLogsAnalysed
+)
+
+// cannotBeHarmed reports whether no callback can make the code's projections non-ECF. Code that runs no other code
+// is never re-entered, so its projection has no recursion. Code with no writes, including emitted logs if they count
+// as writes, only has segments that commute with all others.
+func (traits codeTraits) cannotBeHarmed(logsAsWrites bool) bool {
+	mayWrite := traits&traitMayWrite != 0 || (logsAsWrites && traits&traitMayLog != 0)
+	return traits&traitRunsOtherCode == 0 || !mayWrite
+}
+
+// analyseCode scans the code's opcodes, skipping push data
+func analyseCode(code []byte) codeTraits {
+	traits := traitAnalysed | traitLogsAnalysed
+	for pc := uint64(0); pc < uint64(len(code)); pc++ {
+		op := OpCode(code[pc])
+		switch op {
+		case PUSH1, PUSH2, PUSH3, PUSH4, PUSH5, PUSH6, PUSH7, PUSH8, PUSH9, PUSH10, PUSH11, PUSH12, PUSH13, PUSH14, PUSH15, PUSH16, PUSH17, PUSH18, PUSH19, PUSH20, PUSH21, PUSH22, PUSH23, PUSH24, PUSH25, PUSH26, PUSH27, PUSH28, PUSH29, PUSH30, PUSH31, PUSH32:
+			a := uint64(op) - uint64(PUSH1) + 1
+			pc += a
+		case CALL:
+			traits |= traitRunsOtherCode
+		case CALLCODE, DELEGATECALL, CREATE:
+			traits |= traitRunsOtherCode | traitMayWrite
+		case SSTORE:
+			traits |= traitMayWrite
+		case LOG0, LOG1, LOG2, LOG3, LOG4:
+			traits |= traitMayLog
+		}
+	}
+	return traits
+}
+
+// codeTraitsCache keeps the traits of analysed code by code hash, in a bounded memory cache in front of the chain
+// database
+type codeTraitsCache struct {
+	lock  sync.Mutex
+	cache *lru.Cache
+	db    ethdb.Database // Optional, traits are only kept in memory without it
+}
+
+func newCodeTraitsCache() *codeTraitsCache {
+	cache, _ := lru.New(codeTraitsCacheSize)
+	return &codeTraitsCache{cache: cache}
+}
+
+func (c *codeTraitsCache) setDatabase(db ethdb.Database) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.db = db
+}
+
+// traits returns the traits of the given code, analysing it on first sight
+func (c *codeTraitsCache) traits(codeHash common.Hash, code []byte) codeTraits {
+	if codeHash == (common.Hash{}) {
+		codeHash = crypto.Keccak256Hash(code)
+	}
+	if cached, ok := c.cache.Get(codeHash); ok {
+		return cached.(codeTraits)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	key := append(append([]byte{}, codeTraitsPrefix...), codeHash.Bytes()...)
+	if c.db != nil {
+		if stored, err := c.db.Get(key); err == nil && len(stored) == 1 && codeTraits(stored[0])&traitLogsAnalysed != 0 {
+			c.cache.Add(codeHash, codeTraits(stored[0]))
+			return codeTraits(stored[0])
+		}
+	}
+
+	traits := analyseCode(code)
+	c.cache.Add(codeHash, traits)
+	if c.db != nil {
+		if err := c.db.Put(key, []byte{byte(traits)}); err != nil {
+			Debug(1, "Failed to store code traits of %x, %v", codeHash, err)
+		}
+	}
+	Debug(3, "Analysed code %x, traits %b", codeHash, traits)
+
+	return traits
+}
+
+// SetCodeTraitsDatabase makes the static analysis results persist in the given database
+func (checker *Checker) SetCodeTraitsDatabase(db ethdb.Database) {
+	checker.codeTraits.setDatabase(db)
+}
+
+// SetStaticFastPath turns skipping the check of contracts that cannot be harmed by a callback on or off
+func (checker *Checker) SetStaticFastPath(enabled bool) {
+	checker.staticFastPath = enabled
+}
+
+// recordCodeTraits notes whether the code of a newly called contract can be harmed. A contract is skipped only if
+// none of the code run at its address in the transaction (e.g. init code and then the deployed code) can be.
+func (checker *Checker) recordCodeTraits(contract *Contract) {
+	if !checker.staticFastPath || checker.codeTraits == nil {
+		return
+	}
+	if skip, seen := checker.cannotBeHarmed[contract.Address()]; seen && !skip {
+		return
+	}
+	checker.cannotBeHarmed[contract.Address()] = checker.codeTraits.traits(contract.CodeHash, contract.Code).cannotBeHarmed(checker.logsAsWrites)
+}
@@ -0,0 +1,898 @@
+// Shelly
+
+package vm
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+	_ "github.com/mattn/go-sqlite3"
+	set "gopkg.in/fatih/set.v0"
+)
+
+var (
+	checkerTestA = common.HexToAddress("111111191324e6712a591f304b4eedef6ad9bb9d")
+	checkerTestB = common.HexToAddress("222222291324e6712a591f304b4eedef6ad9bb9d")
+)
+
+func makeTestSegment(contract common.Address, depth, indexInTransaction, indexInCall int, reads, writes []string) Segment {
+	segment := Segment{contract: contract, depth: depth, indexInTransaction: indexInTransaction, indexInCall: indexInCall, readSet: set.New(), writeSet: set.New()}
+	for _, loc := range reads {
+		segment.readSet.Add(common.StringToHash(loc))
+	}
+	for _, loc := range writes {
+		segment.writeSet.Add(common.StringToHash(loc))
+	}
+	return segment
+}
+
+// A1 B1 A'1 B2 A2 where A'1 both reads and writes the balance that A1 read and A2 writes, as in the DAO attack
+func daoTrace() []Segment {
+	return []Segment{
+		makeTestSegment(checkerTestA, 1, 0, 0, []string{"balance"}, nil),
+		makeTestSegment(checkerTestB, 2, 1, 0, nil, nil),
+		makeTestSegment(checkerTestA, 3, 2, 0, []string{"balance"}, []string{"balance"}),
+		makeTestSegment(checkerTestB, 2, 3, 1, nil, nil),
+		makeTestSegment(checkerTestA, 1, 4, 1, nil, []string{"balance"}),
+	}
+}
+
+// Projected trace A1 X1 A2 Y1 A3, where X and Y are inner calls to A. X has to be serialized after the outer call and
+// Y before it, which a single cutpoint can not express.
+func crossingTrace() []Segment {
+	return []Segment{
+		makeTestSegment(checkerTestA, 1, 0, 0, nil, []string{"p"}),
+		makeTestSegment(checkerTestA, 3, 2, 0, []string{"p"}, []string{"r"}),
+		makeTestSegment(checkerTestA, 1, 4, 1, nil, nil),
+		makeTestSegment(checkerTestA, 3, 6, 0, nil, []string{"q"}),
+		makeTestSegment(checkerTestA, 1, 8, 2, []string{"q"}, nil),
+	}
+}
+
+func TestExactSearchRejectsDAO(t *testing.T) {
+	trace := GetProjectedTrace(daoTrace(), &checkerTestA)
+	if _, outcome := findCallbackFreeSerialization(trace, 0, 0); outcome != exactSearchNotFound {
+		t.Errorf("expected no callback free serialization, got outcome %v", outcome)
+	}
+	if violation := checkTraceForReentrancy(trace, attemptToRemoveRecursion, &traceCheckCounters{}); violation == nil {
+		t.Errorf("expected the heuristic to find a violation")
+	}
+}
+
+func TestExactSearchFindsSerialization(t *testing.T) {
+	trace := crossingTrace()
+
+	serialization, outcome := findCallbackFreeSerialization(trace, 0, 0)
+	if outcome != exactSearchFound {
+		t.Fatalf("expected a callback free serialization, got outcome %v", outcome)
+	}
+	if hasRecursion(serialization) {
+		t.Errorf("serialization %v still has recursion", serialization)
+	}
+	expected := []int{6, 0, 4, 8, 2}
+	for i := range expected {
+		if serialization[i].indexInTransaction != expected[i] {
+			t.Fatalf("serialization %v, expected segment order %v", serialization, expected)
+		}
+	}
+
+	if _, success := attemptToRemoveRecursion(trace); success {
+		t.Errorf("expected the cutpoint heuristic to fail on %v", trace)
+	}
+}
+
+func TestExactSearchLimits(t *testing.T) {
+	trace := crossingTrace()
+	if _, outcome := findCallbackFreeSerialization(trace, len(trace)-1, 0); outcome != exactSearchAborted {
+		t.Errorf("expected the segment limit to abort the search, got outcome %v", outcome)
+	}
+	if _, outcome := findCallbackFreeSerialization(trace, len(trace), time.Minute); outcome != exactSearchFound {
+		t.Errorf("expected the search to complete within the limits, got outcome %v", outcome)
+	}
+}
+
+func TestCheckModes(t *testing.T) {
+	checker := &Checker{transactionSegments: crossingTrace(), mode: ECFModeHeuristic, exactMaxSegments: defaultExactMaxSegments}
+
+	if result := checker.checkForReentrancy(ECFModeDefault); result.IsECF() || result.Mode != ECFModeHeuristic {
+		t.Errorf("expected a heuristic violation, got %+v", result)
+	}
+	if result := checker.checkForReentrancy(ECFModeExact); !result.IsECF() {
+		t.Errorf("expected the exact search to find the trace ECF, got %+v", result)
+	}
+
+	result := checker.checkForReentrancy(ECFModeCompare)
+	if result.IsECF() {
+		t.Errorf("expected compare mode to report the heuristic's violation")
+	}
+	if len(result.Disagreements) != 1 || result.Disagreements[0].HeuristicECF || !result.Disagreements[0].ExactECF {
+		t.Errorf("expected a single disagreement in favour of the exact search, got %+v", result.Disagreements)
+	}
+
+	checker.exactMaxSegments = 2
+	if result := checker.checkForReentrancy(ECFModeExact); result.IsECF() || result.ExactAborted != 1 {
+		t.Errorf("expected the exact search to fall back to the heuristic, got %+v", result)
+	}
+}
+
+func TestContractProfiles(t *testing.T) {
+	checker := &Checker{transactionSegments: daoTrace(), mode: ECFModeHeuristic}
+
+	if reentries := countReentries(GetProjectedTrace(daoTrace(), &checkerTestA)); reentries != 1 {
+		t.Errorf("expected A to be re-entered once, got %d", reentries)
+	}
+	if reentries := countReentries(GetProjectedTrace(daoTrace(), &checkerTestB)); reentries != 0 {
+		t.Errorf("expected B not to be re-entered, got %d", reentries)
+	}
+
+	checker.updateProfiles(checker.checkForReentrancy(ECFModeDefault).counters, big.NewInt(10))
+	checker.updateProfiles(checker.checkForReentrancy(ECFModeDefault).counters, big.NewInt(12))
+
+	profile := checker.ContractProfile(checkerTestA)
+	if profile == nil {
+		t.Fatalf("expected a profile of A")
+	}
+	if profile.Transactions != 2 || profile.Reentered != 2 || profile.Violations != 2 || profile.FirstSeenBlock != 10 || profile.LastSeenBlock != 12 {
+		t.Errorf("unexpected profile of A: %+v", profile)
+	}
+
+	top, err := checker.TopContracts("transactions", 1)
+	if err != nil {
+		t.Fatalf("failed to rank contracts: %v", err)
+	}
+	if len(top) != 1 || top[0].Contract != checkerTestA {
+		t.Errorf("expected A to rank first, got %+v", top)
+	}
+	if top, _ := checker.TopContracts("violations", 10); len(top) != 1 {
+		t.Errorf("expected only A to have violations, got %+v", top)
+	}
+	if _, err := checker.TopContracts("gas", 10); err == nil {
+		t.Errorf("expected an error for an unknown metric")
+	}
+
+	// Only imported transactions are counted unless a policy says otherwise
+	if !checker.counts(ExecImport) || checker.counts(ExecMine) || checker.counts(ExecCall) {
+		t.Errorf("expected only imported transactions to be counted")
+	}
+
+	// The changed profiles are written while the checker runs
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(`create table CONTRACT_PROFILE (contract text primary key, transactions integer, reentered integer, omittable integer, reordered integer, violations integer, first_block integer, last_block integer)`); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	checker.dbHandler = db
+	checker.queueProfiles(0)
+	var transactions uint64
+	if err := db.QueryRow("select transactions from CONTRACT_PROFILE where contract = ?", checkerTestA.Hex()).Scan(&transactions); err != nil || transactions != 2 {
+		t.Fatalf("expected the profile of A to be written, got %d transactions (%v)", transactions, err)
+	}
+	checker.updateProfiles(checker.checkForReentrancy(ECFModeDefault).counters, big.NewInt(13))
+	checker.queueProfiles(0)
+	if err := db.QueryRow("select transactions from CONTRACT_PROFILE where contract = ?", checkerTestA.Hex()).Scan(&transactions); err != nil || transactions != 2 {
+		t.Errorf("expected the profiles to be written at most once per interval, got %d transactions (%v)", transactions, err)
+	}
+}
+
+func TestAnalyseCode(t *testing.T) {
+	tests := []struct {
+		code      []byte
+		skippable bool
+		runsOther bool
+		mayWrite  bool
+	}{
+		{[]byte{byte(PUSH1), 0x01, byte(PUSH1), 0x00, byte(SSTORE)}, true, false, true},
+		{[]byte{byte(CALL), byte(SLOAD)}, true, true, false},
+		{[]byte{byte(CALL), byte(SSTORE)}, false, true, true},
+		{[]byte{byte(DELEGATECALL)}, false, true, true},
+		// The CALL byte is push data
+		{[]byte{byte(PUSH2), byte(CALL), 0x00, byte(SSTORE)}, true, false, true},
+	}
+	for i, test := range tests {
+		traits := analyseCode(test.code)
+		if traits.cannotBeHarmed(false) != test.skippable || (traits&traitRunsOtherCode != 0) != test.runsOther || (traits&traitMayWrite != 0) != test.mayWrite {
+			t.Errorf("test %d: unexpected traits %b of %x", i, traits, test.code)
+		}
+	}
+}
+
+func TestStaticFastPath(t *testing.T) {
+	checker := &Checker{transactionSegments: daoTrace(), mode: ECFModeHeuristic}
+
+	checker.cannotBeHarmed = map[common.Address]bool{checkerTestA: false, checkerTestB: true}
+	result := checker.checkForReentrancy(ECFModeDefault)
+	if result.IsECF() || result.StaticSkips != 1 {
+		t.Errorf("expected a violation and B to be skipped, got %+v", result)
+	}
+
+	checker.cannotBeHarmed = map[common.Address]bool{checkerTestA: true, checkerTestB: true}
+	result = checker.checkForReentrancy(ECFModeDefault)
+	if !result.IsECF() || result.StaticSkips != 2 {
+		t.Errorf("expected both contracts to be skipped, got %+v", result)
+	}
+	if result.counters[checkerTestA] == nil || result.counters[checkerTestA].reentries != 1 {
+		t.Errorf("expected skipped contracts to still be profiled, got %+v", result.counters[checkerTestA])
+	}
+
+	checker.updateStats(result, time.Millisecond)
+	if stats := checker.Stats(); stats.Transactions != 1 || stats.NonECF != 0 || stats.StaticSkips != 2 || stats.Segments != 5 || stats.CheckTime != uint64(time.Millisecond) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestCodeTraitsPersistence(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	code := []byte{byte(CALL), byte(SSTORE)}
+
+	cache := newCodeTraitsCache()
+	cache.setDatabase(db)
+	if traits := cache.traits(common.Hash{}, code); traits.cannotBeHarmed(false) {
+		t.Fatalf("expected the code to be harmable, got traits %b", traits)
+	}
+
+	// A fresh cache reads the traits back instead of analysing the code again
+	cache = newCodeTraitsCache()
+	cache.setDatabase(db)
+	if traits := cache.traits(crypto.Keccak256Hash(code), nil); traits.cannotBeHarmed(false) {
+		t.Errorf("expected the stored traits, got %b", traits)
+	}
+}
+
+// A1 B1 A'1 B2 A2 where each segment of A emits an event and accesses no storage. Only logs treated as writes keep the
+// callback from reordering the events.
+func TestLogsAsWrites(t *testing.T) {
+	code := []byte{byte(CALL), byte(LOG1)}
+	if traits := analyseCode(code); !traits.cannotBeHarmed(false) || traits.cannotBeHarmed(true) {
+		t.Errorf("expected logging code to be harmable only if logs are writes, got traits %b", traits)
+	}
+	// Traits stored before logs were analysed are analysed again
+	db, _ := ethdb.NewMemDatabase()
+	db.Put(append(append([]byte{}, codeTraitsPrefix...), crypto.Keccak256(code)...), []byte{byte(traitAnalysed | traitRunsOtherCode)})
+	cache := newCodeTraitsCache()
+	cache.setDatabase(db)
+	if traits := cache.traits(common.Hash{}, code); traits&traitMayLog == 0 {
+		t.Errorf("expected the stored traits to be analysed again, got %b", traits)
+	}
+
+	checker := &Checker{mode: ECFModeHeuristic}
+	frame := NewContract(&dummyContractRef{}, &dummyContractRef{}, new(big.Int), new(big.Int))
+	for _, logsAsWrites := range []bool{false, true} {
+		checker.logsAsWrites = logsAsWrites
+		checker.transactionSegments = nil
+		for _, segment := range daoTrace() {
+			segment.readSet, segment.writeSet = set.New(), set.New()
+			checker.transactionSegments = append(checker.transactionSegments, segment)
+			if segment.contract == checkerTestA {
+				checker.UponLog(nil, frame, []common.Hash{common.StringToHash("Transfer")})
+			}
+		}
+		stream := EventStreamLocation(frame.Address())
+		if first := checker.transactionSegments[0]; first.readSet.Has(stream) != logsAsWrites || first.writeSet.Has(stream) != logsAsWrites {
+			t.Errorf("logs as writes %v: unexpected accesses %v %v", logsAsWrites, first.readSet, first.writeSet)
+		}
+		if result := checker.checkForReentrancy(ECFModeDefault); result.IsECF() == logsAsWrites {
+			t.Errorf("logs as writes %v: unexpected verdict %+v", logsAsWrites, result)
+		}
+	}
+
+	declared := Trace().Call(checkerTestA).Log().Call(checkerTestB).Call(checkerTestA).Log().Ret().Ret().Log().Ret()
+	if result, err := declared.Check(nil, ECFModeDefault); err != nil || result.IsECF() {
+		t.Errorf("expected the declared events to conflict, got %+v (%v)", result, err)
+	}
+}
+
+// proxy -> impl -> attacker -> proxy, where the implementation runs in the proxy's context through DELEGATECALL
+func TestSubSegmentAttribution(t *testing.T) {
+	impl := common.HexToAddress("333333391324e6712a591f304b4eedef6ad9bb9d")
+	implFrame := NewContract(&dummyContractRef{}, &dummyContractRef{}, new(big.Int), new(big.Int))
+	implFrame.SetCallCode(&impl, common.Hash{}, nil)
+	balance := common.StringToHash("balance")
+
+	checker := &Checker{mode: ECFModeHeuristic, attribution: AttributionSubSegments}
+	for i, segment := range daoTrace() {
+		segment.readSet, segment.writeSet = set.New(), set.New()
+		checker.transactionSegments = append(checker.transactionSegments, segment)
+		if segment.contract != checkerTestA {
+			continue
+		}
+		switch i {
+		case 0:
+			checker.UponSLoad(nil, implFrame, balance, new(big.Int))
+			checker.attributeCall(implFrame)
+		case 2:
+			checker.UponSLoad(nil, implFrame, balance, new(big.Int))
+			checker.UponSStore(nil, implFrame, balance, new(big.Int))
+		case 4:
+			checker.UponSStore(nil, implFrame, balance, new(big.Int))
+		}
+	}
+
+	first := checker.transactionSegments[0]
+	if len(first.subSegments) != 1 || first.subSegments[0].code != impl || !first.accesses.Has(StorageAccess{Location: balance, Code: impl}) {
+		t.Fatalf("expected the proxy's first segment to be attributed to the implementation, got %v", first)
+	}
+
+	result := checker.checkForReentrancy(ECFModeDefault)
+	if len(result.Violations) != 1 {
+		t.Fatalf("expected a single violation, got %+v", result)
+	}
+	if violation := result.Violations[0]; violation.Contract != checkerTestA || violation.Code == nil || *violation.Code != impl {
+		t.Errorf("expected the violation on the proxy to be reported through the implementation, got %+v", violation)
+	}
+
+	// Attribution does not change the verdict
+	checker.attribution = AttributionContext
+	if violation := checkTraceForReentrancy(GetProjectedTrace(daoTrace(), &checkerTestA), attemptToRemoveRecursion, &traceCheckCounters{}); violation == nil || violation.Code != nil {
+		t.Errorf("expected an untagged violation by context, got %+v", violation)
+	}
+}
+
+func TestCallChecks(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1) // Every connection would get a database of its own
+	if _, err := db.Exec(`create table CALL_CHECK (id integer primary key autoincrement, block integer, origin text, recipient text, input_hash text, mode text, ecf integer, violations integer, time integer)`); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	checker := &Checker{dbHandler: db}
+
+	checks := []*CallCheck{
+		{Block: 7, From: checkerTestB, To: &checkerTestA, Mode: "heuristic", ECF: false, Violations: 1},
+		{Block: 8, From: checkerTestB, Mode: "exact", ECF: true},
+	}
+	for _, check := range checks {
+		if err := checker.StoreCallCheck(check); err != nil {
+			t.Fatalf("failed to store call check: %v", err)
+		}
+	}
+
+	stored, err := checker.RecentCallChecks(10)
+	if err != nil {
+		t.Fatalf("failed to read call checks: %v", err)
+	}
+	if len(stored) != 2 || stored[0].ID != checks[1].ID || stored[0].To != nil || !stored[0].ECF {
+		t.Fatalf("expected the creation check first, got %+v", stored)
+	}
+	if stored[1].To == nil || *stored[1].To != checkerTestA || stored[1].ECF || stored[1].Violations != 1 || stored[1].Block != 7 {
+		t.Errorf("unexpected stored call check %+v", stored[1])
+	}
+
+	// Checks queued on the background writer are numbered on, and read once written
+	checker.startWriter(db)
+	defer checker.StopWriter()
+	check := &CallCheck{Block: 9, From: checkerTestB, Mode: "exact", ECF: true}
+	if err := checker.StoreCallCheck(check); err != nil || check.ID != checks[1].ID+1 {
+		t.Fatalf("expected the queued check to follow the stored ones, got ID %d (%v)", check.ID, err)
+	}
+	if stored, err := checker.RecentCallChecks(1); err != nil || len(stored) != 1 || stored[0].ID != check.ID || stored[0].Block != 9 {
+		t.Errorf("expected the queued check to be read, got %+v (%v)", stored, err)
+	}
+}
+
+// newViolationsDb returns an in-memory database with empty violations and inconclusive checks tables
+func newViolationsDb(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(`create table NON_REENTRANT_TRACE (id integer not null, origin text, block integer, time integer, contract text, depth integer, start_index integer, length integer, tx_hash text not null default '', block_hash text not null default '', canonical integer not null default 1, context text not null default '', exempt integer not null default 0)`); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	if _, err := db.Exec(`create table INCONCLUSIVE_CHECK (id integer not null, origin text, block integer, time integer, tx_hash text not null default '', block_hash text not null default '', canonical integer not null default 0, context text not null default '', reason text not null, segments integer)`); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	return db
+}
+
+func TestStoredViolations(t *testing.T) {
+	db := newViolationsDb(t)
+	defer db.Close()
+	for i, contract := range []common.Address{checkerTestA, checkerTestB, checkerTestA} {
+		if _, err := db.Exec("insert into NON_REENTRANT_TRACE(id, origin, block, time, contract, depth, start_index, length) values(?, ?, ?, ?, ?, 1, 0, 5)", i+1, checkerTestB.Hex(), 10*(i+1), 0, contract.Hex()); err != nil {
+			t.Fatalf("failed to insert violation: %v", err)
+		}
+	}
+	checker := &Checker{dbHandler: db}
+
+	all, err := checker.Violations(ViolationFilter{})
+	if err != nil || len(all) != 3 {
+		t.Fatalf("expected all 3 violations, got %v (%v)", all, err)
+	}
+	from, to := uint64(15), uint64(30)
+	filtered, err := checker.Violations(ViolationFilter{FromBlock: &from, ToBlock: &to, Contract: &checkerTestA})
+	if err != nil || len(filtered) != 1 {
+		t.Fatalf("expected a single violation, got %v (%v)", filtered, err)
+	}
+	if filtered[0].Block != 30 || filtered[0].Contract != checkerTestA || filtered[0].Origin != checkerTestB || filtered[0].Length != 5 {
+		t.Errorf("unexpected violation %+v", filtered[0])
+	}
+}
+
+func TestViolationsFollowReorgs(t *testing.T) {
+	db := newViolationsDb(t)
+	defer db.Close()
+	defer func(handler *sql.DB) { TheChecker().dbHandler = handler }(TheChecker().dbHandler)
+	checker := TheChecker()
+	checker.dbHandler = db
+
+	var (
+		origin           = common.HexToAddress("0x01")
+		txHash           = common.StringToHash("tx")
+		minedTx          = common.StringToHash("mined")
+		canon, side, own = common.StringToHash("canon"), common.StringToHash("side"), common.StringToHash("own")
+	)
+	checker.origin, checker.blockNumber, checker.time = &origin, big.NewInt(5), big.NewInt(0)
+	defer func() { checker.origin, checker.blockNumber, checker.time = nil, nil, nil }()
+	for _, block := range []common.Hash{canon, side, {}} {
+		transaction := txHash
+		if block == (common.Hash{}) {
+			transaction = minedTx
+		}
+		checker.SetTransactionContext(transaction, block)
+		reportNonReentrant(ECFViolation{Contract: checkerTestA, Depth: 1, Length: 5})
+	}
+	// The pending block is rebuilt, running the mined transaction again, which now violates ECF on two contracts
+	defer func(id int) { checker.TransactionID = id }(checker.TransactionID)
+	checker.TransactionID++
+	checker.SetTransactionContext(minedTx, common.Hash{})
+	reportNonReentrant(ECFViolation{Contract: checkerTestA, Depth: 1, Length: 5})
+	reportNonReentrant(ECFViolation{Contract: checkerTestB, Depth: 2, Length: 3})
+	checker.SetTransactionContext(common.Hash{}, common.Hash{})
+
+	canonicalBlocks := func() []common.Hash {
+		violations, err := checker.Violations(ViolationFilter{})
+		if err != nil {
+			t.Fatalf("failed to query violations: %v", err)
+		}
+		blocks := make([]common.Hash, len(violations))
+		for i, violation := range violations {
+			blocks[i] = violation.BlockHash
+		}
+		return blocks
+	}
+	if blocks := canonicalBlocks(); len(blocks) != 0 {
+		t.Fatalf("violations canonical before their block is written: %x", blocks)
+	}
+	checker.MarkBlock(canon, true)
+	checker.MarkBlock(side, false)
+	checker.AssignBlock(5, own, []common.Hash{minedTx}, false)
+	if blocks := canonicalBlocks(); len(blocks) != 1 || blocks[0] != canon {
+		t.Fatalf("canonical violations in %x, want %x", blocks, canon)
+	}
+
+	// The side chain takes over
+	checker.MarkBlock(canon, false)
+	checker.MarkBlock(side, true)
+	if blocks := canonicalBlocks(); len(blocks) != 1 || blocks[0] != side {
+		t.Fatalf("canonical violations in %x, want %x", blocks, side)
+	}
+	all, err := checker.Violations(ViolationFilter{NonCanonical: true})
+	if err != nil || len(all) != 4 {
+		t.Fatalf("expected 4 violations in all, the 2 of the last run of the mined transaction, got %v (%v)", all, err)
+	}
+	for _, violation := range all {
+		if violation.Canonical != (violation.BlockHash == side) {
+			t.Errorf("violation in %x canonical %v", violation.BlockHash, violation.Canonical)
+		}
+		if violation.BlockHash == own && violation.TxHash != minedTx {
+			t.Errorf("mined violation assigned to transaction %x", violation.TxHash)
+		}
+	}
+
+	checker.MarkRewound(4)
+	if blocks := canonicalBlocks(); len(blocks) != 0 {
+		t.Errorf("violations canonical after rewinding below their block: %x", blocks)
+	}
+}
+
+func TestPersistedContexts(t *testing.T) {
+	db := newViolationsDb(t)
+	defer db.Close()
+	defer func(handler *sql.DB) { TheChecker().dbHandler = handler }(TheChecker().dbHandler)
+	checker := TheChecker()
+	checker.dbHandler = db
+	defer checker.SetPersistedContexts(nil)
+
+	contexts, err := ParseExecutionContexts("import, pool-sim")
+	if err != nil || len(contexts) != 2 || contexts[0] != ExecImport || contexts[1] != ExecPoolSim {
+		t.Fatalf("unexpected contexts %v (%v)", contexts, err)
+	}
+	if _, err := ParseExecutionContexts("import,mining"); err == nil {
+		t.Fatalf("expected an unknown context to be refused")
+	}
+	checker.SetPersistedContexts(contexts)
+
+	origin := common.HexToAddress("0x01")
+	checker.origin, checker.blockNumber, checker.time = &origin, big.NewInt(5), big.NewInt(0)
+	defer func() {
+		checker.origin, checker.blockNumber, checker.time, checker.context = nil, nil, nil, ExecUnknown
+	}()
+	for _, context := range []ExecutionContext{ExecMine, ExecImport, ExecPoolSim, ExecCall} {
+		checker.context = context
+		reportNonReentrant(ECFViolation{Contract: checkerTestA, Depth: 1, Length: 5})
+	}
+	if recent := checker.RecentViolations(2); len(recent) != 2 || recent[0].Context != "pool-sim" || recent[1].Context != "import" {
+		t.Errorf("expected only the persisted violations to be kept in memory, got %+v", recent)
+	}
+	if !checker.counts(ExecPoolSim) || checker.counts(ExecMine) {
+		t.Errorf("expected the persisted contexts to be counted")
+	}
+
+	all, err := checker.Violations(ViolationFilter{NonCanonical: true})
+	if err != nil || len(all) != 2 || all[0].Context != "import" || all[1].Context != "pool-sim" {
+		t.Fatalf("expected the import and pool-sim violations only, got %+v (%v)", all, err)
+	}
+	context := "pool-sim"
+	if filtered, err := checker.Violations(ViolationFilter{NonCanonical: true, Context: &context}); err != nil || len(filtered) != 1 || filtered[0].Context != context {
+		t.Errorf("expected the pool-sim violation, got %+v (%v)", filtered, err)
+	}
+	if err := checker.StoreCallCheck(&CallCheck{}); err != nil {
+		t.Errorf("unpersisted call check failed: %v", err)
+	}
+}
+
+func TestFindingsWriter(t *testing.T) {
+	db := newViolationsDb(t)
+	defer db.Close()
+	if _, err := db.Exec(`create table LAST_TRANSACTION_ID (txId integer); insert into LAST_TRANSACTION_ID values(0)`); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	checkpoint := func() (txID int) {
+		if err := db.QueryRow("select txId from LAST_TRANSACTION_ID").Scan(&txID); err != nil {
+			t.Fatalf("failed to read checkpoint: %v", err)
+		}
+		return txID
+	}
+	insert := "insert into NON_REENTRANT_TRACE(id, origin, block, time, contract, depth, start_index, length) values(?, '', 1, 0, '', 1, 0, 5)"
+
+	checker := &Checker{dbHandler: db}
+	checker.startWriter(db)
+	defer checker.StopWriter()
+
+	checker.TransactionID = 7
+	checker.write("store violation", insert, 7)
+	if violations, err := checker.Violations(ViolationFilter{}); err != nil || len(violations) != 1 {
+		t.Fatalf("expected the queued violation to be written, got %v (%v)", violations, err)
+	}
+	if txID := checkpoint(); txID != 7 {
+		t.Errorf("checkpoint at transaction %d, want 7", txID)
+	}
+
+	// A failing write is skipped, the rest of its batch is written along with the checkpoint
+	checker.TransactionID = 9
+	checker.write("store violation", insert, 8)
+	checker.write("store violation", "insert into MISSING_TABLE values(?)", 9)
+	if err := checker.SyncFindings(); err == nil {
+		t.Errorf("expected the failing write to be reported")
+	}
+	if violations, err := checker.Violations(ViolationFilter{}); err != nil || len(violations) != 2 {
+		t.Errorf("expected the rest of the batch to be written, got %v (%v)", violations, err)
+	}
+	if txID := checkpoint(); txID != 9 {
+		t.Errorf("checkpoint at transaction %d after a failed write, want 9", txID)
+	}
+
+	// Stopping writes the queued findings, later ones are written directly
+	checker.TransactionID = 10
+	checker.write("store violation", insert, 10)
+	checker.StopWriter()
+	checker.write("store violation", insert, 11)
+	if violations, err := checker.Violations(ViolationFilter{}); err != nil || len(violations) != 4 {
+		t.Errorf("expected 4 violations after stopping, got %v (%v)", violations, err)
+	}
+	if txID := checkpoint(); txID != 10 {
+		t.Errorf("checkpoint at transaction %d after stopping, want 10", txID)
+	}
+}
+
+func TestCheckedBlock(t *testing.T) {
+	db := newViolationsDb(t)
+	defer db.Close()
+	if _, err := db.Exec(`create table LAST_CHECKED_BLOCK (id integer primary key check (id = 0), block integer not null)`); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	if _, err := db.Exec(`create table CONTRACT_PROFILE (contract text primary key, transactions integer, reentered integer, omittable integer, reordered integer, violations integer, first_block integer, last_block integer)`); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	checker := &Checker{dbHandler: db}
+	checker.startWriter(db)
+	defer checker.StopWriter()
+
+	if _, ok, err := checker.LastCheckedBlock(); err != nil || ok {
+		t.Fatalf("expected no checked block in a new database (%v)", err)
+	}
+	blockHash := common.HexToHash("0x05")
+	checker.write("store violation", "insert into NON_REENTRANT_TRACE(id, origin, block, time, contract, depth, start_index, length, block_hash) values(1, '', 5, 0, '', 1, 0, 5, ?)", blockHash.Hex())
+	checker.BlockChecked(5)
+	checker.BlockChecked(3)
+	if number, ok, err := checker.LastCheckedBlock(); err != nil || !ok || number != 5 {
+		t.Errorf("checked block %d (%v, %v), want 5", number, ok, err)
+	}
+
+	// The checkpoint waits for the changed profiles, which are queued at most once per interval
+	checker.profiles.profiles = map[common.Address]*ContractProfile{checkerTestA: {Contract: checkerTestA, Transactions: 1}}
+	checker.profiles.dirty = map[common.Address]bool{checkerTestA: true}
+	checker.profiles.queued = time.Now()
+	checker.BlockChecked(6)
+	if number, _, err := checker.LastCheckedBlock(); err != nil || number != 5 {
+		t.Errorf("checked block %d (%v) before the profiles are queued, want 5", number, err)
+	}
+
+	// Findings are dropped before their block is checked again
+	checker.ForgetBlockFindings(blockHash)
+	if violations, err := checker.Violations(ViolationFilter{}); err != nil || len(violations) != 0 {
+		t.Errorf("expected the findings of the block to be dropped, got %v (%v)", violations, err)
+	}
+
+	// Saving all the profiles on shutdown checkpoints the last block they include
+	checker.StopWriter()
+	if err := checker.SaveProfiles(); err != nil {
+		t.Fatalf("failed to save profiles: %v", err)
+	}
+	if number, _, err := checker.LastCheckedBlock(); err != nil || number != 6 {
+		t.Errorf("checked block %d (%v) after saving the profiles, want 6", number, err)
+	}
+}
+
+func TestRecentViolations(t *testing.T) {
+	var ring violationRing
+	if violations := ring.last(10); len(violations) != 0 {
+		t.Fatalf("empty ring returned %d violations", len(violations))
+	}
+	for i := 0; i < recentViolationsSize+10; i++ {
+		ring.add(&StoredViolation{TransactionID: i})
+	}
+	violations := ring.last(recentViolationsSize + 1)
+	if len(violations) != recentViolationsSize {
+		t.Fatalf("returned %d violations, want %d", len(violations), recentViolationsSize)
+	}
+	for i, violation := range violations {
+		if want := recentViolationsSize + 9 - i; violation.TransactionID != want {
+			t.Fatalf("violation %d: transaction %d, want %d", i, violation.TransactionID, want)
+		}
+	}
+}
+
+func TestSetEnabled(t *testing.T) {
+	defer func(disabled bool) { DISABLE_CHECKER = disabled }(DISABLE_CHECKER)
+	DISABLE_CHECKER = false
+	checker := &Checker{}
+
+	checker.SetEnabled(false)
+	if checker.Enabled() || DISABLE_CHECKER {
+		t.Fatalf("expected the checker to be disabled only from the next transaction")
+	}
+	checker.applyPendingEnabled()
+	if !DISABLE_CHECKER {
+		t.Errorf("expected the checker to be disabled once a transaction starts")
+	}
+	checker.SetEnabled(true)
+	checker.applyPendingEnabled()
+	if DISABLE_CHECKER || !checker.Enabled() {
+		t.Errorf("expected the checker to be enabled again")
+	}
+}
+
+func TestTransactionResults(t *testing.T) {
+	checker := &Checker{transactionResults: newTransactionResultsCache()}
+	txHash := common.StringToHash("tx")
+
+	if result, checked := checker.TransactionResult(txHash); result != nil || checked {
+		t.Fatalf("expected no result before recording, got %+v", result)
+	}
+	recorded := &ECFResult{Violations: []ECFViolation{{Contract: checkerTestA}}}
+	checker.RecordTransactionResult(txHash, recorded)
+	if result, checked := checker.TransactionResult(txHash); result != recorded || !checked {
+		t.Errorf("expected the recorded result, got %+v", result)
+	}
+}
+
+func TestSegmentTraceEncoding(t *testing.T) {
+	segments := daoTrace()
+	segments[0].call = &SegmentCall{Caller: checkerTestB, Value: big.NewInt(7), Selector: []byte{1, 2, 3, 4}, InputSize: 36}
+	segments[2].accesses = set.New(StorageAccess{Location: common.StringToHash("balance"), Code: checkerTestA, Write: true})
+	origin := common.HexToAddress("0x01")
+
+	checker := &Checker{mode: ECFModeHeuristic, staticFastPath: true}
+	checker.lastTrace = traceSource{segments: segments, cannotBeHarmed: map[common.Address]bool{checkerTestB: true}, origin: &origin, block: big.NewInt(42)}
+	trace := checker.LastTrace()
+	if trace.Block != 42 || trace.Origin != origin || len(trace.Unharmable) != 1 || len(trace.Segments) != len(segments) {
+		t.Fatalf("unexpected trace %+v", trace)
+	}
+	want, _ := json.Marshal(trace)
+
+	encoded, err := rlp.EncodeToBytes(trace)
+	if err != nil {
+		t.Fatalf("failed to encode trace as RLP: %v", err)
+	}
+	for name, data := range map[string][]byte{"json": want, "rlp": encoded} {
+		decoded, err := DecodeSegmentTrace(data)
+		if err != nil {
+			t.Fatalf("%s: failed to decode trace: %v", name, err)
+		}
+		if have, _ := json.Marshal(decoded); !bytes.Equal(have, want) {
+			t.Errorf("%s: trace mismatch:\nhave %s\nwant %s", name, have, want)
+		}
+		result, err := checker.CheckTrace(decoded, ECFModeDefault)
+		if err != nil {
+			t.Fatalf("%s: failed to check trace: %v", name, err)
+		}
+		if len(result.Violations) != 1 || result.Violations[0].Contract != checkerTestA || result.StaticSkips != 1 {
+			t.Errorf("%s: expected a violation on A and B skipped, got %+v", name, result)
+		}
+	}
+
+	trace.Version++
+	if _, err := checker.CheckTrace(trace, ECFModeDefault); err == nil {
+		t.Errorf("expected a trace of another version to be refused")
+	}
+}
+
+func TestSegmentTraceDOT(t *testing.T) {
+	balance := common.StringToHash("balance")
+	builder := Trace().Block(7).
+		Call(checkerTestA).Read(balance).
+		Call(checkerTestB).
+		Call(checkerTestA).Read(balance).Write(balance).Ret().
+		Ret().
+		Write(balance).Ret()
+	trace, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := builder.Check(nil, ECFModeHeuristic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.subtraces) != 1 {
+		t.Fatalf("expected the subtrace of the violation, got %+v", result.subtraces)
+	}
+	// A1 and A2 both conflict with A'1 on the balance
+	subtrace := result.subtraces[0]
+	if len(subtrace.segments) != 3 || len(subtrace.conflicts) != 2 || subtrace.conflicts[0].from != 0 || subtrace.conflicts[0].to != 2 || subtrace.conflicts[1].from != 2 || subtrace.conflicts[1].to != 4 {
+		t.Fatalf("unexpected subtrace %+v", subtrace)
+	}
+
+	dot := trace.DOT(result)
+	for _, want := range []string{
+		"subgraph \"cluster_0\"", "subgraph \"cluster_1\"",
+		"\"s0\" -> \"s1\" [label=\"call\"]", "\"s3\" -> \"s4\" [label=\"return\"]",
+		"\"s2\" [label=\"#2 depth 3 part 0\\nR " + balance.Hex()[:6],
+		"color=red, penwidth=2", "\"s0\" -> \"s2\" [label=\"" + balance.Hex()[:6],
+		"not ECF (heuristic mode)",
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("graph lacks %q:\n%s", want, dot)
+		}
+	}
+	if strings.Contains(trace.DOT(nil), "color=red") {
+		t.Errorf("graph without a result highlights segments")
+	}
+}
+
+// registryStateDB holds the storage of an exemption registry
+type registryStateDB struct {
+	NoopStateDB
+	storage map[common.Hash]common.Hash
+}
+
+func (db registryStateDB) GetState(_ common.Address, key common.Hash) common.Hash {
+	return db.storage[key]
+}
+
+// Tests that the violations of the methods the registry exempts up to the block are marked exempt and keep the
+// transaction ECF, and that the other violations are not.
+func TestExemptions(t *testing.T) {
+	registry := checkerTestB
+	withdraw := [4]byte{0x2e, 0x1a, 0x7d, 0x4d}
+	db := registryStateDB{storage: map[common.Hash]common.Hash{
+		ExemptionSlot(checkerTestA, withdraw): common.BigToHash(big.NewInt(10)),
+	}}
+	segments := daoTrace()
+	segments[0].call = &SegmentCall{Selector: withdraw[:]}
+
+	for _, test := range []struct {
+		block  int64
+		exempt bool
+	}{{9, true}, {10, true}, {11, false}} {
+		checker := &Checker{mode: ECFModeHeuristic, exemptionRegistry: &registry}
+		checker.exempted = checker.exemptionCheck(&EVM{StateDB: db, Context: Context{BlockNumber: big.NewInt(test.block)}})
+
+		result := checker.checkSegments(segments, nil, ECFModeDefault, true)
+		if len(result.Violations) != 1 || result.Violations[0].Exempt != test.exempt || result.IsECF() != test.exempt {
+			t.Errorf("block %d: expected exempt %v, got %+v", test.block, test.exempt, result.Violations)
+		}
+	}
+
+	checker := &Checker{mode: ECFModeHeuristic, exemptionRegistry: &registry}
+	checker.exempted = checker.exemptionCheck(&EVM{StateDB: db, Context: Context{BlockNumber: big.NewInt(5)}})
+	segments[0].call = &SegmentCall{Selector: []byte{0x01, 0x02}}
+	if result := checker.checkSegments(segments, nil, ECFModeDefault, true); result.IsECF() || len(result.NonExempt()) != 1 {
+		t.Errorf("expected another method to stay a violation, got %+v", result.Violations)
+	}
+}
+
+// Tests that transactions exceeding the limits get an inconclusive verdict, which is stored and counted apart from
+// the non-ECF ones, and that a check out of work or time stops.
+func TestLimits(t *testing.T) {
+	db := newViolationsDb(t)
+	defer db.Close()
+	origin := common.HexToAddress("0x01")
+	checker := &Checker{mode: ECFModeHeuristic, dbHandler: db, origin: &origin, blockNumber: big.NewInt(5)}
+
+	for _, test := range []struct {
+		maxSegments, maxLocations int
+		reason                    string
+	}{{0, 0, ""}, {5, 4, ""}, {4, 0, InconclusiveSegments}, {0, 3, InconclusiveLocations}} {
+		checker.SetLimits(test.maxSegments, test.maxLocations, 0, time.Minute)
+		checker.SetTransactionContext(common.StringToHash(test.reason), common.Hash{})
+		result := checker.checkSegments(daoTrace(), nil, ECFModeDefault, true)
+		if result.Inconclusive != test.reason || result.IsConclusive() != (test.reason == "") {
+			t.Errorf("limits %d/%d: expected inconclusive %q, got %+v", test.maxSegments, test.maxLocations, test.reason, result)
+		}
+		if test.reason != "" && len(result.Violations) != 0 {
+			t.Errorf("limits %d/%d: expected no check, got %+v", test.maxSegments, test.maxLocations, result.Violations)
+		}
+		checker.updateStats(result, time.Millisecond)
+	}
+	if stats := checker.Stats(); stats.Transactions != 4 || stats.NonECF != 2 || stats.Inconclusive != 2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	checks, err := checker.InconclusiveChecks(10)
+	if err != nil || len(checks) != 2 {
+		t.Fatalf("expected 2 stored inconclusive checks, got %v (%v)", checks, err)
+	}
+	if checks[0].Reason != InconclusiveLocations || checks[0].TxHash != common.StringToHash(InconclusiveLocations) || checks[0].Block != 5 || checks[0].Origin != origin || checks[0].Segments != 5 || checks[0].Canonical {
+		t.Errorf("unexpected stored check %+v", checks[0])
+	}
+
+	projection := GetProjectedTrace(daoTrace(), &checkerTestA)
+	expired := checkBudget{deadline: time.Now().Add(-time.Second)}
+	if subtrace, decided := findViolatingSubtrace(projection, attemptToRemoveRecursion, &traceCheckCounters{}, expired); decided || subtrace != nil {
+		t.Errorf("expected the check to stop, got %v", subtrace)
+	}
+	// Each step gives up on its own, not only between steps
+	if _, decided := findAndRemoveOmittables(projection, expired); decided {
+		t.Errorf("expected removing omittables to stop")
+	}
+	if index := findMinimalRecursiveSubTrace(projection, expired); index != -1 {
+		t.Errorf("expected the search for a minimal recursive subtrace to stop, got %d", index)
+	}
+	if cutpoint := findCutpoint(projection, projection[0].depth, expired); cutpoint != -1 {
+		t.Errorf("expected the search for a cutpoint to stop, got %d", cutpoint)
+	}
+	if timeout := expired.bound(time.Second); timeout != time.Nanosecond {
+		t.Errorf("expected the exact search to get no time, got %v", timeout)
+	}
+	if timeout := (checkBudget{}).bound(time.Second); timeout != time.Second {
+		t.Errorf("expected an unlimited budget to keep the timeout, got %v", timeout)
+	}
+	// The work limit does not depend on the machine, the same check gives up at the same point every time
+	for i := 0; i < 2; i++ {
+		checker.SetLimits(0, 0, 3, 0)
+		if result := checker.checkSegments(daoTrace(), nil, ECFModeDefault, false); result.Inconclusive != InconclusiveWork {
+			t.Errorf("expected the work limit to stop the check, got %+v", result)
+		}
+	}
+	checker.SetLimits(0, 0, 100, 0)
+	if result := checker.checkSegments(daoTrace(), nil, ECFModeDefault, false); !result.IsConclusive() {
+		t.Errorf("expected the check to fit in the work limit, got %+v", result)
+	}
+}
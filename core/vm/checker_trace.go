@@ -0,0 +1,272 @@
+// Shelly
+
+package vm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rlp"
+	set "gopkg.in/fatih/set.v0"
+)
+
+// SegmentTraceVersion is the version of the segment trace format written by this checker. Traces of other versions are
+// refused when loaded.
+const SegmentTraceVersion = 1
+
+// SegmentTrace is the full segment trace of a transaction, in a form that can be archived and checked again offline.
+// It is encoded as JSON (field names below, hashes and addresses as hex strings, values as decimal numbers) or as RLP
+// (the fields in order, a missing call as an empty list).
+type SegmentTrace struct {
+	Version     uint64           `json:"version"`
+	Transaction common.Hash      `json:"transaction"` // Zero if not known
+	Block       uint64           `json:"block"`
+	Origin      common.Address   `json:"origin"`
+	Attribution string           `json:"attribution"` // The storage attribution the trace was recorded with
+	Unharmable  []common.Address `json:"unharmable"`  // Contracts the static fast path found cannot be harmed by a callback
+	Segments    []TraceSegment   `json:"segments"`
+}
+
+// TraceSegment is a segment of a SegmentTrace. The read and write sets are sorted.
+type TraceSegment struct {
+	Contract           common.Address    `json:"contract"`
+	Depth              uint64            `json:"depth"`
+	IndexInTransaction uint64            `json:"indexInTransaction"`
+	IndexInCall        uint64            `json:"indexInCall"`
+	Reads              []common.Hash     `json:"reads"`
+	Writes             []common.Hash     `json:"writes"`
+	Accesses           []StorageAccess   `json:"accesses,omitempty"`    // With tagged or sub-segment attribution
+	SubSegments        []TraceSubSegment `json:"subSegments,omitempty"` // With sub-segment attribution
+	Call               *SegmentCall      `json:"call,omitempty" rlp:"nil"`
+}
+
+// TraceSubSegment is a sub-segment of a TraceSegment
+type TraceSubSegment struct {
+	Code   common.Address `json:"code"`
+	Reads  []common.Hash  `json:"reads"`
+	Writes []common.Hash  `json:"writes"`
+}
+
+// SegmentCall is what the checker keeps of the call that opened a segment
+type SegmentCall struct {
+	Caller    common.Address `json:"caller"`
+	Value     *big.Int       `json:"value"`
+	Selector  hexutil.Bytes  `json:"selector"` // The first 4 bytes of the input, fewer if the input is shorter
+	InputSize uint64         `json:"inputSize"`
+}
+
+func newSegmentCall(contract *Contract) *SegmentCall {
+	call := &SegmentCall{Caller: contract.CallerAddress, Value: new(big.Int), InputSize: uint64(len(contract.Input))}
+	if contract.value != nil {
+		call.Value.Set(contract.value)
+	}
+	selector := contract.Input
+	if len(selector) > 4 {
+		selector = selector[:4]
+	}
+	call.Selector = common.CopyBytes(selector)
+	return call
+}
+
+// traceSource keeps what is needed to build the SegmentTrace of the last checked transaction on request
+type traceSource struct {
+	segments       []Segment
+	cannotBeHarmed map[common.Address]bool
+	origin         *common.Address
+	block          *big.Int
+}
+
+// ECFTraceSlot receives the segment trace of the transaction run by an interpreter, see Config.ECFTrace. Unlike
+// LastTrace, it is not overwritten by the transactions other interpreters run meanwhile.
+type ECFTraceSlot struct {
+	Trace *SegmentTrace // nil if the transaction ran no code or the checker is disabled
+}
+
+// LastTrace returns the segment trace of the last transaction that ran code, or nil if there is none. Other
+// transactions may be checked concurrently, use Config.ECFTrace for the trace of a given run.
+func (checker *Checker) LastTrace() *SegmentTrace {
+	return checker.lastTrace.export(checker.attribution)
+}
+
+// export builds the SegmentTrace of the source, or returns nil if it has no segments
+func (source traceSource) export(attribution StorageAttribution) *SegmentTrace {
+	if len(source.segments) == 0 {
+		return nil
+	}
+	trace := &SegmentTrace{
+		Version:     SegmentTraceVersion,
+		Attribution: attribution.String(),
+		Unharmable:  make([]common.Address, 0),
+		Segments:    make([]TraceSegment, len(source.segments)),
+	}
+	if source.origin != nil {
+		trace.Origin = *source.origin
+	}
+	if source.block != nil {
+		trace.Block = source.block.Uint64()
+	}
+	for contract, skip := range source.cannotBeHarmed {
+		if skip {
+			trace.Unharmable = append(trace.Unharmable, contract)
+		}
+	}
+	sort.Sort(addressesByValue(trace.Unharmable))
+
+	for i := range source.segments {
+		trace.Segments[i] = source.segments[i].export()
+	}
+	return trace
+}
+
+// export returns the segment as recorded so far in the form of a SegmentTrace
+func (segment *Segment) export() TraceSegment {
+	exported := TraceSegment{
+		Contract:           segment.contract,
+		Depth:              uint64(segment.depth),
+		IndexInTransaction: uint64(segment.indexInTransaction),
+		IndexInCall:        uint64(segment.indexInCall),
+		Reads:              sortedLocations(segment.readSet),
+		Writes:             sortedLocations(segment.writeSet),
+		Call:               segment.call,
+	}
+	if segment.accesses != nil {
+		for _, access := range segment.accesses.List() {
+			exported.Accesses = append(exported.Accesses, access.(StorageAccess))
+		}
+		sort.Sort(accessesByValue(exported.Accesses))
+	}
+	for _, subSegment := range segment.subSegments {
+		exported.SubSegments = append(exported.SubSegments, TraceSubSegment{
+			Code:   subSegment.code,
+			Reads:  sortedLocations(subSegment.readSet),
+			Writes: sortedLocations(subSegment.writeSet),
+		})
+	}
+	return exported
+}
+
+// segments rebuilds the checker's segments from the trace, along with the contracts that are not checked
+func (trace *SegmentTrace) segments() ([]Segment, map[common.Address]bool, error) {
+	if trace.Version != SegmentTraceVersion {
+		return nil, nil, fmt.Errorf("unsupported segment trace version %d (want %d)", trace.Version, SegmentTraceVersion)
+	}
+	segments := make([]Segment, len(trace.Segments))
+	for i, exported := range trace.Segments {
+		if i > 0 && exported.IndexInTransaction <= trace.Segments[i-1].IndexInTransaction {
+			return nil, nil, fmt.Errorf("segment %d is out of order (index %d in the transaction)", i, exported.IndexInTransaction)
+		}
+		if exported.Depth == 0 {
+			return nil, nil, fmt.Errorf("segment %d has depth 0", i)
+		}
+		segment := Segment{
+			contract:           exported.Contract,
+			depth:              int(exported.Depth),
+			indexInTransaction: int(exported.IndexInTransaction),
+			indexInCall:        int(exported.IndexInCall),
+			readSet:            locationSet(exported.Reads),
+			writeSet:           locationSet(exported.Writes),
+			call:               exported.Call,
+		}
+		if i > 0 {
+			segment.prevSegment = &segments[i-1]
+		}
+		if len(exported.Accesses) > 0 {
+			segment.accesses = set.New()
+			for _, access := range exported.Accesses {
+				segment.accesses.Add(access)
+			}
+		}
+		for _, subSegment := range exported.SubSegments {
+			segment.subSegments = append(segment.subSegments, SubSegment{
+				code:     subSegment.Code,
+				readSet:  locationSet(subSegment.Reads),
+				writeSet: locationSet(subSegment.Writes),
+			})
+		}
+		segments[i] = segment
+	}
+	cannotBeHarmed := make(map[common.Address]bool)
+	for _, contract := range trace.Unharmable {
+		cannotBeHarmed[contract] = true
+	}
+	return segments, cannotBeHarmed, nil
+}
+
+// CheckTrace checks a recorded segment trace for ECF, as if its transaction had just run. Violations are not stored,
+// and the contracts the trace marks as unharmable are skipped only if the static fast path is enabled.
+func (checker *Checker) CheckTrace(trace *SegmentTrace, mode ECFCheckMode) (*ECFResult, error) {
+	segments, cannotBeHarmed, err := trace.segments()
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("empty segment trace")
+	}
+	if !checker.staticFastPath {
+		cannotBeHarmed = make(map[common.Address]bool)
+	}
+	return checker.checkSegments(segments, cannotBeHarmed, mode, false), nil
+}
+
+// DecodeSegmentTrace decodes a segment trace encoded as JSON or RLP, telling them apart by the first byte
+func DecodeSegmentTrace(data []byte) (*SegmentTrace, error) {
+	trace := new(SegmentTrace)
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, trace); err != nil {
+			return nil, err
+		}
+		return trace, nil
+	}
+	if err := rlp.DecodeBytes(data, trace); err != nil {
+		return nil, err
+	}
+	return trace, nil
+}
+
+func sortedLocations(locations set.Interface) []common.Hash {
+	sorted := make([]common.Hash, 0, locations.Size())
+	for _, location := range locations.List() {
+		sorted = append(sorted, location.(common.Hash))
+	}
+	sort.Sort(hashesByValue(sorted))
+	return sorted
+}
+
+func locationSet(locations []common.Hash) set.Interface {
+	s := set.New()
+	for _, location := range locations {
+		s.Add(location)
+	}
+	return s
+}
+
+type hashesByValue []common.Hash
+
+func (h hashesByValue) Len() int           { return len(h) }
+func (h hashesByValue) Less(i, j int) bool { return bytes.Compare(h[i][:], h[j][:]) < 0 }
+func (h hashesByValue) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+type addressesByValue []common.Address
+
+func (a addressesByValue) Len() int           { return len(a) }
+func (a addressesByValue) Less(i, j int) bool { return bytes.Compare(a[i][:], a[j][:]) < 0 }
+func (a addressesByValue) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+
+type accessesByValue []StorageAccess
+
+func (a accessesByValue) Len() int { return len(a) }
+func (a accessesByValue) Less(i, j int) bool {
+	if c := bytes.Compare(a[i].Location[:], a[j].Location[:]); c != 0 {
+		return c < 0
+	}
+	if c := bytes.Compare(a[i].Code[:], a[j].Code[:]); c != 0 {
+		return c < 0
+	}
+	return !a[i].Write && a[j].Write
+}
+func (a accessesByValue) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
@@ -0,0 +1,25 @@
+// Shelly
+
+package vm
+
+// SegmentTracer is a Tracer which also follows the segments of the ECF checker. When the EVM runs with Debug set and
+// a SegmentTracer, the checker tells it about each segment it enters and exits, and about its verdict once the
+// outermost call returns.
+type SegmentTracer interface {
+	Tracer
+	// CaptureEnterSegment is called when a segment starts, on a call or on a return to the caller
+	CaptureEnterSegment(segment TraceSegment)
+	// CaptureExitSegment is called when a segment ends, with the locations it read and wrote
+	CaptureExitSegment(segment TraceSegment)
+	// CaptureECFResult is called with the checker's verdict on the transaction
+	CaptureECFResult(result *ECFResult)
+}
+
+// segmentTracer returns the tracer the checker sends its segment events to, or nil if there is none
+func segmentTracer(evm *Interpreter) SegmentTracer {
+	if !evm.cfg.Debug {
+		return nil
+	}
+	tracer, _ := evm.cfg.Tracer.(SegmentTracer)
+	return tracer
+}
@@ -0,0 +1,253 @@
+// Shelly
+
+package vm
+
+import (
+	"database/sql"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// StoredViolation is a violation as recorded in the NON_REENTRANT_TRACE table
+type StoredViolation struct {
+	TransactionID int            `json:"transactionId"` // The checker's running count of EVM runs, see Checker.TransactionID
+	Origin        common.Address `json:"origin"`
+	Block         uint64         `json:"block"`
+	Time          uint64         `json:"time"` // Block time
+	TxHash        common.Hash    `json:"txHash"`
+	BlockHash     common.Hash    `json:"blockHash"` // Zero until the block is sealed
+	Canonical     bool           `json:"canonical"` // Whether the block is in the canonical chain, as of reading
+	Context       string         `json:"context"`   // What the transaction was run for, see ExecutionContext
+	ECFViolation
+}
+
+// ViolationFilter selects stored violations. Fields left nil match everything. Only violations of the canonical chain
+// are returned unless NonCanonical is set.
+type ViolationFilter struct {
+	FromBlock    *uint64         `json:"fromBlock"`
+	ToBlock      *uint64         `json:"toBlock"`
+	Contract     *common.Address `json:"contract"`
+	NonCanonical bool            `json:"nonCanonical"` // Also return the violations of side chains and orphaned blocks
+	Context      *string         `json:"context"`      // Only return the violations found in this execution context
+	Exempt       *bool           `json:"exempt"`       // Only return the violations that are, or are not, exempt
+}
+
+// Violations returns the stored violations matching the filter, ordered by block. Violations still queued for writing
+// are written first.
+func (checker *Checker) Violations(filter ViolationFilter) ([]*StoredViolation, error) {
+	violations := make([]*StoredViolation, 0)
+	if checker.dbHandler == nil {
+		return violations, nil
+	}
+	if err := checker.SyncFindings(); err != nil {
+		return nil, err
+	}
+	return QueryViolations(checker.dbHandler, filter)
+}
+
+// QueryViolations returns the violations matching the filter stored in an ECF database, ordered by block. It reads the
+// database as is, e.g. one opened offline, without waiting for the checker's queued writes.
+func QueryViolations(db *sql.DB, filter ViolationFilter) ([]*StoredViolation, error) {
+	violations := make([]*StoredViolation, 0)
+	var (
+		conditions = make([]string, 0)
+		args       = make([]interface{}, 0)
+	)
+	if filter.FromBlock != nil {
+		conditions = append(conditions, "block >= ?")
+		args = append(args, *filter.FromBlock)
+	}
+	if filter.ToBlock != nil {
+		conditions = append(conditions, "block <= ?")
+		args = append(args, *filter.ToBlock)
+	}
+	if filter.Contract != nil {
+		conditions = append(conditions, "contract = ?")
+		args = append(args, filter.Contract.Hex())
+	}
+	if filter.Context != nil {
+		conditions = append(conditions, "context = ?")
+		args = append(args, *filter.Context)
+	}
+	if filter.Exempt != nil {
+		conditions = append(conditions, "exempt = ?")
+		args = append(args, *filter.Exempt)
+	}
+	if !filter.NonCanonical {
+		conditions = append(conditions, "canonical = 1")
+	}
+	query := "select id, origin, block, time, contract, depth, start_index, length, tx_hash, block_hash, canonical, context, exempt from NON_REENTRANT_TRACE"
+	if len(conditions) > 0 {
+		query += " where " + strings.Join(conditions, " and ")
+	}
+	query += " order by block, id"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			violation                           StoredViolation
+			origin, contract, txHash, blockHash string
+		)
+		if err := rows.Scan(&violation.TransactionID, &origin, &violation.Block, &violation.Time, &contract, &violation.Depth, &violation.StartIndex, &violation.Length, &txHash, &blockHash, &violation.Canonical, &violation.Context, &violation.Exempt); err != nil {
+			return nil, err
+		}
+		violation.TxHash = common.HexToHash(txHash)
+		violation.BlockHash = common.HexToHash(blockHash)
+		violation.Origin = common.HexToAddress(origin)
+		violation.Contract = common.HexToAddress(contract)
+		violations = append(violations, &violation)
+	}
+	return violations, rows.Err()
+}
+
+// ECFStatus describes how the checker is set up and what it did so far
+type ECFStatus struct {
+	Enabled          bool            `json:"enabled"`
+	Mode             string          `json:"mode"`
+	Attribution      string          `json:"attribution"`
+	StaticFastPath   bool            `json:"staticFastPath"`
+	LogsAsWrites     bool            `json:"logsAsWrites"`
+	VerdictIndex     bool            `json:"verdictIndex"` // Whether verdicts are kept in the chain database
+	ExactMaxSegments int             `json:"exactMaxSegments"`
+	ExactTimeout     string          `json:"exactTimeout"`
+	MaxSegments      int             `json:"maxSegments"`
+	MaxLocations     int             `json:"maxLocations"`
+	MaxCheckWork     int             `json:"maxCheckWork"`
+	MaxCheckTime     string          `json:"maxCheckTime"`
+	Database         bool            `json:"database"`  // Whether violations and profiles are stored
+	Persisted        []string        `json:"persisted"` // The execution contexts whose findings are stored, null for all
+	Registry         *common.Address `json:"registry"`  // The exemption registry consulted, if any
+	TransactionID    int             `json:"transactionId"`
+	Stats            ECFStats        `json:"stats"`
+}
+
+// Status returns the checker's settings and counters
+func (checker *Checker) Status() ECFStatus {
+	return ECFStatus{
+		Enabled:          checker.Enabled(),
+		Mode:             checker.mode.String(),
+		Attribution:      checker.attribution.String(),
+		StaticFastPath:   checker.staticFastPath,
+		LogsAsWrites:     checker.logsAsWrites,
+		VerdictIndex:     checker.verdictIndex,
+		Persisted:        checker.persistedContextNames(),
+		Registry:         checker.exemptionRegistry,
+		ExactMaxSegments: checker.exactMaxSegments,
+		ExactTimeout:     checker.exactTimeout.String(),
+		MaxSegments:      checker.maxSegments,
+		MaxLocations:     checker.maxLocations,
+		MaxCheckWork:     checker.maxCheckWork,
+		MaxCheckTime:     checker.maxCheckTime.String(),
+		Database:         checker.dbHandler != nil,
+		TransactionID:    checker.TransactionID,
+		Stats:            checker.Stats(),
+	}
+}
+
+const (
+	enabledUnchanged int32 = iota
+	enabledPendingOn
+	enabledPendingOff
+)
+
+// Enabled reports whether the checker follows the EVM, or will from the next transaction on
+func (checker *Checker) Enabled() bool {
+	switch atomic.LoadInt32(&checker.pendingEnabled) {
+	case enabledPendingOn:
+		return true
+	case enabledPendingOff:
+		return false
+	}
+	return !DISABLE_CHECKER
+}
+
+// SetEnabled turns the checker on or off. As the checker keeps the state of the running transaction, the change takes
+// effect when the next transaction starts.
+func (checker *Checker) SetEnabled(enabled bool) {
+	pending := enabledPendingOff
+	if enabled {
+		pending = enabledPendingOn
+	}
+	atomic.StoreInt32(&checker.pendingEnabled, pending)
+	ImportantDebug("ECF checker will be enabled: %v, from the next transaction", enabled)
+}
+
+// applyPendingEnabled switches the checker on or off as requested by SetEnabled. It must only be called between
+// transactions.
+func (checker *Checker) applyPendingEnabled() {
+	switch atomic.SwapInt32(&checker.pendingEnabled, enabledUnchanged) {
+	case enabledPendingOn:
+		DISABLE_CHECKER = false
+	case enabledPendingOff:
+		DISABLE_CHECKER = true
+	default:
+		return
+	}
+	ImportantDebug("Disable ECF Checker is set to: %v", DISABLE_CHECKER)
+}
+
+const recentViolationsSize = 64 // Number of violations kept in memory for RecentViolations
+
+// violationRing keeps the last violations found, whether or not they are stored in a database
+type violationRing struct {
+	lock       sync.Mutex
+	violations []*StoredViolation
+	next       int
+}
+
+func (ring *violationRing) add(violation *StoredViolation) {
+	ring.lock.Lock()
+	defer ring.lock.Unlock()
+
+	if len(ring.violations) < recentViolationsSize {
+		ring.violations = append(ring.violations, violation)
+		return
+	}
+	ring.violations[ring.next] = violation
+	ring.next = (ring.next + 1) % recentViolationsSize
+}
+
+// last returns up to n violations, the most recent first
+func (ring *violationRing) last(n int) []*StoredViolation {
+	ring.lock.Lock()
+	defer ring.lock.Unlock()
+
+	if n > len(ring.violations) {
+		n = len(ring.violations)
+	}
+	violations := make([]*StoredViolation, 0, n)
+	for i := 1; i <= n; i++ {
+		index := (ring.next - i + len(ring.violations)) % len(ring.violations)
+		violations = append(violations, ring.violations[index])
+	}
+	return violations
+}
+
+// storedViolation completes a violation of the running transaction with the transaction's details
+func (checker *Checker) storedViolation(violation ECFViolation) *StoredViolation {
+	stored := &StoredViolation{TransactionID: checker.TransactionID, TxHash: checker.txHash, BlockHash: checker.blockHash, Context: checker.context.String(), ECFViolation: violation}
+	if checker.origin != nil {
+		stored.Origin = *checker.origin
+	}
+	if checker.blockNumber != nil {
+		stored.Block = checker.blockNumber.Uint64()
+	}
+	if checker.time != nil {
+		stored.Time = checker.time.Uint64()
+	}
+	return stored
+}
+
+// RecentViolations returns up to n of the last violations found since the node started, the most recent first. Unlike
+// Violations, it works without a database.
+func (checker *Checker) RecentViolations(n int) []*StoredViolation {
+	return checker.recentViolations.last(n)
+}
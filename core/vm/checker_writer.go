@@ -0,0 +1,304 @@
+// Shelly
+
+package vm
+
+import (
+	"database/sql"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	findingsQueueSize     = 1024        // Writes queued before the checker waits for the database
+	findingsBatchSize     = 256         // Writes committed in one database transaction at most
+	findingsFlushInterval = time.Second // Longest time a queued write waits for its batch
+)
+
+// findingsWrite is a statement changing the stored findings, along with the checker's transaction counter and last
+// checked block when it was queued. A write without a statement only carries the checkpoint.
+type findingsWrite struct {
+	what          string // What the statement does, for logging failures
+	stmt          string
+	args          []interface{}
+	transactionID int
+	checkedBlock  uint64
+}
+
+// findingsWriter writes the checker's findings to ecf.db from a background goroutine, so that block processing does
+// not wait for the database. Writes are committed in order, in batches, each batch in a single database transaction
+// which also checkpoints the transaction counter and the last block whose findings were all queued before the batch's
+// writes. A crash loses the writes not committed yet, but the stored findings and checkpoints always agree: no
+// transaction ID is stored twice after a restart, and the canonical blocks after the checkpoint are checked again on
+// startup (see core.BlockChain.RecheckECF), their stored findings being dropped first (see ForgetBlockFindings).
+type findingsWriter struct {
+	db    *sql.DB
+	queue chan findingsWrite
+	flush chan chan error
+	quit  chan struct{}
+	done  chan struct{}
+}
+
+func newFindingsWriter(db *sql.DB) *findingsWriter {
+	w := &findingsWriter{
+		db:    db,
+		queue: make(chan findingsWrite, findingsQueueSize),
+		flush: make(chan chan error),
+		quit:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go w.loop()
+	return w
+}
+
+// enqueue queues a write, waiting for room in the queue if the database falls behind
+func (w *findingsWriter) enqueue(write findingsWrite) {
+	select {
+	case w.queue <- write:
+	default:
+		start := time.Now()
+		w.queue <- write
+		ecfWriterStallTimer.UpdateSince(start)
+	}
+	ecfWriterQueuedMeter.Mark(1)
+}
+
+// sync waits until all writes queued so far are committed, and returns the error of the last batch
+func (w *findingsWriter) sync() error {
+	result := make(chan error)
+	select {
+	case w.flush <- result:
+		return <-result
+	case <-w.done:
+		return nil
+	}
+}
+
+// close commits the queued writes and stops the writer
+func (w *findingsWriter) close() {
+	close(w.quit)
+	<-w.done
+}
+
+func (w *findingsWriter) loop() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(findingsFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]findingsWrite, 0, findingsBatchSize)
+	for {
+		select {
+		case write := <-w.queue:
+			if batch = append(batch, write); len(batch) >= findingsBatchSize {
+				w.commit(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			w.commit(batch)
+			batch = batch[:0]
+		case result := <-w.flush:
+			batch = w.drain(batch)
+			result <- w.commit(batch)
+			batch = batch[:0]
+		case <-w.quit:
+			w.commit(w.drain(batch))
+			return
+		}
+	}
+}
+
+// drain appends the writes waiting in the queue to the batch, committing full batches on the way
+func (w *findingsWriter) drain(batch []findingsWrite) []findingsWrite {
+	for {
+		select {
+		case write := <-w.queue:
+			if batch = append(batch, write); len(batch) >= findingsBatchSize {
+				w.commit(batch)
+				batch = batch[:0]
+			}
+		default:
+			return batch
+		}
+	}
+}
+
+// commit writes a batch in a single database transaction, along with the highest transaction counter of its writes.
+// A failing statement is skipped, SQLite undoing just that statement, and the rest of the batch is committed. The error
+// of the first failing statement is returned. If the transaction itself fails, the batch is dropped as a whole.
+func (w *findingsWriter) commit(batch []findingsWrite) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	start := time.Now()
+	defer ecfWriterBatchTimer.UpdateSince(start)
+
+	tx, err := w.db.Begin()
+	if err != nil {
+		ImportantDebug("Failed to start writing %d findings: %v", len(batch), err)
+		ecfWriterFailedMeter.Mark(int64(len(batch)))
+		return err
+	}
+	var (
+		checkpoint   int
+		checkedBlock uint64
+		failed       int
+		failure      error
+	)
+	for _, write := range batch {
+		if write.stmt != "" {
+			if _, err := tx.Exec(write.stmt, write.args...); err != nil {
+				ImportantDebug("Failed to %s, skipping it: %v", write.what, err)
+				if failure == nil {
+					failure = err
+				}
+				failed++
+			}
+		}
+		if write.transactionID > checkpoint {
+			checkpoint = write.transactionID
+		}
+		if write.checkedBlock > checkedBlock {
+			checkedBlock = write.checkedBlock
+		}
+	}
+	if checkpoint > 0 {
+		if _, err := tx.Exec("update LAST_TRANSACTION_ID set txId = ? where txId < ?", checkpoint, checkpoint); err != nil {
+			ImportantDebug("Failed to checkpoint transaction %d, dropping %d findings: %v", checkpoint, len(batch), err)
+			tx.Rollback()
+			ecfWriterFailedMeter.Mark(int64(len(batch)))
+			return err
+		}
+	}
+	if checkedBlock > 0 {
+		if _, err := tx.Exec(checkpointBlockStmt, checkedBlock, checkedBlock); err != nil {
+			ImportantDebug("Failed to checkpoint block %d, dropping %d findings: %v", checkedBlock, len(batch), err)
+			tx.Rollback()
+			ecfWriterFailedMeter.Mark(int64(len(batch)))
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		ImportantDebug("Failed to commit %d findings: %v", len(batch), err)
+		ecfWriterFailedMeter.Mark(int64(len(batch)))
+		return err
+	}
+	ecfWriterFailedMeter.Mark(int64(failed))
+	ecfWriterWrittenMeter.Mark(int64(len(batch) - failed))
+	Debug(2, "Wrote %d findings, checkpoint at transaction %d and block %d", len(batch)-failed, checkpoint, checkedBlock)
+	return failure
+}
+
+// checkpointBlockStmt raises the stored last checked block to the given one
+const checkpointBlockStmt = "insert or replace into LAST_CHECKED_BLOCK(id, block) select 0, ? where not exists (select 1 from LAST_CHECKED_BLOCK where block >= ?)"
+
+// write stores a change to the findings, through the background writer if it runs, directly otherwise (e.g. in tests
+// setting the database without SetDbHandler)
+func (checker *Checker) write(what, stmt string, args ...interface{}) {
+	checker.writerLock.RLock()
+	defer checker.writerLock.RUnlock()
+
+	if checker.writer != nil {
+		checker.writer.enqueue(findingsWrite{what: what, stmt: stmt, args: args, transactionID: checker.TransactionID, checkedBlock: atomic.LoadUint64(&checker.checkedBlock)})
+		return
+	}
+	if stmt == "" {
+		return
+	}
+	if _, err := checker.dbHandler.Exec(stmt, args...); err != nil {
+		ImportantDebug("Failed to %s: %v", what, err)
+	}
+}
+
+// BlockChecked records that the findings of an imported block are all reported, whether the checker is enabled or
+// not, so that a restart does not check again the blocks imported while it was disabled. The block is checkpointed
+// along with the changed contract profiles, hence not before they are queued, see queueProfiles.
+func (checker *Checker) BlockChecked(number uint64) {
+	if checker.dbHandler == nil {
+		return
+	}
+	checker.raiseBlock(&checker.passedBlock, number)
+	if !checker.queueProfiles(number) {
+		return
+	}
+	checker.writerLock.RLock()
+	running := checker.writer != nil
+	checker.writerLock.RUnlock()
+	if running {
+		checker.write("checkpoint block", "")
+	} else {
+		checker.write("checkpoint block", checkpointBlockStmt, number, number)
+	}
+}
+
+// raiseBlock sets the given block number to the given one if that is higher
+func (checker *Checker) raiseBlock(block *uint64, number uint64) {
+	for {
+		current := atomic.LoadUint64(block)
+		if number <= current || atomic.CompareAndSwapUint64(block, current, number) {
+			return
+		}
+	}
+}
+
+// LastCheckedBlock returns the last block whose findings are all stored, and false if no block was checkpointed yet
+func (checker *Checker) LastCheckedBlock() (uint64, bool, error) {
+	if checker.dbHandler == nil {
+		return 0, false, nil
+	}
+	if err := checker.SyncFindings(); err != nil {
+		return 0, false, err
+	}
+	var number uint64
+	switch err := checker.dbHandler.QueryRow("select block from LAST_CHECKED_BLOCK").Scan(&number); err {
+	case nil:
+		return number, true, nil
+	case sql.ErrNoRows:
+		return 0, false, nil
+	default:
+		return 0, false, err
+	}
+}
+
+// ForgetBlockFindings drops the stored findings of the given block, before it is checked again
+func (checker *Checker) ForgetBlockFindings(blockHash common.Hash) {
+	if checker.dbHandler == nil {
+		return
+	}
+	checker.write("forget the violations of block "+blockHash.Hex(), "delete from NON_REENTRANT_TRACE where block_hash = ?", blockHash.Hex())
+	checker.write("forget the inconclusive checks of block "+blockHash.Hex(), "delete from INCONCLUSIVE_CHECK where block_hash = ?", blockHash.Hex())
+}
+
+// SyncFindings waits until the findings reported so far are written to the database
+func (checker *Checker) SyncFindings() error {
+	checker.writerLock.RLock()
+	defer checker.writerLock.RUnlock()
+
+	if checker.writer == nil {
+		return nil
+	}
+	return checker.writer.sync()
+}
+
+// startWriter starts writing the findings to the database in the background, replacing the running writer if any
+func (checker *Checker) startWriter(db *sql.DB) {
+	checker.StopWriter()
+
+	checker.writerLock.Lock()
+	defer checker.writerLock.Unlock()
+	if db != nil {
+		checker.writer = newFindingsWriter(db)
+	}
+}
+
+// StopWriter writes the queued findings and stops the background writer. Later findings are written directly.
+func (checker *Checker) StopWriter() {
+	checker.writerLock.Lock()
+	defer checker.writerLock.Unlock()
+
+	if checker.writer != nil {
+		checker.writer.close()
+		checker.writer = nil
+	}
+}
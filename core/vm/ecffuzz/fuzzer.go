@@ -0,0 +1,299 @@
+// Shelly
+
+// Package ecffuzz searches for reentrancy attacks on a contract. It deploys the
+// contract on a simulated chain along with generated attacker contracts that
+// re-enter it from their fallback, runs random sequences of calls and uses the
+// ECF checker as the oracle.
+package ecffuzz
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/net/context"
+)
+
+const txGas = 3000000 // Gas limit of every transaction of an attack
+
+var (
+	errCheckerDisabled = errors.New("the ECF checker is disabled (EVM_DISABLE_ECF_CHECK)")
+	errNoMethods       = errors.New("the contract has no non-constant method with supported arguments")
+
+	// The attacking account, funded in the genesis of each simulated chain
+	ownerKey, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+	owner       = crypto.PubkeyToAddress(ownerKey.PublicKey)
+)
+
+// Config sets the bounds of the search
+type Config struct {
+	Iterations int      // Number of random attacks to run
+	MaxDepth   int      // Maximum number of re-entries per triggered call
+	MaxSteps   int      // Maximum number of calls per attack
+	Value      *big.Int // Maximum value sent along a call
+	Seed       int64    // Seed of the random generator, to reproduce a search
+}
+
+// DefaultConfig is a quick search, suitable for small contracts
+var DefaultConfig = Config{
+	Iterations: 200,
+	MaxDepth:   3,
+	MaxSteps:   4,
+	Value:      big.NewInt(1e18),
+}
+
+// Call is a call of a method of the target
+type Call struct {
+	Method      string        `json:"method"`
+	Args        []interface{} `json:"args"`
+	Input       hexutil.Bytes `json:"input"`
+	Value       *big.Int      `json:"value"`
+	ViaAttacker bool          `json:"viaAttacker"` // Sent through the attacker contract, otherwise directly by the attacking account
+}
+
+func (call Call) String() string {
+	args := make([]string, len(call.Args))
+	for i, arg := range call.Args {
+		switch arg := arg.(type) {
+		case common.Address:
+			args[i] = arg.Hex()
+		case []byte:
+			args[i] = hexutil.Encode(arg)
+		default:
+			args[i] = fmt.Sprintf("%v", arg)
+		}
+	}
+	from := "account"
+	if call.ViaAttacker {
+		from = "attacker"
+	}
+	return fmt.Sprintf("%s(%s) value %v from %s", call.Method, strings.Join(args, ", "), call.Value, from)
+}
+
+// Finding is a minimised attack whose last call is not ECF
+type Finding struct {
+	Steps        []Call            `json:"steps"`        // Calls in order, the last one violating ECF
+	Reentry      Call              `json:"reentry"`      // Call the attacker makes from its fallback
+	Depth        int               `json:"depth"`        // Number of re-entries per triggered call
+	AttackerCode hexutil.Bytes     `json:"attackerCode"` // Runtime code of the attacker contract
+	Violations   []vm.ECFViolation `json:"violations"`
+}
+
+// attack is a candidate sequence of calls
+type attack struct {
+	steps   []Call
+	reentry Call
+	depth   int
+}
+
+// Fuzzer searches for reentrancy attacks on a contract
+type Fuzzer struct {
+	abi     abi.ABI
+	code    []byte
+	config  Config
+	methods []abi.Method // Non-constant methods with supported arguments, by name
+	rand    *rand.Rand
+
+	// The contracts are deployed by the owner first, so their addresses are the same on every simulated chain
+	target   common.Address
+	attacker common.Address
+
+	inputs *inputs
+}
+
+// New creates a fuzzer for a contract given its ABI and creation code
+func New(contractABI abi.ABI, code []byte, config Config) (*Fuzzer, error) {
+	if !vm.TheChecker().Enabled() {
+		return nil, errCheckerDisabled
+	}
+	if config.MaxDepth < 1 || config.MaxSteps < 1 {
+		return nil, fmt.Errorf("invalid bounds: depth %d, steps %d", config.MaxDepth, config.MaxSteps)
+	}
+	if config.Value == nil {
+		config.Value = DefaultConfig.Value
+	}
+
+	fuzzer := &Fuzzer{
+		abi:      contractABI,
+		code:     code,
+		config:   config,
+		rand:     rand.New(rand.NewSource(config.Seed)),
+		target:   crypto.CreateAddress(owner, 0),
+		attacker: crypto.CreateAddress(owner, 1),
+	}
+	names := make([]string, 0, len(contractABI.Methods))
+	for name, method := range contractABI.Methods {
+		if !method.Const && supported(method) == nil {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, errNoMethods
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fuzzer.methods = append(fuzzer.methods, contractABI.Methods[name])
+	}
+	fuzzer.inputs = newInputs(fuzzer.rand, []common.Address{fuzzer.attacker, owner, fuzzer.target, {}}, config.Value)
+	return fuzzer, nil
+}
+
+// Run runs the configured number of random attacks, and returns the distinct
+// attacks found to violate ECF, each minimised
+func (f *Fuzzer) Run() ([]*Finding, error) {
+	var (
+		findings = make([]*Finding, 0)
+		seen     = make(map[string]bool)
+	)
+	for i := 0; i < f.config.Iterations; i++ {
+		a := f.randomAttack()
+		result, failing, err := f.execute(a)
+		if err != nil {
+			return findings, err
+		}
+		if result == nil {
+			continue
+		}
+		a.steps = a.steps[:failing+1]
+		if a, result, err = f.minimise(a, result); err != nil {
+			return findings, err
+		}
+		// Attacks calling the same methods in the same order are reported once
+		key := a.reentry.Method
+		for _, step := range a.steps {
+			key += "," + step.Method
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		vm.ImportantDebug("ecffuzz: attack %d violates ECF: %d step(s), re-entering %s", i, len(a.steps), a.reentry.Method)
+		findings = append(findings, &Finding{
+			Steps:        a.steps,
+			Reentry:      a.reentry,
+			Depth:        a.depth,
+			AttackerCode: attackerRuntime(owner, f.target, a.depth, a.reentry.Input),
+			Violations:   result.Violations,
+		})
+	}
+	return findings, nil
+}
+
+func (f *Fuzzer) randomCall() Call {
+	method := f.methods[f.rand.Intn(len(f.methods))]
+	args := f.inputs.args(method)
+	input, err := f.abi.Pack(method.Name, args...)
+	if err != nil {
+		panic(fmt.Sprintf("ecffuzz: failed to pack generated arguments of %s: %v", method.Name, err))
+	}
+	return Call{Method: method.Name, Args: args, Input: input, Value: f.inputs.value(f.config.Value), ViaAttacker: f.rand.Intn(2) == 0}
+}
+
+func (f *Fuzzer) randomAttack() *attack {
+	a := &attack{reentry: f.randomCall(), depth: 1 + f.rand.Intn(f.config.MaxDepth)}
+	a.reentry.Value, a.reentry.ViaAttacker = big.NewInt(0), true // The attacker re-enters without sending value
+
+	steps := 1 + f.rand.Intn(f.config.MaxSteps)
+	for i := 0; i < steps; i++ {
+		a.steps = append(a.steps, f.randomCall())
+	}
+	a.steps[steps-1].ViaAttacker = true // Only calls through the attacker can be re-entered
+	return a
+}
+
+// execute runs the attack on a fresh simulated chain. It returns the result of
+// the first call not ECF along with its index, or a nil result if all are ECF.
+func (f *Fuzzer) execute(a *attack) (*vm.ECFResult, int, error) {
+	balance := new(big.Int).Mul(f.config.Value, big.NewInt(int64(10*(len(a.steps)+1))))
+	sim := backends.NewSimulatedBackend(core.GenesisAccount{Address: owner, Balance: balance.Add(balance, big.NewInt(1e18))})
+
+	var nonce uint64
+	send := func(to *common.Address, value *big.Int, data []byte) (*types.Transaction, error) {
+		var tx *types.Transaction
+		if to == nil {
+			tx = types.NewContractCreation(nonce, value, big.NewInt(txGas), big.NewInt(1), data)
+		} else {
+			tx = types.NewTransaction(nonce, *to, value, big.NewInt(txGas), big.NewInt(1), data)
+		}
+		tx, err := types.SignTx(tx, types.HomesteadSigner{}, ownerKey)
+		if err != nil {
+			return nil, err
+		}
+		nonce++
+		if err := sim.SendTransaction(context.Background(), tx); err != nil {
+			return nil, err
+		}
+		sim.Commit()
+		return tx, nil
+	}
+
+	if _, err := send(nil, new(big.Int), f.code); err != nil {
+		return nil, 0, err
+	}
+	if code, _ := sim.CodeAt(context.Background(), f.target, nil); len(code) == 0 {
+		return nil, 0, errors.New("the target contract could not be deployed")
+	}
+	if _, err := send(nil, new(big.Int), creationCode(attackerRuntime(owner, f.target, a.depth, a.reentry.Input))); err != nil {
+		return nil, 0, err
+	}
+
+	for i, step := range a.steps {
+		to := &f.target
+		if step.ViaAttacker {
+			to = &f.attacker
+		}
+		tx, err := send(to, step.Value, step.Input)
+		if err != nil {
+			return nil, 0, err
+		}
+		if result, _ := sim.ECFVerdict(tx.Hash()); result != nil && !result.IsECF() {
+			return result, i, nil
+		}
+	}
+	return nil, 0, nil
+}
+
+// minimise drops the calls and re-entries the attack does not need to violate ECF
+func (f *Fuzzer) minimise(a *attack, result *vm.ECFResult) (*attack, *vm.ECFResult, error) {
+	// Drop each step before the violating one in turn, keeping the drop if the attack still succeeds
+	for i := len(a.steps) - 2; i >= 0; i-- {
+		candidate := &attack{reentry: a.reentry, depth: a.depth}
+		candidate.steps = append(append([]Call{}, a.steps[:i]...), a.steps[i+1:]...)
+		candidateResult, failing, err := f.execute(candidate)
+		if err != nil {
+			return nil, nil, err
+		}
+		if candidateResult != nil {
+			candidate.steps = candidate.steps[:failing+1]
+			a, result = candidate, candidateResult
+			if i > len(a.steps)-1 {
+				i = len(a.steps) - 1
+			}
+		}
+	}
+	// Lower the number of re-entries as far as possible
+	for a.depth > 1 {
+		candidate := &attack{steps: a.steps, reentry: a.reentry, depth: a.depth - 1}
+		candidateResult, failing, err := f.execute(candidate)
+		if err != nil {
+			return nil, nil, err
+		}
+		if candidateResult == nil {
+			break
+		}
+		candidate.steps = candidate.steps[:failing+1]
+		a, result = candidate, candidateResult
+	}
+	return a, result, nil
+}
@@ -0,0 +1,63 @@
+// Shelly
+
+package ecffuzz
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The SimpleDAO of RunningExample, which updates the credit after sending it
+// (vulnerable) or before (ECF)
+const (
+	simpleDAOABI       = `[{"constant":false,"inputs":[{"name":"to","type":"address"}],"name":"donate","outputs":[],"payable":true,"stateMutability":"payable","type":"function"},{"constant":false,"inputs":[{"name":"amount","type":"uint256"}],"name":"withdraw","outputs":[],"payable":false,"stateMutability":"nonpayable","type":"function"},{"constant":false,"inputs":[{"name":"to","type":"address"}],"name":"queryCredit","outputs":[{"name":"","type":"uint256"}],"payable":false,"stateMutability":"nonpayable","type":"function"},{"constant":true,"inputs":[{"name":"","type":"address"}],"name":"credit","outputs":[{"name":"","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"}]`
+	simpleDAOCode      = "6060604052341561000f57600080fd5b6102ef8061001e6000396000f30060606040526000357c0100000000000000000000000000000000000000000000000000000000900463ffffffff168062362a951461005d5780632e1a7d4d1461008b57806359f1286d146100ae578063d5d44d80146100fb57600080fd5b610089600480803573ffffffffffffffffffffffffffffffffffffffff16906020019091905050610148565b005b341561009657600080fd5b6100ac6004808035906020019091905050610197565b005b34156100b957600080fd5b6100e5600480803573ffffffffffffffffffffffffffffffffffffffff16906020019091905050610263565b6040518082815260200191505060405180910390f35b341561010657600080fd5b610132600480803573ffffffffffffffffffffffffffffffffffffffff169060200190919050506102ab565b6040518082815260200191505060405180910390f35b346000808373ffffffffffffffffffffffffffffffffffffffff1673ffffffffffffffffffffffffffffffffffffffff1681526020019081526020016000206000828254019250508190555050565b6000816000803373ffffffffffffffffffffffffffffffffffffffff1673ffffffffffffffffffffffffffffffffffffffff1681526020019081526020016000205410151561025f573373ffffffffffffffffffffffffffffffffffffffff168260405160006040518083038185876187965a03f1925050509050816000803373ffffffffffffffffffffffffffffffffffffffff1673ffffffffffffffffffffffffffffffffffffffff168152602001908152602001600020600082825403925050819055505b5050565b60008060008373ffffffffffffffffffffffffffffffffffffffff1673ffffffffffffffffffffffffffffffffffffffff168152602001908152602001600020549050919050565b600060205280600052604060002060009150905054815600a165627a7a72305820ebe2d7f0315f16e9080ca5a1b56fe1664fe92e118cd79b5493a59978fee5e0120029"
+	simpleDAOFixedCode = "6060604052341561000f57600080fd5b6102ef8061001e6000396000f30060606040526000357c0100000000000000000000000000000000000000000000000000000000900463ffffffff168062362a951461005d5780632e1a7d4d1461008b57806359f1286d146100ae578063d5d44d80146100fb57600080fd5b610089600480803573ffffffffffffffffffffffffffffffffffffffff16906020019091905050610148565b005b341561009657600080fd5b6100ac6004808035906020019091905050610197565b005b34156100b957600080fd5b6100e5600480803573ffffffffffffffffffffffffffffffffffffffff16906020019091905050610263565b6040518082815260200191505060405180910390f35b341561010657600080fd5b610132600480803573ffffffffffffffffffffffffffffffffffffffff169060200190919050506102ab565b6040518082815260200191505060405180910390f35b346000808373ffffffffffffffffffffffffffffffffffffffff1673ffffffffffffffffffffffffffffffffffffffff1681526020019081526020016000206000828254019250508190555050565b6000816000803373ffffffffffffffffffffffffffffffffffffffff1673ffffffffffffffffffffffffffffffffffffffff1681526020019081526020016000205410151561025f57816000803373ffffffffffffffffffffffffffffffffffffffff1673ffffffffffffffffffffffffffffffffffffffff168152602001908152602001600020600082825403925050819055503373ffffffffffffffffffffffffffffffffffffffff168260405160006040518083038185876187965a03f19250505090505b5050565b60008060008373ffffffffffffffffffffffffffffffffffffffff1673ffffffffffffffffffffffffffffffffffffffff168152602001908152602001600020549050919050565b600060205280600052604060002060009150905054815600a165627a7a7230582077c046a36ad8da453c0f8b02399500d936f1848ddc05cfe97863583ebc3348460029"
+)
+
+func newSimpleDAOFuzzer(t *testing.T, code string) *Fuzzer {
+	parsed, err := abi.JSON(strings.NewReader(simpleDAOABI))
+	if err != nil {
+		t.Fatalf("failed to parse ABI: %v", err)
+	}
+	config := DefaultConfig
+	config.Iterations = 200
+	fuzzer, err := New(parsed, common.FromHex(code), config)
+	if err != nil {
+		t.Fatalf("failed to create fuzzer: %v", err)
+	}
+	return fuzzer
+}
+
+func TestFuzzSimpleDAO(t *testing.T) {
+	findings, err := newSimpleDAOFuzzer(t, simpleDAOCode).Run()
+	if err != nil {
+		t.Fatalf("fuzzing failed: %v", err)
+	}
+	if len(findings) == 0 {
+		t.Fatalf("no attack found on the vulnerable SimpleDAO")
+	}
+	for _, finding := range findings {
+		last := finding.Steps[len(finding.Steps)-1]
+		if !last.ViaAttacker || len(finding.Violations) == 0 || len(finding.AttackerCode) == 0 {
+			t.Errorf("incomplete finding: %+v", finding)
+		}
+		// Only withdraw calls the attacker back
+		if last.Method != "withdraw" {
+			t.Errorf("finding re-enters through %s, want withdraw", last.Method)
+		}
+	}
+}
+
+func TestFuzzFixedSimpleDAO(t *testing.T) {
+	findings, err := newSimpleDAOFuzzer(t, simpleDAOFixedCode).Run()
+	if err != nil {
+		t.Fatalf("fuzzing failed: %v", err)
+	}
+	if len(findings) != 0 {
+		t.Fatalf("found %d attack(s) on the ECF SimpleDAO, first: %+v", len(findings), findings[0])
+	}
+}
@@ -0,0 +1,131 @@
+// Shelly
+
+package ecffuzz
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand"
+	"reflect"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Go types of the fixed size integers, as abi.Pack expects them
+var intTypes = map[reflect.Kind]reflect.Type{
+	reflect.Uint8:  reflect.TypeOf(uint8(0)),
+	reflect.Uint16: reflect.TypeOf(uint16(0)),
+	reflect.Uint32: reflect.TypeOf(uint32(0)),
+	reflect.Uint64: reflect.TypeOf(uint64(0)),
+	reflect.Int8:   reflect.TypeOf(int8(0)),
+	reflect.Int16:  reflect.TypeOf(int16(0)),
+	reflect.Int32:  reflect.TypeOf(int32(0)),
+	reflect.Int64:  reflect.TypeOf(int64(0)),
+}
+
+// inputs draws random arguments from pools of values likely to matter to a
+// contract: the accounts taking part in the attack and amounts close to the
+// value sent along
+type inputs struct {
+	rand      *rand.Rand
+	addresses []common.Address
+	amounts   []*big.Int
+}
+
+func newInputs(rand *rand.Rand, addresses []common.Address, value *big.Int) *inputs {
+	amounts := []*big.Int{
+		big.NewInt(0),
+		big.NewInt(1),
+		big.NewInt(1000),
+		new(big.Int).Set(value),
+		new(big.Int).Div(value, big.NewInt(2)),
+		new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1)),
+	}
+	return &inputs{rand: rand, addresses: addresses, amounts: amounts}
+}
+
+// supported reports whether arguments can be generated for all inputs of the method
+func supported(method abi.Method) error {
+	for _, input := range method.Inputs {
+		switch input.Type.T {
+		case abi.IntTy, abi.UintTy, abi.BoolTy, abi.AddressTy, abi.StringTy, abi.BytesTy, abi.FixedBytesTy:
+			if input.Type.IsSlice && input.Type.T != abi.BytesTy || input.Type.IsArray && input.Type.T != abi.FixedBytesTy {
+				return fmt.Errorf("unsupported array argument %s", input.Name)
+			}
+		default:
+			return fmt.Errorf("unsupported argument type of %s", input.Name)
+		}
+	}
+	return nil
+}
+
+// args returns random arguments for the method
+func (in *inputs) args(method abi.Method) []interface{} {
+	args := make([]interface{}, len(method.Inputs))
+	for i, input := range method.Inputs {
+		args[i] = in.arg(input.Type)
+	}
+	return args
+}
+
+func (in *inputs) arg(t abi.Type) interface{} {
+	switch t.T {
+	case abi.IntTy, abi.UintTy:
+		amount := in.amounts[in.rand.Intn(len(in.amounts))]
+		if typ, ok := intTypes[t.Kind]; ok {
+			// Fixed size integers wrap around, so the maximum amount becomes the maximum of the type
+			if t.T == abi.IntTy {
+				return reflect.ValueOf(int64(amount.Uint64())).Convert(typ).Interface()
+			}
+			return reflect.ValueOf(amount.Uint64()).Convert(typ).Interface()
+		}
+		if t.T == abi.IntTy && amount.BitLen() == 256 {
+			return big.NewInt(-1)
+		}
+		return new(big.Int).Set(amount)
+
+	case abi.BoolTy:
+		return in.rand.Intn(2) == 1
+
+	case abi.AddressTy:
+		// The attacker comes first and is drawn half of the time, as it is the account whose callbacks matter
+		if in.rand.Intn(2) == 0 {
+			return in.addresses[0]
+		}
+		return in.addresses[in.rand.Intn(len(in.addresses))]
+
+	case abi.StringTy:
+		return string(in.bytes(32))
+
+	case abi.BytesTy:
+		return in.bytes(64)
+
+	case abi.FixedBytesTy:
+		array := reflect.New(reflect.ArrayOf(t.SliceSize, reflect.TypeOf(byte(0)))).Elem()
+		reflect.Copy(array, reflect.ValueOf(in.bytes(t.SliceSize)))
+		return array.Interface()
+	}
+	panic(fmt.Sprintf("ecffuzz: unsupported type %v", t))
+}
+
+// bytes returns either no bytes, or up to max random bytes
+func (in *inputs) bytes(max int) []byte {
+	if in.rand.Intn(2) == 0 {
+		return []byte{}
+	}
+	data := make([]byte, 1+in.rand.Intn(max))
+	in.rand.Read(data)
+	return data
+}
+
+// value returns the value to send along a call of the method
+func (in *inputs) value(max *big.Int) *big.Int {
+	switch in.rand.Intn(3) {
+	case 0:
+		return big.NewInt(0)
+	case 1:
+		return new(big.Int).Div(max, big.NewInt(2))
+	}
+	return new(big.Int).Set(max)
+}
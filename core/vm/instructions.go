@@ -652,6 +652,9 @@ func makeLog(size int) executionFunc {
 			// core/state doesn't know the current block number.
 			BlockNumber: env.BlockNumber.Uint64(),
 		})
+
+		TheChecker().UponLog(env, contract, topics)
+
 		return nil, nil
 	}
 }
@@ -40,6 +40,8 @@ func (d *dummyContractRef) AddBalance(amount *big.Int) {}
 func (d *dummyContractRef) SetBalance(*big.Int)        {}
 func (d *dummyContractRef) SetNonce(uint64)            {}
 func (d *dummyContractRef) Balance() *big.Int          { return new(big.Int) }
+func (d *dummyContractRef) GetterGas() *big.Int        { return new(big.Int) }
+func (d *dummyContractRef) GetterUsedGas() *big.Int    { return new(big.Int) }
 
 type dummyStateDB struct {
 	NoopStateDB
@@ -21,7 +21,9 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
@@ -175,3 +177,44 @@ func Call(address common.Address, input []byte, cfg *Config) ([]byte, error) {
 
 	return ret, err
 }
+
+// Replay applies a signed transaction to the state given in the config, e.g. one imported from a dump with
+// state.NewFromDump, in the block described by the config's chain config, block number, time, coinbase, difficulty
+// and gas limit. The sender is recovered from the signature, and the checker labels the run as a trace unless the
+// EVM config gives another execution context.
+//
+// It returns the EVM's return value and the gas used. An error means the transaction could not be applied, e.g.
+// because of a bad nonce or signature, not that its execution failed.
+func Replay(tx *types.Transaction, cfg *Config) ([]byte, *big.Int, error) {
+	setDefaults(cfg)
+
+	msg, err := tx.AsMessage(types.MakeSigner(cfg.ChainConfig, cfg.BlockNumber))
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.EVMConfig.ECFContext == vm.ExecUnknown {
+		cfg.EVMConfig.ECFContext = vm.ExecTrace
+	}
+	if cfg.EVMConfig.ECFResult == nil {
+		cfg.EVMConfig.ECFResult = new(vm.ECFResultSlot)
+	}
+	cfg.EVMConfig.ECFResult.Result = nil
+	cfg.Origin = msg.From()
+	vmenv := NewEnv(cfg, cfg.State)
+	vmenv.GasPrice = msg.GasPrice()
+
+	checker := vm.TheChecker()
+	cfg.State.StartRecord(tx.Hash(), common.Hash{}, 0)
+	checker.SetTransactionContext(tx.Hash(), common.Hash{})
+	ret, gas, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(cfg.GasLimit))
+	checker.SetTransactionContext(common.Hash{}, common.Hash{})
+	if err != nil {
+		return nil, nil, err
+	}
+	if result := cfg.EVMConfig.ECFResult.Result; result != nil {
+		checker.RecordTransactionResult(tx.Hash(), result)
+	} else if checker.Enabled() {
+		checker.RecordTransactionResult(tx.Hash(), nil)
+	}
+	return ret, gas, nil
+}
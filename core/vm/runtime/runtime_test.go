@@ -24,7 +24,9 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 )
 
@@ -93,6 +95,45 @@ func TestExecute(t *testing.T) {
 	}
 }
 
+func TestECFResultSlot(t *testing.T) {
+	code := []byte{byte(vm.PUSH1), 1, byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP)}
+
+	first, second := new(vm.ECFResultSlot), new(vm.ECFResultSlot)
+	trace := new(vm.ECFTraceSlot)
+	if _, _, err := Execute(code, nil, &Config{EVMConfig: vm.Config{ECFResult: first, ECFTrace: trace}}); err != nil {
+		t.Fatal(err)
+	}
+	if first.Result == nil || !first.Result.IsECF() || first.Result.Segments != 1 {
+		t.Fatalf("expected an ECF result of 1 segment, have %+v", first.Result)
+	}
+	if trace.Trace == nil || len(trace.Trace.Segments) != 1 || len(trace.Trace.Segments[0].Writes) != 1 {
+		t.Fatalf("expected a trace of 1 segment writing once, have %+v", trace.Trace)
+	}
+	result, segments := first.Result, trace.Trace
+	if _, _, err := Execute(code, nil, &Config{EVMConfig: vm.Config{ECFResult: second}}); err != nil {
+		t.Fatal(err)
+	}
+	if first.Result != result || second.Result == nil || second.Result == result {
+		t.Errorf("expected each execution to fill its own slot, have %p and %p", first.Result, second.Result)
+	}
+	if trace.Trace != segments {
+		t.Errorf("expected the trace of the first execution to be kept, have %+v", trace.Trace)
+	}
+}
+
+func TestECFSkip(t *testing.T) {
+	code := []byte{byte(vm.PUSH1), 1, byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP)}
+
+	last := vm.TheChecker().LastResult()
+	slot := new(vm.ECFResultSlot)
+	if _, _, err := Execute(code, nil, &Config{EVMConfig: vm.Config{ECFResult: slot, ECFSkip: true}}); err != nil {
+		t.Fatal(err)
+	}
+	if slot.Result != nil || vm.TheChecker().LastResult() != last {
+		t.Errorf("expected a skipped execution not to be checked, have %+v", slot.Result)
+	}
+}
+
 func TestCall(t *testing.T) {
 	db, _ := ethdb.NewMemDatabase()
 	state, _ := state.New(common.Hash{}, db)
@@ -149,3 +190,42 @@ func BenchmarkCall(b *testing.B) {
 		}
 	}
 }
+
+func TestReplay(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	a, b := common.HexToAddress("aa"), common.HexToAddress("bb")
+
+	// A reads slot 0, calls B, which calls A back, and writes 5 to slot 0. Re-entered, A writes 7 to slot 0.
+	dump := state.Dump{Accounts: map[string]state.DumpAccount{
+		common.Bytes2Hex(a.Bytes()):      {Balance: "0", Code: "337300000000000000000000000000000000000000bb1460495760005450600060006000600060007300000000000000000000000000000000000000bb620186a0f1506005600055005b60005450600760005500"},
+		common.Bytes2Hex(b.Bytes()):      {Balance: "0", Code: "600060006000600060007300000000000000000000000000000000000000aa6200c350f15000"},
+		common.Bytes2Hex(sender.Bytes()): {Balance: "1000000000000000000", Nonce: 3},
+	}}
+	statedb, err := state.NewFromDump(dump, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{State: statedb, BlockNumber: big.NewInt(1)}
+	setDefaults(cfg)
+	tx, _ := types.SignTx(types.NewTransaction(3, a, new(big.Int), big.NewInt(400000), big.NewInt(1), nil), types.MakeSigner(cfg.ChainConfig, cfg.BlockNumber), key)
+
+	if _, _, err := Replay(tx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if value := statedb.GetState(a, common.Hash{}); value != common.BigToHash(big.NewInt(5)) {
+		t.Errorf("slot 0 of A: have %x, want 5", value)
+	}
+	if statedb.GetNonce(sender) != 4 {
+		t.Errorf("sender nonce: have %d, want 4", statedb.GetNonce(sender))
+	}
+	result, _ := vm.TheChecker().TransactionResult(tx.Hash())
+	if result == nil || result.IsECF() || result.Violations[0].Contract != a {
+		t.Fatalf("expected a violation on A, have %+v", result)
+	}
+
+	// The nonce is now too high for the transaction
+	if _, _, err := Replay(tx, cfg); err == nil {
+		t.Errorf("expected an error replaying the transaction twice")
+	}
+}
@@ -46,6 +46,17 @@ type Config struct {
 	DisableGasMetering bool
 	// Enable recording of SHA3/keccak preimages
 	EnablePreimageRecording bool
+	// ECFMode overrides the ECF checker's mode for transactions run by this interpreter
+	ECFMode ECFCheckMode
+	// ECFContext tells the ECF checker what the transactions run by this interpreter are run for
+	ECFContext ExecutionContext
+	// ECFResult, if set, receives the ECF checker's result on the transaction run by this interpreter
+	ECFResult *ECFResultSlot
+	// ECFTrace, if set, receives the segment trace of the transaction run by this interpreter
+	ECFTrace *ECFTraceSlot
+	// ECFSkip runs the transactions of this interpreter unchecked, e.g. those replayed only to rebuild the state
+	// before a traced transaction, so that they are neither stored nor counted
+	ECFSkip bool
 	// JumpTable contains the EVM instruction table. This
 	// may me left uninitialised and will be set the default
 	// table.
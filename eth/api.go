@@ -413,8 +413,9 @@ func (api *PrivateDebugAPI) traceBlock(block *types.Block, logConfig *vm.LogConf
 	structLogger := vm.NewStructLogger(logConfig)
 
 	config := vm.Config{
-		Debug:  true,
-		Tracer: structLogger,
+		Debug:      true,
+		Tracer:     structLogger,
+		ECFContext: vm.ExecTrace,
 	}
 
 	if err := core.ValidateHeader(api.config, blockchain.AuxValidator(), block.Header(), blockchain.GetHeader(block.ParentHash(), block.NumberU64()-1), true, false); err != nil {
@@ -425,7 +426,7 @@ func (api *PrivateDebugAPI) traceBlock(block *types.Block, logConfig *vm.LogConf
 		return false, structLogger.StructLogs(), err
 	}
 
-	receipts, _, usedGas, err := processor.Process(block, statedb, config)
+	receipts, _, usedGas, _, err := processor.Process(block, statedb, config)
 	if err != nil {
 		return false, structLogger.StructLogs(), err
 	}
@@ -501,64 +502,162 @@ func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, txHash common.
 		tracer = vm.NewStructLogger(config.LogConfig)
 	}
 
+	// Retrieve the tx from the chain and mutate the state up to it
+	tx, _, _, _ := core.GetTransaction(api.eth.ChainDb(), txHash)
+	if tx == nil {
+		return nil, fmt.Errorf("transaction %x not found", txHash)
+	}
+	msg, context, stateDb, err := api.computeTxEnv(txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	vmenv := vm.NewEVM(context, stateDb, api.config, vm.Config{Debug: true, Tracer: tracer, ECFContext: vm.ExecTrace})
+	ret, gas, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(tx.Gas()))
+	if err != nil {
+		return nil, fmt.Errorf("tracing failed: %v", err)
+	}
+
+	switch tracer := tracer.(type) {
+	case *vm.StructLogger:
+		return &ethapi.ExecutionResult{
+			Gas:         gas,
+			ReturnValue: fmt.Sprintf("%x", ret),
+			StructLogs:  ethapi.FormatLogs(tracer.StructLogs()),
+		}, nil
+	case *ethapi.JavascriptTracer:
+		return tracer.GetResult()
+	default:
+		return nil, fmt.Errorf("bad tracer type %T", tracer)
+	}
+}
+
+// CheckTransactionECF replays the given transaction on top of the state it was
+// originally executed in and returns the ECF checker's verdict on it. The mode
+// may be heuristic, exact or compare, and defaults to the checker's own mode.
+func (api *PrivateDebugAPI) CheckTransactionECF(ctx context.Context, txHash common.Hash, mode *string) (*vm.ECFResult, error) {
+	return api.checkTransactionECF(ctx, txHash, mode, nil)
+}
+
+// checkTransactionECF is CheckTransactionECF also filling the given trace slot,
+// if any, with the segment trace of the replay.
+func (api *PrivateDebugAPI) checkTransactionECF(ctx context.Context, txHash common.Hash, mode *string, trace *vm.ECFTraceSlot) (*vm.ECFResult, error) {
+	ecfMode := vm.ECFModeDefault
+	if mode != nil {
+		var err error
+		if ecfMode, err = vm.ParseECFCheckMode(*mode); err != nil {
+			return nil, err
+		}
+	}
+	tx, _, _, _ := core.GetTransaction(api.eth.ChainDb(), txHash)
+	if tx == nil {
+		return nil, fmt.Errorf("transaction %x not found", txHash)
+	}
+	msg, context, stateDb, err := api.computeTxEnv(txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	slot := new(vm.ECFResultSlot)
+	vmenv := vm.NewEVM(context, stateDb, api.config, vm.Config{ECFMode: ecfMode, ECFContext: vm.ExecTrace, ECFResult: slot, ECFTrace: trace})
+	if _, _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(tx.Gas())); err != nil {
+		return nil, fmt.Errorf("replay failed: %v", err)
+	}
+	if slot.Result != nil {
+		return slot.Result, nil
+	}
+	return nil, fmt.Errorf("transaction %x ran no code", txHash)
+}
+
+// DotTransactionECF replays the given transaction like CheckTransactionECF and
+// renders its segments as a Graphviz DOT graph, with the segments and storage
+// locations of each violation highlighted.
+func (api *PrivateDebugAPI) DotTransactionECF(ctx context.Context, txHash common.Hash, mode *string) (string, error) {
+	slot := new(vm.ECFTraceSlot)
+	result, err := api.checkTransactionECF(ctx, txHash, mode, slot)
+	if err != nil {
+		return "", err
+	}
+	if slot.Trace == nil {
+		return "", fmt.Errorf("transaction %x ran no code", txHash)
+	}
+	slot.Trace.Transaction = txHash
+	return slot.Trace.DOT(result), nil
+}
+
+// PublicECFAPI offers ECF checks of the transactions of the local chain.
+type PublicECFAPI struct {
+	debug *PrivateDebugAPI
+}
+
+// NewPublicECFAPI creates a new ECF API instance.
+func NewPublicECFAPI(config *params.ChainConfig, eth *Ethereum) *PublicECFAPI {
+	return &PublicECFAPI{debug: NewPrivateDebugAPI(config, eth)}
+}
+
+// CheckTransaction replays the given transaction and returns the checker's
+// verdict on it, as debug_checkTransactionECF does.
+func (api *PublicECFAPI) CheckTransaction(ctx context.Context, txHash common.Hash, mode *string) (*vm.ECFResult, error) {
+	return api.debug.CheckTransactionECF(ctx, txHash, mode)
+}
+
+// TraceTransaction replays the given transaction and returns its full segment
+// trace, to be archived and checked again with ecf_checkTrace.
+func (api *PublicECFAPI) TraceTransaction(ctx context.Context, txHash common.Hash) (*vm.SegmentTrace, error) {
+	slot := new(vm.ECFTraceSlot)
+	if _, err := api.debug.checkTransactionECF(ctx, txHash, nil, slot); err != nil {
+		return nil, err
+	}
+	if slot.Trace == nil {
+		return nil, fmt.Errorf("transaction %x ran no code", txHash)
+	}
+	slot.Trace.Transaction = txHash
+	return slot.Trace, nil
+}
+
+// computeTxEnv returns the execution environment of the given transaction: its
+// call message, EVM context and the state of its block right before it ran.
+func (api *PrivateDebugAPI) computeTxEnv(txHash common.Hash) (core.Message, vm.Context, *state.StateDB, error) {
 	// Retrieve the tx from the chain and the containing block
 	tx, blockHash, _, txIndex := core.GetTransaction(api.eth.ChainDb(), txHash)
 	if tx == nil {
-		return nil, fmt.Errorf("transaction %x not found", txHash)
+		return nil, vm.Context{}, nil, fmt.Errorf("transaction %x not found", txHash)
 	}
 	block := api.eth.BlockChain().GetBlockByHash(blockHash)
 	if block == nil {
-		return nil, fmt.Errorf("block %x not found", blockHash)
+		return nil, vm.Context{}, nil, fmt.Errorf("block %x not found", blockHash)
 	}
 	// Create the state database to mutate and eventually trace
 	parent := api.eth.BlockChain().GetBlock(block.ParentHash(), block.NumberU64()-1)
 	if parent == nil {
-		return nil, fmt.Errorf("block parent %x not found", block.ParentHash())
+		return nil, vm.Context{}, nil, fmt.Errorf("block parent %x not found", block.ParentHash())
 	}
 	stateDb, err := api.eth.BlockChain().StateAt(parent.Root())
 	if err != nil {
-		return nil, err
+		return nil, vm.Context{}, nil, err
 	}
 
 	signer := types.MakeSigner(api.config, block.Number())
-	// Mutate the state and trace the selected transaction
+	// Mutate the state until the selected transaction is reached
 	for idx, tx := range block.Transactions() {
 		// Assemble the transaction call message
 		msg, err := tx.AsMessage(signer)
 		if err != nil {
-			return nil, fmt.Errorf("sender retrieval failed: %v", err)
+			return nil, vm.Context{}, nil, fmt.Errorf("sender retrieval failed: %v", err)
 		}
 		context := core.NewEVMContext(msg, block.Header(), api.eth.BlockChain())
-
-		// Mutate the state if we haven't reached the tracing transaction yet
-		if uint64(idx) < txIndex {
-			vmenv := vm.NewEVM(context, stateDb, api.config, vm.Config{})
-			_, _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(tx.Gas()))
-			if err != nil {
-				return nil, fmt.Errorf("mutation failed: %v", err)
-			}
-			stateDb.DeleteSuicides()
-			continue
+		if uint64(idx) == txIndex {
+			return msg, context, stateDb, nil
 		}
 
-		vmenv := vm.NewEVM(context, stateDb, api.config, vm.Config{Debug: true, Tracer: tracer})
-		ret, gas, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(tx.Gas()))
+		vmenv := vm.NewEVM(context, stateDb, api.config, vm.Config{ECFSkip: true})
+		_, _, err = core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(tx.Gas()))
 		if err != nil {
-			return nil, fmt.Errorf("tracing failed: %v", err)
-		}
-
-		switch tracer := tracer.(type) {
-		case *vm.StructLogger:
-			return &ethapi.ExecutionResult{
-				Gas:         gas,
-				ReturnValue: fmt.Sprintf("%x", ret),
-				StructLogs:  ethapi.FormatLogs(tracer.StructLogs()),
-			}, nil
-		case *ethapi.JavascriptTracer:
-			return tracer.GetResult()
+			return nil, vm.Context{}, nil, fmt.Errorf("mutation failed: %v", err)
 		}
+		stateDb.DeleteSuicides()
 	}
-	return nil, errors.New("database inconsistency")
+	return nil, vm.Context{}, nil, errors.New("database inconsistency")
 }
 
 // Preimage is a debug API function that returns the preimage for a sha3 hash, if known.
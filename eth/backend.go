@@ -218,6 +218,7 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 
 	glog.V(logger.Info).Infoln("Chain config:", eth.chainConfig)
 
+	vm.TheChecker().SetCodeTraitsDatabase(chainDb)
 	eth.blockchain, err = core.NewBlockChain(chainDb, eth.chainConfig, eth.pow, eth.EventMux(), vm.Config{EnablePreimageRecording: config.EnablePreimageRecording})
 	if err != nil {
 		if err == core.ErrNoGenesis {
@@ -225,6 +226,9 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		}
 		return nil, err
 	}
+	if err := eth.blockchain.RecheckECF(); err != nil {
+		glog.V(logger.Error).Infoln("Failed to check again the blocks imported since the last ECF checkpoint:", err)
+	}
 	newPool := core.NewTxPool(eth.chainConfig, eth.EventMux(), eth.blockchain.State, eth.blockchain.GasLimit)
 	eth.txPool = newPool
 
@@ -351,6 +355,11 @@ func (s *Ethereum) APIs() []rpc.API {
 			Namespace: "debug",
 			Version:   "1.0",
 			Service:   NewPrivateDebugAPI(s.chainConfig, s),
+		}, {
+			Namespace: "ecf",
+			Version:   "1.0",
+			Service:   NewPublicECFAPI(s.chainConfig, s),
+			Public:    true,
 		}, {
 			Namespace: "net",
 			Version:   "1.0",
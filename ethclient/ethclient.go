@@ -26,6 +26,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
 	"golang.org/x/net/context"
@@ -385,6 +386,20 @@ func (ec *Client) PendingCallContract(ctx context.Context, msg ethereum.CallMsg)
 	return hex, nil
 }
 
+// PendingCheckECF executes a message call transaction against the pending state
+// and returns the verdict of the node's ECF checker on it. The result is nil if
+// the call ran no code. The node must serve the ecf API.
+func (ec *Client) PendingCheckECF(ctx context.Context, msg ethereum.CallMsg) (*vm.ECFResult, error) {
+	var checked struct {
+		Result *vm.ECFResult `json:"result"`
+	}
+	err := ec.c.CallContext(ctx, &checked, "ecf_checkCall", toCallArg(msg), "pending")
+	if err != nil {
+		return nil, err
+	}
+	return checked.Result, nil
+}
+
 // SuggestGasPrice retrieves the currently suggested gas price to allow a timely
 // execution of a transaction.
 func (ec *Client) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
@@ -31,6 +31,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/eth"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/les"
@@ -122,6 +123,10 @@ func (s *Service) loop() {
 	txSub := emux.Subscribe(core.TxPreEvent{})
 	defer txSub.Unsubscribe()
 
+	// Every canonical block, not only the new heads, may carry ECF violations to push
+	chainSub := emux.Subscribe(core.ChainEvent{})
+	defer chainSub.Unsubscribe()
+
 	// Loop reporting until termination
 	for {
 		// Establish a websocket connection to the server and authenticate the node
@@ -181,6 +186,14 @@ func (s *Service) loop() {
 				if err = s.reportPending(out); err != nil {
 					glog.V(logger.Warn).Infof("Post-block transaction stats report failed: %v", err)
 				}
+			case ev, ok := <-chainSub.Chan():
+				if !ok { // node stopped
+					conn.Close()
+					return
+				}
+				if err = s.reportViolations(out, ev.Data.(core.ChainEvent).Block); err != nil {
+					glog.V(logger.Warn).Infof("ECF violations report failed: %v", err)
+				}
 			case _, ok := <-txSub.Chan():
 				if !ok { // node stopped
 					conn.Close()
@@ -403,6 +416,13 @@ type blockStats struct {
 	TotalDiff string         `json:"totalDifficulty"`
 	Txs       txStats        `json:"transactions"`
 	Uncles    uncleStats     `json:"uncles"`
+	ECF       *ecfStats      `json:"ecf,omitempty"` // Full nodes only
+}
+
+// ecfStats is the ECF checker's summary of the transactions of a block.
+type ecfStats struct {
+	Checked    int `json:"checked"`    // Transactions that ran code and were checked
+	Violations int `json:"violations"` // Violations found in them
 }
 
 // txStats is a custom wrapper around a transaction array to force serializing
@@ -449,6 +469,7 @@ func (s *Service) assembleBlockStats(block *types.Block) *blockStats {
 		td     *big.Int
 		txs    []*types.Transaction
 		uncles []*types.Header
+		ecf    *ecfStats
 	)
 	if s.eth != nil {
 		// Full nodes have all needed information available
@@ -460,6 +481,7 @@ func (s *Service) assembleBlockStats(block *types.Block) *blockStats {
 
 		txs = block.Transactions()
 		uncles = block.Uncles()
+		ecf = assembleECFStats(txs)
 	} else {
 		// Light nodes would need on-demand lookups for transactions/uncles, skip
 		if block != nil {
@@ -481,7 +503,58 @@ func (s *Service) assembleBlockStats(block *types.Block) *blockStats {
 		TotalDiff: td.String(),
 		Txs:       txs,
 		Uncles:    uncles,
+		ECF:       ecf,
+	}
+}
+
+// assembleECFStats sums up the checker's results for the given transactions.
+// Results are only kept for recent blocks, so older ones report nothing checked.
+func assembleECFStats(txs []*types.Transaction) *ecfStats {
+	stats := new(ecfStats)
+	for _, tx := range txs {
+		if result, _ := vm.TheChecker().TransactionResult(tx.Hash()); result != nil {
+			stats.Checked++
+			stats.Violations += len(result.Violations)
+		}
 	}
+	return stats
+}
+
+// violationStats is the information to report about a single ECF violation.
+type violationStats struct {
+	Block     *big.Int    `json:"block"`
+	BlockHash common.Hash `json:"blockHash"`
+	Tx        common.Hash `json:"tx"`
+	vm.ECFViolation
+}
+
+// reportViolations pushes each ECF violation found in the transactions of the
+// given block to the stats server as a message of its own.
+func (s *Service) reportViolations(out *json.Encoder, block *types.Block) error {
+	for _, tx := range block.Transactions() {
+		result, _ := vm.TheChecker().TransactionResult(tx.Hash())
+		if result == nil {
+			continue
+		}
+		for _, violation := range result.Violations {
+			stats := map[string]interface{}{
+				"id": s.node,
+				"violation": &violationStats{
+					Block:        block.Number(),
+					BlockHash:    block.Hash(),
+					Tx:           tx.Hash(),
+					ECFViolation: violation,
+				},
+			}
+			report := map[string][]interface{}{
+				"emit": {"ecf-violation", stats},
+			}
+			if err := out.Encode(report); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 // reportHistory retrieves the most recent batch of blocks and reports it to the
@@ -565,6 +638,8 @@ type nodeStats struct {
 	Peers    int  `json:"peers"`
 	GasPrice int  `json:"gasPrice"`
 	Uptime   int  `json:"uptime"`
+
+	ECF vm.ECFStats `json:"ecf"`
 }
 
 // reportPending retrieves various stats about the node at the networking and
@@ -599,6 +674,7 @@ func (s *Service) reportStats(out *json.Encoder) error {
 			GasPrice: gasprice,
 			Syncing:  syncing,
 			Uptime:   100,
+			ECF:      vm.TheChecker().Stats(),
 		},
 	}
 	report := map[string][]interface{}{
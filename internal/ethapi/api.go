@@ -575,16 +575,24 @@ type CallArgs struct {
 }
 
 func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (string, *big.Int, error) {
+	return doCall(ctx, s.b, args, blockNr, vm.Config{})
+}
+
+// doCall executes the call on the state of the given block, in an EVM configured by vmCfg.
+func doCall(ctx context.Context, b Backend, args CallArgs, blockNr rpc.BlockNumber, vmCfg vm.Config) (string, *big.Int, error) {
+	if vmCfg.ECFContext == vm.ExecUnknown {
+		vmCfg.ECFContext = vm.ExecCall
+	}
 	defer func(start time.Time) { glog.V(logger.Debug).Infof("call took %v", time.Since(start)) }(time.Now())
 
-	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	state, header, err := b.StateAndHeaderByNumber(ctx, blockNr)
 	if state == nil || err != nil {
 		return "0x", common.Big0, err
 	}
 	// Set sender address or use a default if none specified
 	addr := args.From
 	if addr == (common.Address{}) {
-		if wallets := s.b.AccountManager().Wallets(); len(wallets) > 0 {
+		if wallets := b.AccountManager().Wallets(); len(wallets) > 0 {
 			if accounts := wallets[0].Accounts(); len(accounts) > 0 {
 				addr = accounts[0].Address
 			}
@@ -603,7 +611,7 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	msg := types.NewMessage(addr, args.To, 0, args.Value.ToInt(), gas, gasPrice, args.Data, false)
 
 	// Execute the call and return
-	vmenv, vmError, err := s.b.GetVMEnv(ctx, msg, state, header)
+	vmenv, vmError, err := b.GetVMEnv(ctx, msg, state, header, vmCfg)
 	if err != nil {
 		return "0x", common.Big0, err
 	}
@@ -742,8 +750,17 @@ func (s *PublicBlockChainAPI) rpcOutputBlock(b *types.Block, inclTx bool, fullTx
 		}
 
 		if fullTx {
+			// Add the ECF verdicts if the block was indexed
+			verdicts := core.GetBlockECFVerdicts(s.b.ChainDb(), b.Hash(), b.NumberU64())
+			if len(verdicts) != len(b.Transactions()) {
+				verdicts = nil
+			}
 			formatTx = func(tx *types.Transaction) (interface{}, error) {
-				return newRPCTransaction(b, tx.Hash())
+				rpcTx, err := newRPCTransaction(b, tx.Hash())
+				if rpcTx != nil && verdicts != nil {
+					rpcTx.ECF = verdicts[rpcTx.TransactionIndex]
+				}
+				return rpcTx, err
 			}
 		}
 
@@ -770,20 +787,21 @@ func (s *PublicBlockChainAPI) rpcOutputBlock(b *types.Block, inclTx bool, fullTx
 
 // RPCTransaction represents a transaction that will serialize to the RPC representation of a transaction
 type RPCTransaction struct {
-	BlockHash        common.Hash     `json:"blockHash"`
-	BlockNumber      *hexutil.Big    `json:"blockNumber"`
-	From             common.Address  `json:"from"`
-	Gas              *hexutil.Big    `json:"gas"`
-	GasPrice         *hexutil.Big    `json:"gasPrice"`
-	Hash             common.Hash     `json:"hash"`
-	Input            hexutil.Bytes   `json:"input"`
-	Nonce            hexutil.Uint64  `json:"nonce"`
-	To               *common.Address `json:"to"`
-	TransactionIndex hexutil.Uint    `json:"transactionIndex"`
-	Value            *hexutil.Big    `json:"value"`
-	V                *hexutil.Big    `json:"v"`
-	R                *hexutil.Big    `json:"r"`
-	S                *hexutil.Big    `json:"s"`
+	BlockHash        common.Hash      `json:"blockHash"`
+	BlockNumber      *hexutil.Big     `json:"blockNumber"`
+	From             common.Address   `json:"from"`
+	Gas              *hexutil.Big     `json:"gas"`
+	GasPrice         *hexutil.Big     `json:"gasPrice"`
+	Hash             common.Hash      `json:"hash"`
+	Input            hexutil.Bytes    `json:"input"`
+	Nonce            hexutil.Uint64   `json:"nonce"`
+	To               *common.Address  `json:"to"`
+	TransactionIndex hexutil.Uint     `json:"transactionIndex"`
+	Value            *hexutil.Big     `json:"value"`
+	V                *hexutil.Big     `json:"v"`
+	R                *hexutil.Big     `json:"r"`
+	S                *hexutil.Big     `json:"s"`
+	ECF              *core.ECFVerdict `json:"ecf,omitempty"` // The checker's verdict, if the block was indexed
 }
 
 // newRPCPendingTransaction returns a pending transaction that will serialize to the RPC representation
@@ -1066,6 +1084,10 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(txHash common.Hash) (ma
 	if receipt.ContractAddress != (common.Address{}) {
 		fields["contractAddress"] = receipt.ContractAddress
 	}
+	// The checker's verdict, if the transaction was indexed
+	if verdict := core.GetECFVerdict(s.b.ChainDb(), txHash); verdict != nil {
+		fields["ecf"] = verdict
+	}
 	return fields, nil
 }
 
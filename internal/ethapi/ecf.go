@@ -0,0 +1,90 @@
+// Shelly
+
+package ethapi
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"golang.org/x/net/context"
+)
+
+// ecfCallLock serializes the checked calls, as the checker follows a single execution at a time
+var ecfCallLock sync.Mutex
+
+// PublicECFAPI checks simulated calls for ECF. It is served by full and light
+// nodes alike, so wallets can pre-check a transaction without a full node.
+type PublicECFAPI struct {
+	b Backend
+}
+
+// NewPublicECFAPI creates a new ECF call checking API.
+func NewPublicECFAPI(b Backend) *PublicECFAPI {
+	return &PublicECFAPI{b}
+}
+
+// ECFCallResult is the outcome of a checked call.
+type ECFCallResult struct {
+	ReturnValue string        `json:"returnValue"`
+	Gas         *hexutil.Big  `json:"gas"`
+	Result      *vm.ECFResult `json:"result"` // nil if the call ran no code
+	Check       *vm.CallCheck `json:"check"`  // The stored verdict, nil if the call ran no code
+}
+
+// CheckCall executes the given call on the state of the given block, as
+// eth_call does, and checks it for ECF using the given mode (the checker's
+// mode if omitted). The verdict is stored in the node's ECF database.
+func (api *PublicECFAPI) CheckCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, mode *string) (*ECFCallResult, error) {
+	var ecfMode vm.ECFCheckMode
+	if mode != nil {
+		var err error
+		if ecfMode, err = vm.ParseECFCheckMode(*mode); err != nil {
+			return nil, err
+		}
+	}
+	header, err := api.b.HeaderByNumber(ctx, blockNr)
+	if header == nil || err != nil {
+		return nil, fmt.Errorf("block %v not found: %v", blockNr, err)
+	}
+
+	ecfCallLock.Lock()
+	defer ecfCallLock.Unlock()
+
+	// On a light node, a failure to retrieve the state makes doCall fail, so no verdict is taken on partial state
+	slot := new(vm.ECFResultSlot)
+	ret, gas, err := doCall(ctx, api.b, args, blockNr, vm.Config{ECFMode: ecfMode, ECFResult: slot})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ECFCallResult{ReturnValue: ret, Gas: (*hexutil.Big)(gas)}
+	if last := slot.Result; last != nil {
+		result.Result = last
+		result.Check = &vm.CallCheck{
+			Block:      header.Number.Uint64(),
+			From:       args.From,
+			To:         args.To,
+			InputHash:  crypto.Keccak256Hash(args.Data),
+			Mode:       last.Mode.String(),
+			ECF:        last.IsECF(),
+			Violations: len(last.Violations),
+		}
+		if err := vm.TheChecker().StoreCallCheck(result.Check); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// RecentCallChecks returns the last n stored verdicts of checked calls, the
+// most recent first.
+func (api *PublicECFAPI) RecentCallChecks(n int) ([]*vm.CallCheck, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid number of checks %d", n)
+	}
+	return vm.TheChecker().RecentCallChecks(n)
+}
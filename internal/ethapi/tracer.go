@@ -178,6 +178,11 @@ type JavascriptTracer struct {
 	db         *dbWrapper             // Wrapper around the VM environment
 	dbvalue    otto.Value             // JS view of `db`
 	err        error                  // Error, if one has occurred
+
+	// SHELLY - segment events, sent to the optional 'enterSegment' and 'exitSegment' functions
+	enterSegment bool       // Whether the tracer defines 'enterSegment'
+	exitSegment  bool       // Whether the tracer defines 'exitSegment'
+	ecfvalue     otto.Value // JS view of the checker's verdict, passed to 'result'
 }
 
 // NewJavascriptTracer instantiates a new JavascriptTracer instance.
@@ -212,6 +217,16 @@ func NewJavascriptTracer(code string) (*JavascriptTracer, error) {
 		return nil, fmt.Errorf("Trace object must expose a function result()")
 	}
 
+	// SHELLY - segment callbacks are optional
+	enterSegment, err := jstracer.Get("enterSegment")
+	if err != nil {
+		return nil, err
+	}
+	exitSegment, err := jstracer.Get("exitSegment")
+	if err != nil {
+		return nil, err
+	}
+
 	// Create the persistent log object
 	log := make(map[string]interface{})
 	logvalue, _ := vm.ToValue(log)
@@ -233,6 +248,10 @@ func NewJavascriptTracer(code string) (*JavascriptTracer, error) {
 		db:         db,
 		dbvalue:    db.toValue(vm),
 		err:        nil,
+
+		enterSegment: enterSegment.IsFunction(),
+		exitSegment:  exitSegment.IsFunction(),
+		ecfvalue:     otto.NullValue(),
 	}, nil
 }
 
@@ -304,13 +323,65 @@ func (jst *JavascriptTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode,
 	return nil
 }
 
-// GetResult calls the Javascript 'result' function and returns its value, or any accumulated error
+// toJSValue converts a value to a plain JS object through its JSON encoding
+func (jst *JavascriptTracer) toJSValue(value interface{}) (otto.Value, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return otto.UndefinedValue(), err
+	}
+	obj, err := jst.vm.Object("(" + string(encoded) + ")")
+	if err != nil {
+		return otto.UndefinedValue(), err
+	}
+	return obj.Value(), nil
+}
+
+// captureSegment calls the given segment function of the tracer with the segment, if it defines it
+func (jst *JavascriptTracer) captureSegment(method string, defined bool, segment vm.TraceSegment) {
+	if jst.err != nil || !defined {
+		return
+	}
+	value, err := jst.toJSValue(segment)
+	if err == nil {
+		_, err = jst.callSafely(method, value)
+	}
+	if err != nil {
+		jst.err = wrapError(method, err)
+	}
+}
+
+// CaptureEnterSegment implements the SegmentTracer interface, calling 'enterSegment' when the checker starts a segment
+func (jst *JavascriptTracer) CaptureEnterSegment(segment vm.TraceSegment) {
+	jst.captureSegment("enterSegment", jst.enterSegment, segment)
+}
+
+// CaptureExitSegment implements the SegmentTracer interface, calling 'exitSegment' with the reads and writes of the
+// segment the checker ends
+func (jst *JavascriptTracer) CaptureExitSegment(segment vm.TraceSegment) {
+	jst.captureSegment("exitSegment", jst.exitSegment, segment)
+}
+
+// CaptureECFResult implements the SegmentTracer interface, keeping the checker's verdict for 'result'
+func (jst *JavascriptTracer) CaptureECFResult(result *vm.ECFResult) {
+	if jst.err != nil {
+		return
+	}
+	value, err := jst.toJSValue(result)
+	if err != nil {
+		jst.err = wrapError("result", err)
+		return
+	}
+	jst.ecfvalue = value
+}
+
+// GetResult calls the Javascript 'result' function and returns its value, or any accumulated error. The function is
+// given the ECF checker's verdict on the transaction, or null if the checker did not check it.
 func (jst *JavascriptTracer) GetResult() (result interface{}, err error) {
 	if jst.err != nil {
 		return nil, jst.err
 	}
 
-	result, err = jst.callSafely("result")
+	result, err = jst.callSafely("result", jst.ecfvalue)
 	if err != nil {
 		err = wrapError("result", err)
 	}
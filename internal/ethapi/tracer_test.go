@@ -24,7 +24,10 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -41,6 +44,8 @@ func (account) Address() common.Address                             { return com
 func (account) ReturnGas(*big.Int)                                  {}
 func (account) SetCode(common.Hash, []byte)                         {}
 func (account) ForEachStorage(cb func(key, value common.Hash) bool) {}
+func (account) GetterGas() *big.Int                                 { return new(big.Int) }
+func (account) GetterUsedGas() *big.Int                             { return new(big.Int) }
 
 func runTrace(tracer *JavascriptTracer) (interface{}, error) {
 	env := vm.NewEVM(vm.Context{}, nil, params.TestChainConfig, vm.Config{Debug: true, Tracer: tracer})
@@ -145,3 +150,42 @@ func TestHaltBetweenSteps(t *testing.T) {
 		t.Errorf("Expected timeout error, got %v", err)
 	}
 }
+
+func TestSegmentEvents(t *testing.T) {
+	tracer, err := NewJavascriptTracer(`{
+		events: [],
+		step: function() {},
+		enterSegment: function(segment) { this.events.push("enter " + segment.contract.slice(-2) + " " + segment.depth + " " + segment.indexInCall); },
+		exitSegment: function(segment) { this.events.push("exit " + segment.contract.slice(-2) + " " + segment.writes.length); },
+		result: function(ecf) { return {events: this.events, segments: ecf.segments, violations: ecf.violations === null ? 0 : ecf.violations.length}; }
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A calls B, then writes a slot
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, db)
+	a, b := common.HexToAddress("0xaa"), common.HexToAddress("0xbb")
+	statedb.SetCode(b, []byte{byte(vm.STOP)})
+	statedb.SetCode(a, []byte{
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0xbb, byte(vm.GAS), byte(vm.CALL), byte(vm.POP),
+		byte(vm.PUSH1), 1, byte(vm.PUSH1), 1, byte(vm.SSTORE), byte(vm.STOP),
+	})
+	if _, err := runtime.Call(a, nil, &runtime.Config{State: statedb, EVMConfig: vm.Config{Debug: true, Tracer: tracer}}); err != nil {
+		t.Fatal(err)
+	}
+
+	ret, err := tracer.GetResult()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"events":     []string{"enter aa 1 0", "exit aa 0", "enter bb 2 0", "exit bb 0", "enter aa 1 1", "exit aa 1"},
+		"segments":   int64(3),
+		"violations": int64(0),
+	}
+	if !reflect.DeepEqual(ret, expected) {
+		t.Errorf("Expected return value to be %#v, got %#v", expected, ret)
+	}
+}
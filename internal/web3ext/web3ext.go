@@ -21,6 +21,7 @@ var Modules = map[string]string{
 	"admin":      Admin_JS,
 	"chequebook": Chequebook_JS,
 	"debug":      Debug_JS,
+	"ecf":        ECF_JS,
 	"eth":        Eth_JS,
 	"miner":      Miner_JS,
 	"net":        Net_JS,
@@ -117,6 +118,11 @@ web3._extend({
 		new web3._extend.Method({
 			name: 'stopWS',
 			call: 'admin_stopWS'
+		}),
+		new web3._extend.Method({
+			name: 'setECFEnabled',
+			call: 'admin_setECFEnabled',
+			params: 1
 		})
 	],
 	properties:
@@ -289,6 +295,18 @@ web3._extend({
 			params: 2,
 			inputFormatter: [null, null]
 		}),
+		new web3._extend.Method({
+			name: 'checkTransactionECF',
+			call: 'debug_checkTransactionECF',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'dotTransactionECF',
+			call: 'debug_dotTransactionECF',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
 		new web3._extend.Method({
 			name: 'preimage',
 			call: 'debug_preimage',
@@ -520,3 +538,83 @@ web3._extend({
 	]
 });
 `
+
+const ECF_JS = `
+web3._extend({
+	property: 'ecf',
+	methods:
+	[
+		new web3._extend.Method({
+			name: 'checkTransaction',
+			call: 'ecf_checkTransaction',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'checkCall',
+			call: 'ecf_checkCall',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputCallFormatter, web3._extend.formatters.inputDefaultBlockNumberFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'violations',
+			call: 'ecf_violations',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'status',
+			call: 'ecf_status',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'setEnabled',
+			call: 'admin_setECFEnabled',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'stats',
+			call: 'ecf_stats',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'contractProfile',
+			call: 'ecf_contractProfile',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'topContracts',
+			call: 'ecf_topContracts',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'recentCallChecks',
+			call: 'ecf_recentCallChecks',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'recentViolations',
+			call: 'ecf_recentViolations',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'inconclusiveChecks',
+			call: 'ecf_inconclusiveChecks',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'traceTransaction',
+			call: 'ecf_traceTransaction',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'checkTrace',
+			call: 'ecf_checkTrace',
+			params: 2,
+			inputFormatter: [null, null]
+		})
+	],
+	properties: []
+});
+`
@@ -70,9 +70,10 @@ type Work struct {
 
 	Block *types.Block // the new block
 
-	header   *types.Header
-	txs      []*types.Transaction
-	receipts []*types.Receipt
+	header     *types.Header
+	txs        []*types.Transaction
+	receipts   []*types.Receipt
+	ecfResults []*vm.ECFResultSlot // The results of checking txs, see core.BlockECFVerdicts
 
 	createdAt time.Time
 }
@@ -311,6 +312,17 @@ func (self *worker) wait() {
 				for _, log := range work.state.Logs() {
 					log.BlockHash = block.Hash()
 				}
+				txHashes := make([]common.Hash, len(block.Transactions()))
+				for i, tx := range block.Transactions() {
+					txHashes[i] = tx.Hash()
+				}
+				vm.TheChecker().AssignBlock(block.NumberU64(), block.Hash(), txHashes, stat == core.CanonStatTy)
+				verdicts := core.BlockECFVerdicts(block, work.ecfResults)
+				if verdicts != nil {
+					if err := core.WriteBlockECFVerdicts(self.chainDb, block.Hash(), block.NumberU64(), verdicts); err != nil {
+						glog.V(logger.Warn).Infoln("error writing block ECF verdicts:", err)
+					}
+				}
 
 				// check if canon block and write transactions
 				if stat == core.CanonStatTy {
@@ -318,6 +330,8 @@ func (self *worker) wait() {
 					core.WriteTransactions(self.chainDb, block)
 					// store the receipts
 					core.WriteReceipts(self.chainDb, work.receipts)
+					// index the ECF verdicts by transaction
+					core.WriteECFVerdicts(self.chainDb, block, verdicts)
 					// Write map map bloom filters
 					core.WriteMipmapBloom(self.chainDb, block.NumberU64(), work.receipts)
 					// implicit by posting ChainHeadEvent
@@ -617,13 +631,15 @@ func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsB
 func (env *Work) commitTransaction(tx *types.Transaction, bc *core.BlockChain, gp *core.GasPool) (error, []*types.Log) {
 	snap := env.state.Snapshot()
 
-	receipt, _, err := core.ApplyTransaction(env.config, bc, gp, env.state, env.header, tx, env.header.GasUsed, vm.Config{})
+	ecfResult := new(vm.ECFResultSlot)
+	receipt, _, err := core.ApplyTransaction(env.config, bc, gp, env.state, env.header, tx, env.header.GasUsed, vm.Config{ECFContext: vm.ExecMine, ECFResult: ecfResult})
 	if err != nil {
 		env.state.RevertToSnapshot(snap)
 		return err, nil
 	}
 	env.txs = append(env.txs, tx)
 	env.receipts = append(env.receipts, receipt)
+	env.ecfResults = append(env.ecfResults, ecfResult)
 
 	return nil, receipt.Logs
 }
@@ -21,7 +21,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/discover"
@@ -171,6 +173,12 @@ func (api *PrivateAdminAPI) StopWS() (bool, error) {
 	return true, nil
 }
 
+// SetECFEnabled turns the ECF checker on or off, starting with the next transaction.
+func (api *PrivateAdminAPI) SetECFEnabled(enabled bool) bool {
+	vm.TheChecker().SetEnabled(enabled)
+	return true
+}
+
 // PublicAdminAPI is the collection of administrative API methods exposed over
 // both secure and unsecure RPC channels.
 type PublicAdminAPI struct {
@@ -333,3 +341,85 @@ func (s *PublicWeb3API) ClientVersion() string {
 func (s *PublicWeb3API) Sha3(input hexutil.Bytes) hexutil.Bytes {
 	return crypto.Keccak256(input)
 }
+
+// PublicECFAPI offers the results of the ECF checker running in the node.
+type PublicECFAPI struct {
+	stack *Node
+}
+
+// NewPublicECFAPI creates a new ECF API instance.
+func NewPublicECFAPI(stack *Node) *PublicECFAPI {
+	return &PublicECFAPI{stack}
+}
+
+// ContractProfile returns the aggregated checker statistics of the given
+// contract, or nil if the checker has not seen it run.
+func (api *PublicECFAPI) ContractProfile(contract common.Address) *vm.ContractProfile {
+	return vm.TheChecker().ContractProfile(contract)
+}
+
+// TopContracts returns the profiles of the n contracts ranking highest by the
+// given metric: transactions, reentered, omittable, reordered or violations.
+func (api *PublicECFAPI) TopContracts(metric string, n int) ([]*vm.ContractProfile, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid number of contracts %d", n)
+	}
+	return vm.TheChecker().TopContracts(metric, n)
+}
+
+// Stats returns the counters of the checker since the node was started.
+func (api *PublicECFAPI) Stats() vm.ECFStats {
+	return vm.TheChecker().Stats()
+}
+
+// Status returns the settings of the checker and its counters.
+func (api *PublicECFAPI) Status() vm.ECFStatus {
+	return vm.TheChecker().Status()
+}
+
+// RecentViolations returns up to n of the last violations found since the node
+// started, the most recent first.
+func (api *PublicECFAPI) RecentViolations(n int) ([]*vm.StoredViolation, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid number of violations %d", n)
+	}
+	return vm.TheChecker().RecentViolations(n), nil
+}
+
+// InconclusiveChecks returns up to n of the last stored transactions the checker
+// gave up on because they exceeded its limits, the most recent first.
+func (api *PublicECFAPI) InconclusiveChecks(n int) ([]*vm.InconclusiveCheck, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid number of checks %d", n)
+	}
+	return vm.TheChecker().InconclusiveChecks(n)
+}
+
+// CheckTrace checks a segment trace, as returned by ecf_traceTransaction, for
+// ECF. The mode may be heuristic, exact or compare, and defaults to the
+// checker's own mode.
+func (api *PublicECFAPI) CheckTrace(trace *vm.SegmentTrace, mode *string) (*vm.ECFResult, error) {
+	if trace == nil {
+		return nil, fmt.Errorf("missing segment trace")
+	}
+	ecfMode := vm.ECFModeDefault
+	if mode != nil {
+		var err error
+		if ecfMode, err = vm.ParseECFCheckMode(*mode); err != nil {
+			return nil, err
+		}
+	}
+	return vm.TheChecker().CheckTrace(trace, ecfMode)
+}
+
+// Violations returns the stored violations in the given block range involving
+// the given contract. Omitted filter fields match every violation.
+func (api *PublicECFAPI) Violations(filter *vm.ViolationFilter) ([]*vm.StoredViolation, error) {
+	if filter == nil {
+		filter = new(vm.ViolationFilter)
+	}
+	if filter.FromBlock != nil && filter.ToBlock != nil && *filter.FromBlock > *filter.ToBlock {
+		return nil, fmt.Errorf("invalid block range %d-%d", *filter.FromBlock, *filter.ToBlock)
+	}
+	return vm.TheChecker().Violations(*filter)
+}
@@ -0,0 +1,168 @@
+// Shelly
+
+package node
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// ECFSchemaVersion is the version of the ECF database schema this node writes. Databases of an older version are
+// migrated on open, databases of a newer version are refused.
+const ECFSchemaVersion = 7
+
+// ecfMigration brings the ECF database from the previous schema version to the next one. Migrations must also apply
+// cleanly to the unversioned databases of older nodes, which may already have some of their changes.
+type ecfMigration struct {
+	description string
+	apply       func(tx *sql.Tx) error
+}
+
+// ecfMigrations are the migrations of the ECF database, in order: migration i brings it to version i+1
+var ecfMigrations = []ecfMigration{
+	{"create the findings tables", func(tx *sql.Tx) error {
+		return execStmts(tx,
+			`create table if not exists LAST_TRANSACTION_ID (txId integer)`,
+			`create table if not exists NON_REENTRANT_TRACE (id integer not null, origin text, block integer, time integer, contract text, depth integer, start_index integer, length integer)`,
+			`create table if not exists CONTRACT_PROFILE (contract text primary key, transactions integer, reentered integer, omittable integer, reordered integer, violations integer, first_block integer, last_block integer)`,
+			`create table if not exists CALL_CHECK (id integer primary key autoincrement, block integer, origin text, recipient text, input_hash text, mode text, ecf integer, violations integer, time integer)`,
+		)
+	}},
+	{"key violations by transaction and block", func(tx *sql.Tx) error {
+		// Violations stored before they were keyed by block are assumed canonical
+		for _, column := range []string{"tx_hash text not null default ''", "block_hash text not null default ''", "canonical integer not null default 1"} {
+			if err := addColumn(tx, "NON_REENTRANT_TRACE", column); err != nil {
+				return err
+			}
+		}
+		return execStmts(tx, `create index if not exists NON_REENTRANT_TRACE_BLOCK_HASH on NON_REENTRANT_TRACE (block_hash)`)
+	}},
+	{"label violations with their execution context", func(tx *sql.Tx) error {
+		return addColumn(tx, "NON_REENTRANT_TRACE", "context text not null default ''")
+	}},
+	{"keep a single transaction counter", func(tx *sql.Tx) error {
+		// Older nodes inserted a counter row on every start, keep the highest
+		return execStmts(tx,
+			`create table LAST_TRANSACTION_ID_SINGLE (id integer primary key check (id = 0), txId integer not null)`,
+			`insert into LAST_TRANSACTION_ID_SINGLE select 0, coalesce(max(txId), 0) from LAST_TRANSACTION_ID`,
+			`drop table LAST_TRANSACTION_ID`,
+			`alter table LAST_TRANSACTION_ID_SINGLE rename to LAST_TRANSACTION_ID`,
+		)
+	}},
+	{"mark the violations exempt by the registry", func(tx *sql.Tx) error {
+		return addColumn(tx, "NON_REENTRANT_TRACE", "exempt integer not null default 0")
+	}},
+	{"store the transactions the checker gave up on", func(tx *sql.Tx) error {
+		return execStmts(tx,
+			`create table if not exists INCONCLUSIVE_CHECK (id integer not null, origin text, block integer, time integer, tx_hash text not null default '', block_hash text not null default '', canonical integer not null default 0, context text not null default '', reason text not null, segments integer)`,
+			`create index if not exists INCONCLUSIVE_CHECK_BLOCK_HASH on INCONCLUSIVE_CHECK (block_hash)`,
+		)
+	}},
+	{"checkpoint the last checked block", func(tx *sql.Tx) error {
+		return execStmts(tx, `create table if not exists LAST_CHECKED_BLOCK (id integer primary key check (id = 0), block integer not null)`)
+	}},
+}
+
+// OpenECFDatabase opens the ECF database at the given path, creating it if missing, and migrates it to the current
+// schema version
+func OpenECFDatabase(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := MigrateECFDatabase(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// MigrateECFDatabase applies the migrations the database lacks, each in its own database transaction along with the
+// update of the schema version
+func MigrateECFDatabase(db *sql.DB) error {
+	if _, err := db.Exec(`create table if not exists ECF_SCHEMA_VERSION (version integer not null)`); err != nil {
+		return err
+	}
+	version, err := ECFDatabaseVersion(db)
+	if err != nil {
+		return err
+	}
+	if version > len(ecfMigrations) {
+		return fmt.Errorf("ECF database schema version %d is newer than the supported version %d", version, ECFSchemaVersion)
+	}
+	for ; version < len(ecfMigrations); version++ {
+		migration := ecfMigrations[version]
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if err := migration.apply(tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("ECF database migration %d (%s) failed: %v", version+1, migration.description, err)
+		}
+		if err := execStmts(tx, `delete from ECF_SCHEMA_VERSION`); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err := tx.Exec(`insert into ECF_SCHEMA_VERSION values(?)`, version+1); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		vm.Debug(1, "Migrated the ECF database to version %d: %s", version+1, migration.description)
+	}
+	return nil
+}
+
+// ECFDatabaseVersion returns the schema version of the ECF database, 0 for a new or unversioned one
+func ECFDatabaseVersion(db *sql.DB) (int, error) {
+	var version sql.NullInt64
+	if err := db.QueryRow(`select max(version) from ECF_SCHEMA_VERSION`).Scan(&version); err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+func execStmts(tx *sql.Tx, stmts ...string) error {
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to execute %s: %v", stmt, err)
+		}
+	}
+	return nil
+}
+
+// addColumn adds a column, given with its definition, to a table unless a database created by an older version has
+// it already
+func addColumn(tx *sql.Tx, table, column string) error {
+	rows, err := tx.Query("pragma table_info(" + table + ")")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	name := strings.Fields(column)[0]
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			existing, kind   string
+			defaultValue     sql.NullString
+		)
+		if err := rows.Scan(&cid, &existing, &kind, &notNull, &defaultValue, &pk); err != nil {
+			return err
+		}
+		if existing == name {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	return execStmts(tx, "alter table "+table+" add column "+column)
+}
@@ -0,0 +1,92 @@
+// Shelly
+
+package node
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Tests that the unversioned database of an older node, with a counter row per start, is migrated without losing its
+// findings, and that opening it again changes nothing.
+func TestMigrateLegacyECFDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ecfdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, DB_FILENAME)
+
+	legacy, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`create table LAST_TRANSACTION_ID (txId integer)`,
+		`insert into LAST_TRANSACTION_ID values(0)`,
+		`insert into LAST_TRANSACTION_ID values(12)`,
+		`insert into LAST_TRANSACTION_ID values(0)`,
+		`create table NON_REENTRANT_TRACE (id integer not null, origin text, block integer, time integer, contract text, depth integer, start_index integer, length integer, tx_hash text not null default '')`,
+		`insert into NON_REENTRANT_TRACE values(7, '0x01', 3, 1000, '0x02', 1, 0, 3, '')`,
+	} {
+		if _, err := legacy.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	legacy.Close()
+
+	for i := 0; i < 2; i++ {
+		db, err := OpenECFDatabase(path)
+		if err != nil {
+			t.Fatalf("open %d: %v", i, err)
+		}
+		if version, err := ECFDatabaseVersion(db); err != nil || version != ECFSchemaVersion {
+			t.Errorf("open %d: version %d (%v), want %d", i, version, err, ECFSchemaVersion)
+		}
+		var rows, txId int
+		if err := db.QueryRow(`select count(*), max(txId) from LAST_TRANSACTION_ID`).Scan(&rows, &txId); err != nil {
+			t.Fatal(err)
+		}
+		if rows != 1 || txId != 12 {
+			t.Errorf("open %d: %d counter rows up to %d, want a single one at 12", i, rows, txId)
+		}
+		var (
+			id, canonical int
+			context       string
+		)
+		if err := db.QueryRow(`select id, canonical, context from NON_REENTRANT_TRACE`).Scan(&id, &canonical, &context); err != nil {
+			t.Fatal(err)
+		}
+		if id != 7 || canonical != 1 || context != "" {
+			t.Errorf("open %d: violation %d, canonical %d, context %q", i, id, canonical, context)
+		}
+		db.Close()
+	}
+}
+
+// Tests that a database written by a newer node is refused.
+func TestNewerECFDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ecfdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, DB_FILENAME)
+
+	db, err := OpenECFDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`update ECF_SCHEMA_VERSION set version = ?`, ECFSchemaVersion+1); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if db, err := OpenECFDatabase(path); err == nil {
+		db.Close()
+		t.Fatal("expected a newer schema to be refused")
+	}
+}
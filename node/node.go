@@ -161,22 +161,12 @@ func (n *Node) Register(constructor ServiceConstructor) error {
 	return nil
 }
 
-func (n *Node) executeStmt(stmt string) error {
-	_, err := n.dbHandler.Exec(stmt)
-	if err != nil {
-		fmt.Println("Faild to execute %s", stmt)
-		return err
-	}
-
-	return nil
-}
-
 func (n *Node) setupDb() error {
-	// SHELLY - create the database
+	// SHELLY - open the database, creating or migrating its tables
 	dbfile := filepath.Join(n.config.DataDir, DB_FILENAME)
-	db, err := sql.Open("sqlite3", dbfile)
+	db, err := OpenECFDatabase(dbfile)
 	if err != nil {
-		fmt.Println("Faild to open database file")
+		fmt.Println("Failed to open database file", dbfile)
 		return err
 	}
 
@@ -184,22 +174,6 @@ func (n *Node) setupDb() error {
 
 	n.dbHandler = db
 
-	// SHELLY - create the tables
-	lastTxIdCreateTableStmt := `create table if not exists LAST_TRANSACTION_ID (txId integer)`
-	nonReentrantTraceTableStmt := `create table if not exists NON_REENTRANT_TRACE (id integer not null, origin text, block integer, time integer, contract text, depth integer, start_index integer, length integer)`
-
-	if err := n.executeStmt(lastTxIdCreateTableStmt); err != nil {
-		return err
-	}
-	if err := n.executeStmt(`insert into LAST_TRANSACTION_ID values(0)`); err != nil {
-		return err
-	}
-	if err := n.executeStmt(nonReentrantTraceTableStmt); err != nil {
-		return err
-	}
-
-	fmt.Println("Created all tables")
-
 	//fmt.Printf("Setting db handler %v on the checker object\n", n.GetDbHandler())
 	vm.TheChecker().SetDbHandler(n.GetDbHandler())
 
@@ -218,6 +192,12 @@ func (n *Node) Start() error {
 	if err := n.openDataDir(); err != nil {
 		return err
 	}
+	// SHELLY - reopen the database closed by a previous Stop
+	if n.dbHandler == nil {
+		if err := n.setupDb(); err != nil {
+			return err
+		}
+	}
 
 	// Initialize the p2p server. This creates the node key and
 	// discovery databases.
@@ -555,12 +535,18 @@ func (n *Node) stopWS() {
 }
 
 func (n *Node) teardownDb() error {
+	// SHELLY - write the queued findings
+	vm.TheChecker().StopWriter()
 	// SHELLY - save the transaction id
 	fmt.Printf("Updating last transaction id to %d\n", vm.TheChecker().TransactionID)
 	_, dberr := n.dbHandler.Exec(fmt.Sprintf("update LAST_TRANSACTION_ID set txId = %d", vm.TheChecker().TransactionID))
 	if dberr != nil {
 		fmt.Printf("Failed to set last tx id, %v\n", dberr)
 	}
+	// SHELLY - save the contract profiles
+	if err := vm.TheChecker().SaveProfiles(); err != nil {
+		fmt.Printf("Failed to save contract profiles, %v\n", err)
+	}
 	// SHELLY - close the database
 	fmt.Printf("Closing the db handler\n")
 	n.dbHandler.Close()
@@ -774,6 +760,11 @@ func (n *Node) apis() []rpc.API {
 			Version:   "1.0",
 			Service:   NewPublicWeb3API(n),
 			Public:    true,
+		}, {
+			Namespace: "ecf",
+			Version:   "1.0",
+			Service:   NewPublicECFAPI(n),
+			Public:    true,
 		},
 	}
 }
@@ -63,6 +63,7 @@ type btBlock struct {
 	Rlp          string
 	Transactions []btTransaction
 	UncleHeaders []*btHeader
+	ECF          []*ECFExpectation // Optional, one per transaction, see ECFExpectation
 }
 
 type btAccount struct {
@@ -151,7 +152,7 @@ func runBlockTests(homesteadBlock, daoForkBlock, gasPriceFork *big.Int, bt map[s
 			continue
 		}
 		// test the block
-		if err := runBlockTest(homesteadBlock, daoForkBlock, gasPriceFork, test); err != nil {
+		if err := runBlockTest(homesteadBlock, daoForkBlock, gasPriceFork, name, test); err != nil {
 			return fmt.Errorf("%s: %v", name, err)
 		}
 		glog.Infoln("Block test passed: ", name)
@@ -160,7 +161,7 @@ func runBlockTests(homesteadBlock, daoForkBlock, gasPriceFork *big.Int, bt map[s
 	return nil
 }
 
-func runBlockTest(homesteadBlock, daoForkBlock, gasPriceFork *big.Int, test *BlockTest) error {
+func runBlockTest(homesteadBlock, daoForkBlock, gasPriceFork *big.Int, name string, test *BlockTest) error {
 	// import pre accounts & construct test genesis block & state root
 	db, _ := ethdb.NewMemDatabase()
 	if _, err := test.InsertPreState(db); err != nil {
@@ -179,10 +180,14 @@ func runBlockTest(homesteadBlock, daoForkBlock, gasPriceFork *big.Int, test *Blo
 	}
 
 	//vm.Debug = true
+	vm.TheChecker().ForgetTransactionResults()
 	validBlocks, err := test.TryBlocksInsert(chain)
 	if err != nil {
 		return err
 	}
+	if err := test.ValidateECF(name, validBlocks); err != nil {
+		return err
+	}
 
 	lastblockhash := common.HexToHash(test.lastblockhash)
 	cmlast := chain.LastBlockHash()
@@ -287,6 +292,35 @@ func (t *BlockTest) TryBlocksInsert(blockchain *core.BlockChain) ([]btBlock, err
 	return validBlocks, nil
 }
 
+// ValidateECF compares the checker's verdicts on the transactions of the valid blocks to the expectations of the
+// blocks that have some
+func (t *BlockTest) ValidateECF(name string, validBlocks []btBlock) error {
+	countECFFixture()
+	index := 0
+	for _, b := range validBlocks {
+		cb, err := mustConvertBlock(b)
+		if err != nil {
+			return err
+		}
+		txs := cb.Transactions()
+		if b.ECF != nil && len(b.ECF) != len(txs) {
+			return fmt.Errorf("Block #%v has %d ECF expectations for %d transactions", cb.Number(), len(b.ECF), len(txs))
+		}
+		for i, tx := range txs {
+			var expected *ECFExpectation
+			if b.ECF != nil {
+				expected = b.ECF[i]
+			}
+			result, _ := vm.TheChecker().TransactionResult(tx.Hash())
+			if err := checkECF(name, index, expected, result); err != nil {
+				return fmt.Errorf("Block #%v transaction %d: %v", cb.Number(), i, err)
+			}
+			index++
+		}
+	}
+	return nil
+}
+
 func validateHeader(h *btHeader, h2 *types.Header) error {
 	expectedBloom := mustConvertBytes(h.Bloom)
 	if !bytes.Equal(expectedBloom, h2.Bloom.Bytes()) {
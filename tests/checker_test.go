@@ -3,71 +3,220 @@
 package tests
 
 import (
+	"bytes"
 	"fmt"
+	"math/big"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/vm"
-	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/params"
 )
 
-func setupEnv(address string, caller string) *vm.EVM {
-	pre := make(map[string]Account)
-	db, _ := ethdb.NewMemDatabase()
-	statedb := makePreState(db, pre)
-
-	env := make(map[string]string)
-	env["currentCoinbase"] = "0"
-	env["currentDifficulty"] = "0"
-	env["currentGasLimit"] = "0"
-	env["currentNumber"] = "0"
-	env["previousHash"] = "0"
-	env["currentTimestamp"] = "0"
-
-	exec := make(map[string]string)
-	exec["address"] = address
-	exec["caller"] = caller
-	exec["data"] = "0"
-	exec["gas"] = "0"
-	exec["value"] = "0"
-
-	chainConfig := &params.ChainConfig{
-		HomesteadBlock: params.MainNetHomesteadBlock,
-		DAOForkBlock:   params.MainNetDAOForkBlock,
-		DAOForkSupport: true,
-	}
+var (
+	checkerA = common.StringToAddress("111111191324e6712a591f304b4eedef6ad9bb9d")
+	checkerB = common.StringToAddress("222222291324e6712a591f304b4eedef6ad9bb9d")
+	checkerC = common.StringToAddress("333333391324e6712a591f304b4eedef6ad9bb9d")
 
-	environment, _ := NewEVMEnvironment(true, chainConfig, statedb, env, exec)
-	return environment
+	balance = common.HexToHash("3ac225168df54212a25c1c01fd35bebfea408fdac2e31ddd6f80a4bbf9a5f1ca")
+	counter = common.HexToHash("01")
+)
 
+/************** UNIT TESTS ********************/
+func TestCheckerScenarios(t *testing.T) {
+	tests := []struct {
+		name  string
+		trace *vm.TraceBuilder
+		ecf   bool
+	}{
+		// Start A, call B, call A, return to B, return to A: A1 B1 A'1 B2 A2
+		{"A1 B1 A'1 B2 A2, A' reads", vm.Trace().
+			Call(checkerA).Read(balance).
+			Call(checkerB).
+			Call(checkerA).Read(balance).Ret().
+			Ret().
+			Write(balance).Ret(), true},
+		{"A1 B1 A'1 B2 A2, DAO", vm.Trace().
+			Call(checkerA).Read(balance).
+			Call(checkerB).
+			Call(checkerA).Read(balance).Write(balance).Ret().
+			Ret().
+			Write(balance).Ret(), false},
+
+		// Start A, call B, call C, call A, return to C, return to B, return to A: A1 B1 C1 A'1 C2 B2 A2
+		{"A1 B1 C1 A'1 C2 B2 A2, independent", vm.Trace().
+			Call(checkerA).Read(balance).
+			Call(checkerB).Call(checkerC).
+			Call(checkerA).Write(counter).Ret().
+			Ret().Ret().
+			Write(balance).Ret(), true},
+		{"A1 B1 C1 A'1 C2 B2 A2, DAO", vm.Trace().
+			Call(checkerA).Read(balance).
+			Call(checkerB).Call(checkerC).
+			Call(checkerA).Read(balance).Write(balance).Ret().
+			Ret().Ret().
+			Write(balance).Ret(), false},
+
+		// Start A, call B, call A, call C, call A, return to C, return to A, return to B, return to A: A1 B1 A'1 C1 A''1 C2 A'2 B2 A2
+		{"A1 B1 A'1 C1 A''1 C2 A'2 B2 A2, independent", vm.Trace().
+			Call(checkerA).Read(balance).
+			Call(checkerB).
+			Call(checkerA).Read(counter).
+			Call(checkerC).
+			Call(checkerA).Ret().
+			Ret().
+			Write(counter).Ret().
+			Ret().
+			Write(balance).Ret(), true},
+		{"A1 B1 A'1 C1 A''1 C2 A'2 B2 A2, inner DAO", vm.Trace().
+			Call(checkerA).
+			Call(checkerB).
+			Call(checkerA).Read(counter).
+			Call(checkerC).
+			Call(checkerA).Read(counter).Write(counter).Ret().
+			Ret().
+			Write(counter).Ret().
+			Ret().
+			Ret(), false},
+
+		// A1 B1 A'1 B2 A''1 B3 A2
+		{"A1 B1 A'1 B2 A''1 B3 A2, reads only", vm.Trace().
+			Call(checkerA).Read(balance).
+			Call(checkerB).
+			Call(checkerA).Read(balance).Ret().
+			Call(checkerA).Read(balance).Ret().
+			Ret().
+			Write(balance).Ret(), true},
+		{"A1 B1 A'1 B2 A''1 B3 A2, DAO on second re-entry", vm.Trace().
+			Call(checkerA).Read(balance).
+			Call(checkerB).
+			Call(checkerA).Read(counter).Ret().
+			Call(checkerA).Read(balance).Write(balance).Ret().
+			Ret().
+			Write(balance).Ret(), false},
+	}
+	for _, mode := range []vm.ECFCheckMode{vm.ECFModeHeuristic, vm.ECFModeExact} {
+		for _, test := range tests {
+			result, err := test.trace.Check(nil, mode)
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			if result.IsECF() != test.ecf {
+				t.Errorf("%s (%v): ECF mismatch: have %v, want %v (violations %+v)", test.name, mode, result.IsECF(), test.ecf, result.Violations)
+			}
+			if !test.ecf && result.Violations[0].Contract != checkerA {
+				t.Errorf("%s (%v): violation on %x, want %x", test.name, mode, result.Violations[0].Contract, checkerA)
+			}
+		}
+	}
 }
 
-/************** UNIT TESTS ********************/
-func Test1(t *testing.T) {
-	// init dummy checker, evm, and contracts A,B,C.
-	checker := vm.TheChecker()
-	environment := setupEnv("0", "0")
-	A := environment.StateDB.CreateAccount(common.StringToAddress("111111191324e6712a591f304b4eedef6ad9bb9d"))
-	B := environment.StateDB.CreateAccount(common.StringToAddress("222222291324e6712a591f304b4eedef6ad9bb9d"))
-	C := environment.StateDB.CreateAccount(common.StringToAddress("333333391324e6712a591f304b4eedef6ad9bb9d"))
+func TestTraceBuilderMisuse(t *testing.T) {
+	tests := map[string]*vm.TraceBuilder{
+		"empty":                vm.Trace(),
+		"read before the call": vm.Trace().Read(balance).Call(checkerA).Ret(),
+		"not returned":         vm.Trace().Call(checkerA).Call(checkerB).Ret(),
+		"returned twice":       vm.Trace().Call(checkerA).Ret().Ret(),
+		"call after the end":   vm.Trace().Call(checkerA).Ret().Call(checkerB).Ret(),
+	}
+	for name, builder := range tests {
+		if _, err := builder.Build(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
 
-	cA := vm.NewContract(A, A, nil, nil)
-	cB := vm.NewContract(B, B, nil, nil)
-	cC := vm.NewContract(C, C, nil, nil)
-	fmt.Printf("Working on checker %v, environment %v, calling contract %v, %v, %v", checker, environment, cA, cB, cC)
+// ecfFixtureCode is the code of the contracts of ecfFixture. A reads slot 0, calls B, which calls A back, and writes
+// slot 0. Re-entered, A reads slot 0, and writes it too in the reentrant variant.
+const (
+	ecfFixtureA         = "337300000000000000000000000000000000000000bb1460495760005450600060006000600060007300000000000000000000000000000000000000bb620186a0f1506005600055005b60005450%s00"
+	ecfFixtureB         = "600060006000600060007300000000000000000000000000000000000000aa6200c350f15000"
+	ecfFixtureReentrant = "6007600055"
 
-	// Simulate: Start A, call B, call A, return to B, return to A: A1 B1 A'1 B2 A2 -> Test each of the 4 cutpoints + no cutpoint
-	checker.UponEVMStart(environment.Interpreter(), cA)
-	checker.UponSLoad(environment, cA, common.HexToHash("3ac225168df54212a25c1c01fd35bebfea408fdac2e31ddd6f80a4bbf9a5f1ca"), nil)
-	checker.UponEVMEnd(environment.Interpreter(), cA)
+	ecfFixtureReadRoot      = "e0ccd7f4aa9ceb580dc6ac5cd9d8bc1d63cd0b247a095e0fb2b4c9f38556bee6"
+	ecfFixtureReentrantRoot = "e3c6dd721e3bede4857fcaf285bbcb5fc0e4d4e4a7ab120a03b276d8e83dd632"
+)
 
-	// Simulate: Start A, call B, call C, call A, return to C, return to B, return to A: A1 B1 C1 A'1 C2 B2 A2 -> Test each of the 4 cutpoints + no cutpoint
+// ecfFixture is a state test calling A, whose re-entry writes if reentrant, with the given ECF expectation
+func ecfFixture(reentrant bool, expectation string) string {
+	a, postStateRoot := fmt.Sprintf(ecfFixtureA, ""), ecfFixtureReadRoot
+	if reentrant {
+		a, postStateRoot = fmt.Sprintf(ecfFixtureA, ecfFixtureReentrant), ecfFixtureReentrantRoot
+	}
+	return fmt.Sprintf(`{"ecf": {
+		"env": {"currentCoinbase": "2adc25665018aa1fe0e6bc666dac8fc2697ff9ba", "currentDifficulty": "0x0100", "currentGasLimit": "0x0f4240", "currentNumber": "0x00", "currentTimestamp": "0x01", "previousHash": "5e20a0453cecd065ea59c37ac63e079ee08998b6045136a8ce6635c7912ec0b6"},
+		"logs": [],
+		"out": "0x",
+		"post": {},
+		"postStateRoot": "%s",
+		"pre": {
+			"00000000000000000000000000000000000000aa": {"balance": "0x00", "code": "0x%s", "nonce": "0x00", "storage": {}},
+			"00000000000000000000000000000000000000bb": {"balance": "0x00", "code": "0x%s", "nonce": "0x00", "storage": {}},
+			"a94f5374fce5edbc8e2a8697c15331677e6ebf0b": {"balance": "0x0de0b6b3a7640000", "code": "0x", "nonce": "0x00", "storage": {}}
+		},
+		"transaction": {"data": "", "gasLimit": "0x061a80", "gasPrice": "0x01", "nonce": "0x00", "secretKey": "45a915e4d060149eb4365960e6a7a45f334393093061116b197e3240065ff2d8", "to": "00000000000000000000000000000000000000aa", "value": "0x00"},
+		"ecf": %s
+	}}`, postStateRoot, a, ecfFixtureB, expectation)
+}
 
-	// Simulate: Start A, call B, call A, call C, call A, return to C, return to A, return to B, return to A: A1 B1 A'1 C1 A''1 C2 A'2 B2 A2 -> Test each of the 4 inner cutpoints and 4 outer cutpoints + no cutpoint
+func TestStateTestECFExpectations(t *testing.T) {
+	chainConfig := &params.ChainConfig{HomesteadBlock: big.NewInt(0)}
+	tests := []struct {
+		name      string
+		reentrant bool
+		expected  string
+		fails     bool
+	}{
+		{"read, ecf", false, `{"verdict": "ecf"}`, false},
+		{"read, nonECF", false, `{"verdict": "nonECF"}`, true},
+		{"reentrant, nonECF on A", true, `{"verdict": "nonECF", "contract": "00000000000000000000000000000000000000aa"}`, false},
+		{"reentrant, nonECF on B", true, `{"verdict": "nonECF", "contract": "00000000000000000000000000000000000000bb"}`, true},
+		{"reentrant, ecf", true, `{"verdict": "ecf"}`, true},
+		{"invalid verdict", false, `{"verdict": "safe"}`, true},
+	}
+	for _, test := range tests {
+		err := RunStateTestWithReader(chainConfig, strings.NewReader(ecfFixture(test.reentrant, test.expected)), nil)
+		if test.fails && err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		} else if !test.fails && err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+	}
 
-	// A1 B1 A'1 B2 A''1 B3 A2 -> Test each of the 5 cutpoints + no cutpoint
+	// Over the limits, the fixture must expect an inconclusive verdict
+	status := vm.TheChecker().Status()
+	maxCheckTime, _ := time.ParseDuration(status.MaxCheckTime)
+	vm.TheChecker().SetLimits(2, 0, 0, 0)
+	defer vm.TheChecker().SetLimits(status.MaxSegments, status.MaxLocations, status.MaxCheckWork, maxCheckTime)
+	if err := RunStateTestWithReader(chainConfig, strings.NewReader(ecfFixture(true, `{"verdict": "inconclusive", "reason": "segments"}`)), nil); err != nil {
+		t.Errorf("inconclusive: %v", err)
+	}
+}
 
-	//
+func TestECFSummary(t *testing.T) {
+	summary := &ECFSummary{Source: "fixture.json"}
+	CollectECFSummary(summary)
+	defer CollectECFSummary(nil)
 
+	chainConfig := &params.ChainConfig{HomesteadBlock: big.NewInt(0)}
+	for _, reentrant := range []bool{false, true} {
+		if err := RunStateTestWithReader(chainConfig, strings.NewReader(ecfFixture(reentrant, "null")), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if summary.Fixtures != 2 || summary.Checked != 2 || summary.Recursive != 2 || summary.NonECF != 1 || summary.Inconclusive != 0 {
+		t.Fatalf("summary mismatch: %+v", summary)
+	}
+	if len(summary.Transactions) != 2 || summary.Transactions[1].Verdict != ECFVerdictNonECF || summary.Transactions[1].Source != "fixture.json" {
+		t.Fatalf("transactions mismatch: %+v", summary.Transactions)
+	}
+	var text bytes.Buffer
+	if err := summary.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(text.String(), "2 fixtures, 2 transactions checked, 2 recursive, 1 not ECF, 0 inconclusive\n") {
+		t.Errorf("unexpected text summary:\n%s", text.String())
+	}
 }
@@ -0,0 +1,206 @@
+// Shelly
+
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// The verdicts a fixture may expect of the ECF checker
+const (
+	ECFVerdictECF          = "ecf"
+	ECFVerdictNonECF       = "nonECF"
+	ECFVerdictInconclusive = "inconclusive"
+)
+
+// ECFExpectation is what a fixture expects the ECF checker to conclude on one of its transactions. It is given as
+// the optional "ecf" field of a state test, and as the optional "ecf" list of a block test's block, one per
+// transaction of the block.
+type ECFExpectation struct {
+	Verdict  string // ecf, nonECF or inconclusive
+	Contract string // With nonECF, a contract one of the violations must be on, if given
+	Reason   string // With inconclusive, the limit that must have been exceeded, if given
+}
+
+// ecfVerdict returns the verdict of a check, a transaction that ran no code being ECF
+func ecfVerdict(result *vm.ECFResult) string {
+	switch {
+	case result == nil:
+		return ECFVerdictECF
+	case !result.IsConclusive():
+		return ECFVerdictInconclusive
+	case result.IsECF():
+		return ECFVerdictECF
+	default:
+		return ECFVerdictNonECF
+	}
+}
+
+// check compares the result of checking the transaction, nil if it ran no code, to the expectation
+func (expected *ECFExpectation) check(result *vm.ECFResult) error {
+	switch expected.Verdict {
+	case ECFVerdictECF, ECFVerdictNonECF, ECFVerdictInconclusive:
+	default:
+		return fmt.Errorf("invalid ECF verdict %q, want %s, %s or %s", expected.Verdict, ECFVerdictECF, ECFVerdictNonECF, ECFVerdictInconclusive)
+	}
+	if verdict := ecfVerdict(result); verdict != expected.Verdict {
+		var violations []ECFViolationSummary
+		if result != nil {
+			violations = summarizeViolations(result.NonExempt())
+		}
+		return fmt.Errorf("ECF verdict mismatch: want %s, have %s (violations %v)", expected.Verdict, verdict, violations)
+	}
+	if expected.Verdict == ECFVerdictNonECF && expected.Contract != "" {
+		contract := common.HexToAddress(expected.Contract)
+		for _, violation := range result.NonExempt() {
+			if violation.Contract == contract {
+				return nil
+			}
+		}
+		return fmt.Errorf("ECF violation mismatch: want one on %x, have %v", contract, summarizeViolations(result.NonExempt()))
+	}
+	if expected.Verdict == ECFVerdictInconclusive && expected.Reason != "" && expected.Reason != result.Inconclusive {
+		return fmt.Errorf("inconclusive ECF reason mismatch: want %s, have %s", expected.Reason, result.Inconclusive)
+	}
+	return nil
+}
+
+// ECFSummary collects the checker's verdicts on the transactions of the fixtures run while it is set with
+// CollectECFSummary, listing those whose traces are recursive
+type ECFSummary struct {
+	Source       string                   `json:"-"` // The fixture file being run, set by the caller
+	Fixtures     int                      `json:"fixtures"`
+	Checked      int                      `json:"checked"`      // Transactions that ran code
+	Recursive    int                      `json:"recursive"`    // Transactions re-entering a contract
+	NonECF       int                      `json:"nonECF"`       // Transactions with a violation
+	Inconclusive int                      `json:"inconclusive"` // Transactions the checker gave up on
+	Transactions []*ECFTransactionSummary `json:"transactions"` // The recursive, non-ECF and inconclusive ones
+}
+
+// ECFTransactionSummary is the verdict on a transaction of a fixture
+type ECFTransactionSummary struct {
+	Source       string                `json:"source"`
+	Fixture      string                `json:"fixture"`
+	Transaction  int                   `json:"transaction"` // Index in the fixture, counting through the blocks
+	Verdict      string                `json:"verdict"`
+	Reason       string                `json:"reason,omitempty"`
+	Segments     int                   `json:"segments"`
+	Reentered    []common.Address      `json:"reentered"`
+	Violations   []ECFViolationSummary `json:"violations,omitempty"`
+	ExactAborted int                   `json:"exactAborted,omitempty"`
+}
+
+// ECFViolationSummary locates a violation in a transaction
+type ECFViolationSummary struct {
+	Contract common.Address `json:"contract"`
+	Depth    int            `json:"depth"`
+	Start    int            `json:"start"`
+	Length   int            `json:"length"`
+}
+
+func (violation ECFViolationSummary) String() string {
+	return fmt.Sprintf("%x depth %d start %d length %d", violation.Contract, violation.Depth, violation.Start, violation.Length)
+}
+
+func summarizeViolations(violations []vm.ECFViolation) []ECFViolationSummary {
+	summaries := make([]ECFViolationSummary, len(violations))
+	for i, violation := range violations {
+		summaries[i] = ECFViolationSummary{violation.Contract, violation.Depth, violation.StartIndex, violation.Length}
+	}
+	return summaries
+}
+
+var ecfSummary *ECFSummary
+
+// CollectECFSummary makes the state and block test runners add their fixtures to the summary, or stop if nil
+func CollectECFSummary(summary *ECFSummary) {
+	ecfSummary = summary
+}
+
+// checkECF compares the checker's verdict on a transaction of a fixture, nil if it ran no code, to the fixture's
+// expectation if it has one, and adds it to the summary being collected if any
+func checkECF(fixture string, index int, expected *ECFExpectation, result *vm.ECFResult) error {
+	if ecfSummary != nil {
+		ecfSummary.add(fixture, index, result)
+	}
+	if expected == nil {
+		return nil
+	}
+	return expected.check(result)
+}
+
+// countECFFixture counts a fixture run while a summary is being collected
+func countECFFixture() {
+	if ecfSummary != nil {
+		ecfSummary.Fixtures++
+	}
+}
+
+// add notes the verdict on a transaction of a fixture, nil if it ran no code
+func (summary *ECFSummary) add(fixture string, index int, result *vm.ECFResult) {
+	if result == nil {
+		return
+	}
+	summary.Checked++
+	tx := &ECFTransactionSummary{
+		Source:       summary.Source,
+		Fixture:      fixture,
+		Transaction:  index,
+		Verdict:      ecfVerdict(result),
+		Reason:       result.Inconclusive,
+		Segments:     result.Segments,
+		Reentered:    result.ReenteredContracts(),
+		Violations:   summarizeViolations(result.Violations),
+		ExactAborted: result.ExactAborted,
+	}
+	if len(tx.Reentered) > 0 {
+		summary.Recursive++
+	}
+	switch tx.Verdict {
+	case ECFVerdictNonECF:
+		summary.NonECF++
+	case ECFVerdictInconclusive:
+		summary.Inconclusive++
+	}
+	if len(tx.Reentered) > 0 || tx.Verdict != ECFVerdictECF {
+		summary.Transactions = append(summary.Transactions, tx)
+	}
+}
+
+// WriteJSON writes the summary as JSON
+func (summary *ECFSummary) WriteJSON(w io.Writer) error {
+	encoded, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", encoded)
+	return err
+}
+
+// WriteText writes the summary as one line per listed transaction followed by the totals
+func (summary *ECFSummary) WriteText(w io.Writer) error {
+	for _, tx := range summary.Transactions {
+		reentered := make([]string, len(tx.Reentered))
+		for i, contract := range tx.Reentered {
+			reentered[i] = fmt.Sprintf("%x", contract)
+		}
+		line := fmt.Sprintf("%s %s tx %d: %s, %d segments, re-entered [%s]", tx.Source, tx.Fixture, tx.Transaction, tx.Verdict, tx.Segments, strings.Join(reentered, " "))
+		if tx.Reason != "" {
+			line += ", " + tx.Reason + " limit exceeded"
+		}
+		if len(tx.Violations) > 0 {
+			line += fmt.Sprintf(", violations %v", tx.Violations)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d fixtures, %d transactions checked, %d recursive, %d not ECF, %d inconclusive\n", summary.Fixtures, summary.Checked, summary.Recursive, summary.NonECF, summary.Inconclusive)
+	return err
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/logger/glog"
 	"github.com/ethereum/go-ethereum/params"
@@ -114,7 +115,7 @@ func runStateTests(chainConfig *params.ChainConfig, tests map[string]VmTest, ski
 		}
 
 		//fmt.Println("StateTest:", name)
-		if err := runStateTest(chainConfig, test); err != nil {
+		if err := runStateTest(chainConfig, name, test); err != nil {
 			return fmt.Errorf("%s: %s\n", name, err.Error())
 		}
 
@@ -125,7 +126,7 @@ func runStateTests(chainConfig *params.ChainConfig, tests map[string]VmTest, ski
 
 }
 
-func runStateTest(chainConfig *params.ChainConfig, test VmTest) error {
+func runStateTest(chainConfig *params.ChainConfig, name string, test VmTest) error {
 	db, _ := ethdb.NewMemDatabase()
 	statedb := makePreState(db, test.Pre)
 
@@ -149,8 +150,20 @@ func runStateTest(chainConfig *params.ChainConfig, test VmTest) error {
 		logs []*types.Log
 	)
 
+	checker := vm.TheChecker()
+	previous := checker.LastResult()
 	ret, logs, _, _ = RunState(chainConfig, statedb, env, test.Transaction)
 
+	// Compare the checker's verdict, if the transaction ran code
+	result := checker.LastResult()
+	if result == previous {
+		result = nil
+	}
+	countECFFixture()
+	if err := checkECF(name, 0, test.ECF, result); err != nil {
+		return err
+	}
+
 	// Compare expected and actual return
 	var rexp []byte
 	if strings.HasPrefix(test.Out, "#") {
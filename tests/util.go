@@ -147,6 +147,7 @@ type VmTest struct {
 	Post          map[string]Account
 	Pre           map[string]Account
 	PostStateRoot string
+	ECF           *ECFExpectation // Optional, see ECFExpectation
 }
 
 func NewEVMEnvironment(vmTest bool, chainConfig *params.ChainConfig, statedb *state.StateDB, envValues map[string]string, tx map[string]string) (*vm.EVM, core.Message) {